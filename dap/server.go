@@ -0,0 +1,369 @@
+// Package dap 通过标准输入输出提供调试适配器协议(DAP)的服务:
+// 行断点, 单步执行, 调用栈, 各层作用域中的变量与表达式求值
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/WAY29/LoxGo/framing"
+	"github.com/WAY29/LoxGo/interpreter"
+	"github.com/WAY29/LoxGo/lox"
+)
+
+// 解释器只有一个线程
+const threadID = 1
+
+type Server struct {
+	in *bufio.Reader
+
+	// 事件由程序所在的goroutine发送, 以下字段由mu保护
+	mu     sync.Mutex
+	out    io.Writer
+	seq    int
+	paused bool
+
+	lox         *lox.Lox
+	debugger    *interpreter.Debugger
+	breakpoints map[string][]int // 启动程序前设置的断点
+	launch      *LaunchArguments
+	source      []byte
+	configured  bool
+	started     bool
+
+	commands chan command  // 暂停时交给程序所在的goroutine处理的请求
+	done     chan struct{} // 程序结束时关闭
+
+	// 只在程序所在的goroutine中访问
+	references [][]interpreter.DebugVariable // variablesReference-1 对应的变量
+}
+
+// 暂停时处理的请求, inspect为nil时按mode继续执行, err不为nil时终止程序
+type command struct {
+	inspect func(pause *interpreter.Pause)
+	mode    interpreter.StepMode
+	err     error
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:          bufio.NewReader(in),
+		out:         out,
+		breakpoints: make(map[string][]int),
+		commands:    make(chan command),
+		done:        make(chan struct{}),
+	}
+}
+
+// 发送消息并设置序号
+func (s *Server) send(message interface{}, seq *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	*seq = s.seq
+	return framing.Write(s.out, message)
+}
+
+func (s *Server) respond(req *request, body interface{}, err error) {
+	resp := &response{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	s.send(resp, &resp.Seq)
+}
+
+func (s *Server) event(name string, body interface{}) {
+	e := &event{Type: "event", Event: name, Body: body}
+	s.send(e, &e.Seq)
+}
+
+func (s *Server) setPaused(paused bool) {
+	s.mu.Lock()
+	s.paused = paused
+	s.mu.Unlock()
+}
+
+func (s *Server) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// 处理请求直到收到disconnect请求或输入结束, 退出前终止仍在运行的程序
+func (s *Server) Run() error {
+	defer s.terminate()
+
+	for {
+		body, err := framing.Read(s.in)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// 无法解析的消息没有对应的请求可以回复, 报告后继续处理之后的消息
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.event("output", &OutputEventBody{Category: "console", Output: fmt.Sprintf("Invalid message: %v\n", err)})
+			continue
+		}
+		if req.Command == "disconnect" {
+			s.terminate()
+			s.respond(&req, nil, nil)
+			return nil
+		}
+		s.handle(&req)
+	}
+}
+
+func decode(req *request, v interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(req.Arguments, v)
+}
+
+func (s *Server) handle(req *request) {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, &Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil)
+		s.event("initialized", nil)
+	case "launch":
+		s.respond(req, nil, s.load(req))
+		s.start()
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := decode(req, &args); err != nil {
+			s.respond(req, nil, err)
+			return
+		}
+		body := &SetBreakpointsResponseBody{Breakpoints: make([]Breakpoint, len(args.Breakpoints))}
+		lines := make([]int, len(args.Breakpoints))
+		for n, breakpoint := range args.Breakpoints {
+			lines[n] = breakpoint.Line
+			body.Breakpoints[n] = Breakpoint{Verified: true, Line: breakpoint.Line}
+		}
+		s.breakpoints[args.Source.Path] = lines
+		if s.debugger != nil {
+			s.debugger.SetBreakpoints(args.Source.Path, lines)
+		}
+		s.respond(req, body, nil)
+	case "configurationDone":
+		s.configured = true
+		s.respond(req, nil, nil)
+		s.start()
+	case "threads":
+		s.respond(req, &ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil)
+	case "stackTrace":
+		s.inspect(req, s.stackTrace)
+	case "scopes":
+		s.inspect(req, s.scopes)
+	case "variables":
+		s.inspect(req, s.variables)
+	case "evaluate":
+		s.inspect(req, s.evaluate)
+	case "continue":
+		s.resume(req, interpreter.StepContinue, &ContinueResponseBody{AllThreadsContinued: true})
+	case "next":
+		s.resume(req, interpreter.StepOver, nil)
+	case "stepIn":
+		s.resume(req, interpreter.StepIn, nil)
+	case "stepOut":
+		s.resume(req, interpreter.StepOut, nil)
+	case "pause":
+		if s.debugger != nil && !s.isPaused() {
+			s.debugger.Pause()
+		}
+		s.respond(req, nil, nil)
+	case "terminate":
+		s.terminate()
+		s.respond(req, nil, nil)
+	default:
+		s.respond(req, nil, fmt.Errorf("Unsupported command '%s'.", req.Command))
+	}
+}
+
+// 读取launch参数中的程序, 在configurationDone之后开始执行
+func (s *Server) load(req *request) error {
+	var args LaunchArguments
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+	if args.Program == "" {
+		return errors.New("Missing 'program' in launch arguments.")
+	}
+	source, err := ioutil.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	s.launch, s.source = &args, source
+	s.lox = lox.NewLox()
+	s.lox.SetOutput(&output{server: s})
+	s.lox.SetModulePath(args.ModulePath...)
+
+	s.debugger = s.lox.Debug(args.Program, s.stopped)
+	if args.StopOnEntry {
+		s.debugger.StopOnEntry()
+	}
+	for file, lines := range s.breakpoints {
+		s.debugger.SetBreakpoints(file, lines)
+	}
+	return nil
+}
+
+func (s *Server) start() {
+	if s.launch == nil || !s.configured || s.started {
+		return
+	}
+	s.started = true
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		_, err := s.lox.EvalNamed(s.launch.Program, bytes.NewReader(s.source))
+		if _, ok := err.(*interpreter.TerminateSignal); !ok && err != nil {
+			s.event("output", &OutputEventBody{Category: "stderr", Output: err.Error() + "\n"})
+			exitCode = 1
+		}
+		s.event("exited", &ExitedEventBody{ExitCode: exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// 终止程序并等待其结束
+func (s *Server) terminate() {
+	if !s.started {
+		return
+	}
+	s.debugger.Terminate()
+	// 程序正在暂停或即将暂停时通过请求终止, 否则在执行下一条语句时终止
+	for {
+		select {
+		case s.commands <- command{err: &interpreter.TerminateSignal{}}:
+			s.setPaused(false)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// 程序暂停时调用, 在程序所在的goroutine中处理请求直到继续执行
+func (s *Server) stopped(pause *interpreter.Pause) (interpreter.StepMode, error) {
+	s.references = s.references[:0]
+	s.setPaused(true)
+	s.event("stopped", &StoppedEventBody{Reason: string(pause.Reason), ThreadID: threadID, AllThreadsStopped: true})
+	for command := range s.commands {
+		if command.inspect != nil {
+			command.inspect(pause)
+			continue
+		}
+		return command.mode, command.err
+	}
+	return interpreter.StepContinue, nil
+}
+
+// 暂停时在程序所在的goroutine中处理请求
+func (s *Server) inspect(req *request, handler func(req *request, pause *interpreter.Pause) (interface{}, error)) {
+	if !s.isPaused() {
+		s.respond(req, nil, errors.New("Program is not paused."))
+		return
+	}
+	s.commands <- command{inspect: func(pause *interpreter.Pause) {
+		body, err := handler(req, pause)
+		s.respond(req, body, err)
+	}}
+}
+
+// 先回复再继续执行, 保证回复在之后的事件之前发送
+func (s *Server) resume(req *request, mode interpreter.StepMode, body interface{}) {
+	if !s.isPaused() {
+		s.respond(req, nil, errors.New("Program is not paused."))
+		return
+	}
+	s.setPaused(false)
+	s.respond(req, body, nil)
+	s.commands <- command{mode: mode}
+}
+
+func (s *Server) stackTrace(req *request, pause *interpreter.Pause) (interface{}, error) {
+	body := &StackTraceResponseBody{StackFrames: make([]StackFrame, len(pause.Frames)), TotalFrames: len(pause.Frames)}
+	for n, frame := range pause.Frames {
+		body.StackFrames[n] = StackFrame{ID: n, Name: frame.Function, Line: frame.Line, Column: frame.Column}
+		if frame.Line > 0 {
+			path := frame.File
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			body.StackFrames[n].Source = &Source{Name: filepath.Base(frame.File), Path: path}
+		}
+	}
+	return body, nil
+}
+
+func (s *Server) scopes(req *request, pause *interpreter.Pause) (interface{}, error) {
+	var args ScopesArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+	scopes, err := pause.Scopes(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	body := &ScopesResponseBody{Scopes: make([]Scope, len(scopes))}
+	for n, scope := range scopes {
+		s.references = append(s.references, scope.Variables)
+		body.Scopes[n] = Scope{Name: scope.Name, VariablesReference: len(s.references)}
+	}
+	return body, nil
+}
+
+func (s *Server) variables(req *request, pause *interpreter.Pause) (interface{}, error) {
+	var args VariablesArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+	if args.VariablesReference < 1 || args.VariablesReference > len(s.references) {
+		return nil, fmt.Errorf("Invalid variablesReference %d.", args.VariablesReference)
+	}
+	variables := s.references[args.VariablesReference-1]
+	body := &VariablesResponseBody{Variables: make([]Variable, len(variables))}
+	for n, variable := range variables {
+		body.Variables[n] = Variable{Name: variable.Name, Value: interpreter.DebugString(variable.Value)}
+	}
+	return body, nil
+}
+
+func (s *Server) evaluate(req *request, pause *interpreter.Pause) (interface{}, error) {
+	var args EvaluateArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+	value, err := pause.Evaluate(args.FrameID, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return &EvaluateResponseBody{Result: interpreter.DebugString(value)}, nil
+}
+
+// 程序的输出作为output事件发送
+type output struct {
+	server *Server
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.server.event("output", &OutputEventBody{Category: "stdout", Output: string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,223 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/WAY29/LoxGo/framing"
+)
+
+// 通过管道与调试适配器交换消息的客户端
+type client struct {
+	t        *testing.T
+	in       io.Writer
+	messages chan *message // 在单独的goroutine中读取, 避免双方同时写入管道时阻塞
+	seq      int
+	output   string
+}
+
+// 收到的回复或事件
+type message struct {
+	Type       string
+	Event      string
+	RequestSeq int `json:"request_seq"`
+	Success    bool
+	Message    string
+	Body       json.RawMessage
+}
+
+func startServer(t *testing.T) (*client, chan error) {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+	}()
+	messages := make(chan *message, 64)
+	go func() {
+		defer close(messages)
+		out := bufio.NewReader(clientIn)
+		for {
+			body, err := framing.Read(out)
+			if err != nil {
+				return
+			}
+			var msg message
+			if json.Unmarshal(body, &msg) == nil {
+				messages <- &msg
+			}
+		}
+	}()
+	return &client{t: t, in: clientOut, messages: messages}, done
+}
+
+func (c *client) read() *message {
+	msg, ok := <-c.messages
+	if !ok {
+		c.t.Fatal("connection closed")
+	}
+	if msg.Event == "output" {
+		var output OutputEventBody
+		json.Unmarshal(msg.Body, &output)
+		c.output += output.Output
+	}
+	return msg
+}
+
+// 发送请求并等待回复, 期间收到的事件被忽略
+func (c *client) call(command string, arguments interface{}, result interface{}) {
+	c.seq++
+	req := map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": arguments}
+	if err := framing.Write(c.in, req); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg := c.read()
+		if msg.Type != "response" || msg.RequestSeq != c.seq {
+			continue
+		}
+		if !msg.Success {
+			c.t.Fatalf("%s: %s", command, msg.Message)
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Body, result); err != nil {
+				c.t.Fatalf("%s: %v", command, err)
+			}
+		}
+		return
+	}
+}
+
+// 等待指定的事件
+func (c *client) wait(name string) *message {
+	for {
+		if msg := c.read(); msg.Type == "event" && msg.Event == name {
+			return msg
+		}
+	}
+}
+
+// 等待暂停并返回最内层帧的函数与行
+func (c *client) stopped(reason string) StackFrame {
+	var body StoppedEventBody
+	json.Unmarshal(c.wait("stopped").Body, &body)
+	if body.Reason != reason {
+		c.t.Fatalf("expected to stop on %s but got %s", reason, body.Reason)
+	}
+	var trace StackTraceResponseBody
+	c.call("stackTrace", map[string]interface{}{"threadId": threadID}, &trace)
+	return trace.StackFrames[0]
+}
+
+const testSource = `fun add(a, b) {
+    var sum = a + b;
+    return sum;
+}
+var total = add(1, 2);
+print total;
+`
+
+func TestServer(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "main.lox")
+	if err := ioutil.WriteFile(program, []byte(testSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, done := startServer(t)
+
+	var capabilities Capabilities
+	c.call("initialize", map[string]interface{}{"adapterID": "lox"}, &capabilities)
+	if !capabilities.SupportsConfigurationDoneRequest {
+		t.Fatalf("unexpected capabilities %+v", capabilities)
+	}
+	c.call("launch", &LaunchArguments{Program: program, StopOnEntry: true}, nil)
+	c.call("setBreakpoints", &SetBreakpointsArguments{Source: Source{Path: program}, Breakpoints: []SourceBreakpoint{{Line: 3}}}, nil)
+	c.call("configurationDone", nil, nil)
+
+	if frame := c.stopped("entry"); frame.Name != "<script>" || frame.Line != 1 {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+	c.call("next", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped("step")
+	c.call("stepIn", map[string]interface{}{"threadId": threadID}, nil)
+	if frame := c.stopped("step"); frame.Name != "add" || frame.Line != 2 {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+	c.call("continue", map[string]interface{}{"threadId": threadID}, nil)
+	if frame := c.stopped("breakpoint"); frame.Name != "add" || frame.Line != 3 {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+
+	var scopes ScopesResponseBody
+	c.call("scopes", &ScopesArguments{FrameID: 0}, &scopes)
+	if len(scopes.Scopes) != 3 || scopes.Scopes[2].Name != "Global" {
+		t.Fatalf("unexpected scopes %+v", scopes)
+	}
+	var variables VariablesResponseBody
+	c.call("variables", &VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &variables)
+	if len(variables.Variables) != 1 || variables.Variables[0] != (Variable{Name: "sum", Value: "3"}) {
+		t.Errorf("unexpected variables %+v", variables)
+	}
+	var result EvaluateResponseBody
+	c.call("evaluate", &EvaluateArguments{Expression: "sum * 10 + a", FrameID: 0}, &result)
+	if result.Result != "31" {
+		t.Errorf("unexpected result %s", result.Result)
+	}
+
+	c.call("stepOut", map[string]interface{}{"threadId": threadID}, nil)
+	if frame := c.stopped("step"); frame.Name != "<script>" || frame.Line != 6 {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+	c.call("continue", map[string]interface{}{"threadId": threadID}, nil)
+	var exited ExitedEventBody
+	json.Unmarshal(c.wait("exited").Body, &exited)
+	c.wait("terminated")
+	if exited.ExitCode != 0 || c.output != "3\n" {
+		t.Errorf("unexpected exit code %d and output %q", exited.ExitCode, c.output)
+	}
+
+	c.call("disconnect", nil, nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 运行中断开连接时终止程序
+func TestServerDisconnect(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "loop.lox")
+	if err := ioutil.WriteFile(program, []byte("while (true) {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, done := startServer(t)
+	c.call("initialize", nil, nil)
+	c.call("launch", &LaunchArguments{Program: program}, nil)
+	c.call("configurationDone", nil, nil)
+	c.call("pause", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped("pause")
+	c.call("continue", map[string]interface{}{"threadId": threadID}, nil)
+	c.call("disconnect", nil, nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 无法解析的消息不会中断服务
+func TestServerInvalidMessage(t *testing.T) {
+	c, done := startServer(t)
+	if _, err := io.WriteString(c.in, "Content-Length: 5\r\n\r\n{bad}"); err != nil {
+		t.Fatal(err)
+	}
+	if output := c.wait("output"); !strings.Contains(c.output, "Invalid message") {
+		t.Errorf("unexpected output %s", output.Body)
+	}
+	c.call("initialize", nil, nil)
+	c.call("disconnect", nil, nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
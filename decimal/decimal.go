@@ -0,0 +1,244 @@
+// Package decimal 实现十进制定点小数, 用于货币等不能接受浮点数舍入误差的场景
+package decimal
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 除法除不尽时保留的小数位数
+const DivisionScale = 20
+
+var ErrDivisionByZero = errors.New("Division by zero.")
+
+var bigTen = big.NewInt(10)
+
+// 定点小数, 数值为 value * 10^-scale, 运算不修改操作数, 可以安全共享
+type Decimal struct {
+	value *big.Int
+	scale int
+}
+
+func New(value *big.Int, scale int) *Decimal {
+	if scale < 0 {
+		value = new(big.Int).Mul(value, pow10(-scale))
+		scale = 0
+	}
+	return &Decimal{value: value, scale: scale}
+}
+
+func FromInt(v int) *Decimal {
+	return New(big.NewInt(int64(v)), 0)
+}
+
+func FromBig(v *big.Int) *Decimal {
+	return New(v, 0)
+}
+
+// 按最短表示转换浮点数, decimal(0.1)的结果为0.1而不是二进制近似值
+func FromFloat(f float64) (*Decimal, error) {
+	return Parse(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// 解析 -12.345, 1.5e3 形式的字符串
+func Parse(s string) (*Decimal, error) {
+	str := s
+	exponent := 0
+	if n := strings.IndexAny(str, "eE"); n >= 0 {
+		e, err := strconv.Atoi(str[n+1:])
+		if err != nil {
+			return nil, errors.New("invalid decimal " + s)
+		}
+		exponent = e
+		str = str[:n]
+	}
+	scale := 0
+	if n := strings.IndexByte(str, '.'); n >= 0 {
+		scale = len(str) - n - 1
+		str = str[:n] + str[n+1:]
+	}
+	if str == "" || str == "+" || str == "-" || strings.ContainsAny(str[1:], "+-") {
+		return nil, errors.New("invalid decimal " + s)
+	}
+	value, ok := new(big.Int).SetString(str, 10)
+	if !ok {
+		return nil, errors.New("invalid decimal " + s)
+	}
+	return New(value, scale-exponent), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// 小数位数
+func (d *Decimal) Scale() int {
+	return d.scale
+}
+
+func (d *Decimal) Sign() int {
+	return d.value.Sign()
+}
+
+// 调整到更大的小数位数, 数值不变
+func (d *Decimal) rescale(scale int) *big.Int {
+	if scale == d.scale {
+		return d.value
+	}
+	return new(big.Int).Mul(d.value, pow10(scale-d.scale))
+}
+
+// 对齐两个数的小数位数
+func align(a, b *Decimal) (*big.Int, *big.Int, int) {
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return a.rescale(scale), b.rescale(scale), scale
+}
+
+func (d *Decimal) Neg() *Decimal {
+	return New(new(big.Int).Neg(d.value), d.scale)
+}
+
+func (d *Decimal) Add(o *Decimal) *Decimal {
+	a, b, scale := align(d, o)
+	return New(new(big.Int).Add(a, b), scale)
+}
+
+func (d *Decimal) Sub(o *Decimal) *Decimal {
+	a, b, scale := align(d, o)
+	return New(new(big.Int).Sub(a, b), scale)
+}
+
+func (d *Decimal) Mul(o *Decimal) *Decimal {
+	return New(new(big.Int).Mul(d.value, o.value), d.scale+o.scale)
+}
+
+// 除法, 除不尽时保留DivisionScale位小数并四舍五入, 再去掉多余的0, 但至少保留两个操作数中较大的小数位数
+func (d *Decimal) Quo(o *Decimal) (*Decimal, error) {
+	if o.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	minScale := d.scale
+	if o.scale > minScale {
+		minScale = o.scale
+	}
+	scale := minScale
+	if scale < DivisionScale {
+		scale = DivisionScale
+	}
+	// d / o = (d.value * 10^(scale - d.scale + o.scale)) / o.value * 10^-scale
+	numerator := new(big.Int).Mul(d.value, pow10(scale-d.scale+o.scale))
+	quotient, remainder := new(big.Int).QuoRem(numerator, o.value, new(big.Int))
+	if roundUp(remainder, o.value) {
+		quotient.Add(quotient, big.NewInt(int64(numerator.Sign()*o.value.Sign())))
+	}
+	return New(quotient, scale).trim(minScale), nil
+}
+
+// 取余, 结果的符号与被除数相同
+func (d *Decimal) Rem(o *Decimal) (*Decimal, error) {
+	if o.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	a, b, scale := align(d, o)
+	return New(new(big.Int).Rem(a, b), scale), nil
+}
+
+// 整数次幂, 指数为负数时按除法计算
+func (d *Decimal) Pow(n int) (*Decimal, error) {
+	if n < 0 {
+		p, err := d.Pow(-n)
+		if err != nil {
+			return nil, err
+		}
+		return FromInt(1).Quo(p)
+	}
+	return New(new(big.Int).Exp(d.value, big.NewInt(int64(n)), nil), d.scale*n), nil
+}
+
+func (d *Decimal) Cmp(o *Decimal) int {
+	a, b, _ := align(d, o)
+	return a.Cmp(b)
+}
+
+// 余数的两倍不小于除数时进位, 即四舍五入
+func roundUp(remainder, divisor *big.Int) bool {
+	if remainder.Sign() == 0 {
+		return false
+	}
+	double := new(big.Int).Abs(remainder)
+	double.Lsh(double, 1)
+	return double.CmpAbs(divisor) >= 0
+}
+
+// 四舍五入到指定的小数位数, 小数位数不足时补0, 如 Round(1.005, 2) = 1.01, Round(1.5, 2) = 1.50
+func (d *Decimal) Round(places int) *Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return New(d.rescale(places), places)
+	}
+	divisor := pow10(d.scale - places)
+	quotient, remainder := new(big.Int).QuoRem(d.value, divisor, new(big.Int))
+	if roundUp(remainder, divisor) {
+		quotient.Add(quotient, big.NewInt(int64(d.value.Sign())))
+	}
+	return New(quotient, places)
+}
+
+// 去掉小数末尾的0, 最少保留minScale位小数
+func (d *Decimal) trim(minScale int) *Decimal {
+	value, scale := d.value, d.scale
+	remainder := new(big.Int)
+	for scale > minScale {
+		quotient, _ := new(big.Int).QuoRem(value, bigTen, remainder)
+		if remainder.Sign() != 0 {
+			break
+		}
+		value, scale = quotient, scale-1
+	}
+	return New(value, scale)
+}
+
+// 去掉小数末尾所有的0, 数值相等的小数得到相同的结果
+func (d *Decimal) Normalize() *Decimal {
+	return d.trim(0)
+}
+
+// 是否没有小数部分
+func (d *Decimal) IsInt() bool {
+	return d.Normalize().scale == 0
+}
+
+// 向零取整
+func (d *Decimal) Int() *big.Int {
+	return new(big.Int).Quo(d.value, pow10(d.scale))
+}
+
+func (d *Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.value, pow10(d.scale))
+}
+
+func (d *Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+func (d *Decimal) String() string {
+	str := new(big.Int).Abs(d.value).String()
+	if d.scale > 0 {
+		if len(str) <= d.scale {
+			str = strings.Repeat("0", d.scale-len(str)+1) + str
+		}
+		str = str[:len(str)-d.scale] + "." + str[len(str)-d.scale:]
+	}
+	if d.value.Sign() < 0 {
+		str = "-" + str
+	}
+	return str
+}
@@ -0,0 +1,105 @@
+package decimal
+
+import "testing"
+
+func mustParse(t *testing.T, s string) *Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return d
+}
+
+func TestParse(t *testing.T) {
+	testCases := map[string]string{
+		"0":       "0",
+		"1.50":    "1.50",
+		"-0.05":   "-0.05",
+		"+3":      "3",
+		".5":      "0.5",
+		"1.5e3":   "1500",
+		"1.5e-3":  "0.0015",
+		"12.3E+1": "123",
+	}
+	for source, expected := range testCases {
+		if got := mustParse(t, source).String(); got != expected {
+			t.Errorf("%s: expected %s, but got %s", source, expected, got)
+		}
+	}
+
+	for _, source := range []string{"", "-", "1.2.3", "abc", "1e", "1-2"} {
+		if _, err := Parse(source); err == nil {
+			t.Errorf("%s: expected error", source)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := mustParse(t, "0.1"), mustParse(t, "0.20")
+	testCases := []struct {
+		got      *Decimal
+		expected string
+	}{
+		{a.Add(b), "0.30"},
+		{a.Sub(b), "-0.10"},
+		{a.Mul(b), "0.020"},
+		{mustParse(t, "19.99").Mul(FromInt(3)), "59.97"},
+		{mustParse(t, "1.005").Round(2), "1.01"},
+		{mustParse(t, "-1.005").Round(2), "-1.01"},
+		{mustParse(t, "1.5").Round(2), "1.50"},
+		{mustParse(t, "2.5").Round(0), "3"},
+		{mustParse(t, "1.500").Normalize(), "1.5"},
+	}
+	for n, testCase := range testCases {
+		if got := testCase.got.String(); got != testCase.expected {
+			t.Errorf("case %d: expected %s, but got %s", n, testCase.expected, got)
+		}
+	}
+
+	quotients := [][3]string{
+		{"10.00", "4", "2.50"},
+		{"1", "3", "0.33333333333333333333"},
+		{"-2", "3", "-0.66666666666666666667"},
+		{"7.5", "-2.5", "-3.0"},
+	}
+	for _, q := range quotients {
+		got, err := mustParse(t, q[0]).Quo(mustParse(t, q[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != q[2] {
+			t.Errorf("%s / %s: expected %s, but got %s", q[0], q[1], q[2], got)
+		}
+	}
+	if _, err := a.Quo(FromInt(0)); err != ErrDivisionByZero {
+		t.Errorf("expected division by zero, but got %v", err)
+	}
+
+	if r, _ := mustParse(t, "-7.5").Rem(FromInt(2)); r.String() != "-1.5" {
+		t.Errorf("expected -1.5, but got %s", r)
+	}
+	if p, _ := mustParse(t, "1.1").Pow(2); p.String() != "1.21" {
+		t.Errorf("expected 1.21, but got %s", p)
+	}
+	if p, _ := FromInt(2).Pow(-2); p.String() != "0.25" {
+		t.Errorf("expected 0.25, but got %s", p)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	if mustParse(t, "1.10").Cmp(mustParse(t, "1.1")) != 0 {
+		t.Error("expected 1.10 == 1.1")
+	}
+	if !mustParse(t, "3.00").IsInt() || mustParse(t, "3.01").IsInt() {
+		t.Error("IsInt failed")
+	}
+	if got := mustParse(t, "-3.99").Int().String(); got != "-3" {
+		t.Errorf("expected -3, but got %s", got)
+	}
+	if got := mustParse(t, "0.25").Float64(); got != 0.25 {
+		t.Errorf("expected 0.25, but got %v", got)
+	}
+	if d, _ := FromFloat(0.1); d.String() != "0.1" {
+		t.Errorf("expected 0.1, but got %s", d)
+	}
+}
@@ -0,0 +1,52 @@
+// Package framing 读写以Content-Length头部分隔的JSON消息, 语言服务器与调试适配器使用相同的格式
+package framing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 读取一条消息的内容
+func Read(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if n := strings.IndexByte(line, ':'); n > 0 && strings.EqualFold(strings.TrimSpace(line[:n]), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[n+1:])); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %s", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// 将消息编码为JSON后写入
+func Write(w io.Writer, message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
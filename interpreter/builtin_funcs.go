@@ -2,27 +2,44 @@ package interpreter
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"strconv"
 	"time"
+
+	"github.com/WAY29/LoxGo/decimal"
 )
 
-func _clock(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+func _clock(caller Caller, arguments []interface{}) (interface{}, error) {
 	return time.Now().Unix(), nil
 }
 
-func _type(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+func _type(caller Caller, arguments []interface{}) (interface{}, error) {
+	switch arguments[0].(type) {
+	case *big.Int:
+		return "bigint", nil
+	case *decimal.Decimal:
+		return "decimal", nil
+	}
 	return fmt.Sprintf("%T", arguments[0]), nil
 }
 
-func _bool(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+func _bool(caller Caller, arguments []interface{}) (interface{}, error) {
 	return isTruthy(arguments[0]), nil
 }
 
-func _int(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+func _int(caller Caller, arguments []interface{}) (interface{}, error) {
 	value := arguments[0]
 	switch v := value.(type) {
 	case string:
-		return strconv.Atoi(v)
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			// 超出64位整数范围时使用大整数
+			if b, ok := new(big.Int).SetString(v, 10); ok {
+				return b, nil
+			}
+		}
+		return i, err
 	case bool:
 		if v {
 			return 1, nil
@@ -30,14 +47,28 @@ func _int(interpreter *Interpreter, arguments []interface{}) (interface{}, error
 			return 0, nil
 		}
 	case int64:
-		return v, nil
+		return int(v), nil
 	case int:
 		return v, nil
+	case float64:
+		// 向零取整, 超出整数范围时转换为大整数
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, NewConvertError(value, "int", "")
+		}
+		if v >= -float64(math.MinInt) || v < float64(math.MinInt) {
+			b, _ := big.NewFloat(v).Int(nil)
+			return b, nil
+		}
+		return int(v), nil
+	case *big.Int:
+		return NormalizeInt(v), nil
+	case *decimal.Decimal:
+		return NormalizeInt(v.Int()), nil
 	}
 	return nil, NewConvertError(value, "int", "")
 }
 
-func _float(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+func _float(caller Caller, arguments []interface{}) (interface{}, error) {
 	value := arguments[0]
 	switch v := value.(type) {
 	case string:
@@ -50,35 +81,135 @@ func _float(interpreter *Interpreter, arguments []interface{}) (interface{}, err
 		return value, nil
 	case float64:
 		return value, nil
+	case *big.Int:
+		return bigToFloat(v), nil
+	case *decimal.Decimal:
+		return v.Float64(), nil
 	}
 	return nil, NewConvertError(value, "float", "")
 }
 
-func _string(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
-	return fmt.Sprintf("%v", arguments[0]), nil
+func _bigint(caller Caller, arguments []interface{}) (interface{}, error) {
+	value := arguments[0]
+	switch v := value.(type) {
+	case string:
+		// 支持 0x 等进制前缀与_分隔符
+		if b, ok := new(big.Int).SetString(v, 0); ok {
+			return b, nil
+		}
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			b, _ := big.NewFloat(v).Int(nil)
+			return b, nil
+		}
+	case *decimal.Decimal:
+		return v.Int(), nil
+	default:
+		if b := toBig(value); b != nil {
+			return b, nil
+		}
+	}
+	return nil, NewConvertError(value, "bigint", "")
 }
 
-func _array(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+func _decimal(caller Caller, arguments []interface{}) (interface{}, error) {
+	value := arguments[0]
+	switch v := value.(type) {
+	case string:
+		if d, err := decimal.Parse(v); err == nil {
+			return d, nil
+		}
+	case float64:
+		if d, err := decimal.FromFloat(v); err == nil {
+			return d, nil
+		}
+	default:
+		if d := toDecimal(value); d != nil {
+			return d, nil
+		}
+	}
+	return nil, NewConvertError(value, "decimal", "")
+}
+
+// 四舍五入到指定的小数位数, 定点小数不足的位数补0, 如 round(1.5d, 2) 为 1.50
+func _round(caller Caller, arguments []interface{}) (interface{}, error) {
+	places := arguments[1].(int)
+	switch v := arguments[0].(type) {
+	case *decimal.Decimal:
+		return v.Round(places), nil
+	case float64:
+		p := math.Pow(10, float64(places))
+		return math.Round(v*p) / p, nil
+	}
+	return arguments[0], nil
+}
+
+func _string(caller Caller, arguments []interface{}) (interface{}, error) {
+	return ToString(arguments[0]), nil
+}
+
+func _array(caller Caller, arguments []interface{}) (interface{}, error) {
 	return arguments, nil
 }
 
-func _len(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
-	if array, ok := arguments[0].([]interface{}); !ok {
-		return nil, NewRuntimeError(nil, "Can't get length of %s[%T]", arguments[0], arguments[0])
-	} else {
-		return len(array), nil
+func _len(caller Caller, arguments []interface{}) (interface{}, error) {
+	switch v := arguments[0].(type) {
+	case []interface{}:
+		return len(v), nil
+	case *LoxMap:
+		return v.Len(), nil
+	case goContainer:
+		return v.Len(), nil
+	}
+	return nil, NewRuntimeError(nil, "Can't get length of %v[%T].", arguments[0], arguments[0])
+}
+
+func _has(caller Caller, arguments []interface{}) (interface{}, error) {
+	return arguments[0].(*LoxMap).Has(arguments[1])
+}
+
+func _keys(caller Caller, arguments []interface{}) (interface{}, error) {
+	return arguments[0].(*LoxMap).Keys(), nil
+}
+
+func _values(caller Caller, arguments []interface{}) (interface{}, error) {
+	return arguments[0].(*LoxMap).Values(), nil
+}
+
+func _delete(caller Caller, arguments []interface{}) (interface{}, error) {
+	return arguments[0].(*LoxMap).Delete(arguments[1])
+}
+
+// 向零取整的整数除法
+func _div(caller Caller, arguments []interface{}) (interface{}, error) {
+	return IntDiv(arguments[0], arguments[1])
+}
+
+func NewBuiltinFuncs() []*LoxBuiltinFunc {
+	return []*LoxBuiltinFunc{
+		NewLoxBuiltinFunc(_clock, "clock", 0),
+		NewLoxBuiltinFunc(_type, "type", 1),
+		NewLoxBuiltinFunc(_int, "int", 1),
+		NewLoxBuiltinFunc(_float, "float", 1),
+		NewLoxBuiltinFunc(_bigint, "bigint", 1),
+		NewLoxBuiltinFunc(_decimal, "decimal", 1),
+		NewLoxBuiltinFunc(_bool, "bool", 1),
+		NewLoxBuiltinFunc(_string, "string", 1),
+		NewLoxBuiltinFunc(_array, "array", -1),
+		NewLoxBuiltinFunc(_len, "len", 1),
+		NewLoxBuiltinFunc(_div, "div", 2),
+		NewTypedLoxBuiltinFunc(_round, "round", ArgNumber, ArgInt),
+		NewTypedLoxBuiltinFunc(_has, "has", ArgMap, ArgAny),
+		NewTypedLoxBuiltinFunc(_keys, "keys", ArgMap),
+		NewTypedLoxBuiltinFunc(_values, "values", ArgMap),
+		NewTypedLoxBuiltinFunc(_delete, "delete", ArgMap, ArgAny),
 	}
 }
 
 func NewBuiltinEnvironments() *Environment {
 	globals := NewEnvironment(nil)
-	globals.define("clock", NewLoxBuiltinFunc(_clock, "clock", 0))
-	globals.define("type", NewLoxBuiltinFunc(_type, "type", 1))
-	globals.define("int", NewLoxBuiltinFunc(_int, "int", 1))
-	globals.define("float", NewLoxBuiltinFunc(_float, "float", 1))
-	globals.define("bool", NewLoxBuiltinFunc(_bool, "bool", 1))
-	globals.define("string", NewLoxBuiltinFunc(_string, "string", 1))
-	globals.define("array", NewLoxBuiltinFunc(_array, "array", -1))
-	globals.define("len", NewLoxBuiltinFunc(_len, "len", 1))
+	for _, function := range NewBuiltinFuncs() {
+		globals.define(function.name, function)
+	}
 	return globals
 }
@@ -1,14 +1,22 @@
 package interpreter
 
+import "github.com/WAY29/LoxGo/lexer"
+
 type LoxClass struct { // impl LoxCallable
-	className string
-	methods   map[string]*LoxCustomFunc
+	className    string
+	superclass   *LoxClass
+	methods      map[string]*LoxCustomFunc
+	classMethods map[string]*LoxCustomFunc
+	fields       map[string]interface{}
 }
 
-func NewLoxClass(name string, methods map[string]*LoxCustomFunc) *LoxClass {
+func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxCustomFunc, classMethods map[string]*LoxCustomFunc) *LoxClass {
 	return &LoxClass{
-		className: name,
-		methods:   methods,
+		className:    name,
+		superclass:   superclass,
+		methods:      methods,
+		classMethods: classMethods,
+		fields:       make(map[string]interface{}),
 	}
 }
 
@@ -16,22 +24,32 @@ func (c *LoxClass) String() string {
 	return c.className
 }
 
-func (c *LoxClass) arity() int {
+func (c *LoxClass) Arity() int {
 	initializer := c.findMethod("init")
 	if initializer == nil {
 		return 0
 	}
-	return initializer.arity()
+	return initializer.Arity()
+}
+
+func (c *LoxClass) ArityRange() (int, int) {
+	initializer := c.findMethod("init")
+	if initializer == nil {
+		return 0, 0
+	}
+	return initializer.ArityRange()
 }
 
-func (c *LoxClass) call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+func (c *LoxClass) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
 	instance := NewLoxInstance(c)
 	initializer := c.findMethod("init")
 	if initializer != nil {
-		initializer.bind(instance).call(interpreter, arguments)
+		if _, err := initializer.bind(instance).Call(interpreter, arguments); err != nil {
+			return nil, err
+		}
 	}
 
-	return NewLoxInstance(c), nil
+	return instance, nil
 }
 
 func (c *LoxClass) Name() string {
@@ -43,5 +61,39 @@ func (c *LoxClass) findMethod(name string) (result *LoxCustomFunc) {
 	if result, ok = c.methods[name]; ok {
 		return
 	}
+	// 在父类中查找方法
+	if c.superclass != nil {
+		return c.superclass.findMethod(name)
+	}
 	return nil
 }
+
+func (c *LoxClass) findClassMethod(name string) (result *LoxCustomFunc) {
+	var ok bool
+	if result, ok = c.classMethods[name]; ok {
+		return
+	}
+	if c.superclass != nil {
+		return c.superclass.findClassMethod(name)
+	}
+	return nil
+}
+
+func (c *LoxClass) get(name *lexer.Token) (interface{}, error) {
+	for class := c; class != nil; class = class.superclass {
+		if result, ok := class.fields[name.GetValue()]; ok {
+			return result, nil
+		}
+	}
+	// 静态方法中的this指向类本身
+	method := c.findClassMethod(name.GetValue())
+	if method != nil {
+		return method.bind(c), nil
+	}
+
+	return nil, NewRuntimeError(name, "Undefined class property '%s'.", name.GetValue())
+}
+
+func (c *LoxClass) set(name *lexer.Token, value interface{}) {
+	c.fields[name.GetValue()] = value
+}
@@ -5,4 +5,5 @@ type ClassType uint8
 const (
 	ClassTypeNone ClassType = iota
 	ClassTypeClass
+	ClassTypeSubclass
 )
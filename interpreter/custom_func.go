@@ -26,10 +26,29 @@ func NewLoxCustomFunc(declaration *parser.Function, parentEnviron *Environment,
 	}
 }
 
-func (f *LoxCustomFunc) call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+func (f *LoxCustomFunc) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
 	environ := NewEnvironment(f.parentEnvironment)
-	for i, param := range f.declaration.Params {
-		environ.define(param.GetValue(), arguments[i])
+	for n, param := range f.declaration.Params {
+		if n < len(arguments) {
+			environ.define(param.GetValue(), arguments[n])
+			continue
+		}
+		// 缺少的参数在调用时于函数作用域中求默认值, 默认值可以引用之前的参数
+		var value interface{}
+		if defaultValue := f.declaration.Defaults[n]; defaultValue != nil {
+			var err error
+			if value, err = interpreter.evaluateIn(defaultValue, environ); err != nil {
+				return nil, err
+			}
+		}
+		environ.define(param.GetValue(), value)
+	}
+	if f.declaration.Rest != nil {
+		rest := make([]interface{}, 0)
+		if len(arguments) > len(f.declaration.Params) {
+			rest = append(rest, arguments[len(f.declaration.Params):]...)
+		}
+		environ.define(f.declaration.Rest.GetValue(), rest)
 	}
 
 	closure := NewEnvironment(environ)
@@ -37,12 +56,42 @@ func (f *LoxCustomFunc) call(interpreter *Interpreter, arguments []interface{})
 		return nil, NewRuntimeError(nil, "Func %s body invalid.", f.declaration.Name.GetValue())
 	} else {
 		result, err := interpreter.executeBlock(block, closure)
-		return result, err
+		if signal, ok := err.(*ReturnSignal); ok {
+			result, err = signal.value, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 构造函数总是返回this
+		if f.isInitializer {
+			return f.parentEnvironment.getAt(0, "this")
+		}
+		return result, nil
+	}
+}
+
+// 有默认值或剩余参数时参数个数不固定, 返回-1
+func (f *LoxCustomFunc) Arity() int {
+	if min, max := f.ArityRange(); min == max {
+		return min
 	}
+	return -1
 }
 
-func (f *LoxCustomFunc) arity() int {
-	return len(f.declaration.Params)
+func (f *LoxCustomFunc) ArityRange() (int, int) {
+	return declarationArity(f.declaration)
+}
+
+// 根据声明得到参数个数的范围, 有默认值的参数可以省略
+func declarationArity(declaration *parser.Function) (int, int) {
+	min := 0
+	for min < len(declaration.Defaults) && declaration.Defaults[min] == nil {
+		min++
+	}
+	if declaration.Rest != nil {
+		return min, -1
+	}
+	return min, len(declaration.Params)
 }
 
 func (f *LoxCustomFunc) String() string {
@@ -53,8 +102,8 @@ func (f *LoxCustomFunc) Name() string {
 	return f.name
 }
 
-func (f *LoxCustomFunc) bind(instance *LoxInstance) *LoxCustomFunc {
+func (f *LoxCustomFunc) bind(this interface{}) *LoxCustomFunc {
 	environ := NewEnvironment(f.parentEnvironment)
-	environ.define("this", instance)
+	environ.define("this", this)
 	return NewLoxCustomFunc(f.declaration, environ, f.isInitializer)
 }
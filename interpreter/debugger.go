@@ -0,0 +1,381 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/WAY29/LoxGo/parser"
+)
+
+// 暂停后继续执行的方式
+type StepMode uint8
+
+const (
+	StepContinue StepMode = iota // 运行到下一个断点
+	StepIn                       // 在下一行暂停, 包括进入被调用的函数
+	StepOver                     // 在当前函数或调用者的下一行暂停
+	StepOut                      // 返回调用者后暂停
+)
+
+// 暂停的原因
+type StopReason string
+
+const (
+	StopEntry      StopReason = "entry"
+	StopBreakpoint StopReason = "breakpoint"
+	StopStep       StopReason = "step"
+	StopPause      StopReason = "pause"
+)
+
+// 暂停时调用, 返回继续执行的方式, 返回错误时终止程序
+type DebugHandler func(pause *Pause) (StepMode, error)
+
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+// 调试器, 在执行每条语句前检查断点与单步状态, 需要暂停时调用handler
+type Debugger struct {
+	interpreter *Interpreter
+	main        string // 主程序的文件名, 模块的文件名记录在其全局环境中
+	handler     DebugHandler
+
+	mu          sync.Mutex
+	breakpoints map[string]map[int]string // 规范化的路径 -> 行 -> 设置时使用的文件名
+
+	mode  StepMode
+	depth uint64 // 开始单步时的调用栈深度
+	entry bool
+
+	// 上一条执行的语句, 同一行的多条语句只暂停一次
+	last      parser.Stmt
+	lastFile  string
+	lastLine  int
+	lastDepth uint64
+
+	paused     bool // 暂停时对表达式求值不再触发断点
+	pause      int32
+	terminated int32
+}
+
+func NewDebugger(interpreter *Interpreter, main string, handler DebugHandler) *Debugger {
+	return &Debugger{
+		interpreter: interpreter,
+		main:        main,
+		handler:     handler,
+		breakpoints: make(map[string]map[int]string),
+	}
+}
+
+// 在执行第一条语句前暂停
+func (d *Debugger) StopOnEntry() {
+	d.mode = StepIn
+	d.entry = true
+}
+
+func normalizePath(file string) string {
+	if path, err := filepath.Abs(file); err == nil {
+		return path
+	}
+	return filepath.Clean(file)
+}
+
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := normalizePath(file)
+	if d.breakpoints[path] == nil {
+		d.breakpoints[path] = make(map[int]string)
+	}
+	d.breakpoints[path][line] = file
+}
+
+// 删除断点, 断点不存在时返回false
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := d.breakpoints[normalizePath(file)]
+	if _, ok := lines[line]; !ok {
+		return false
+	}
+	delete(lines, line)
+	return true
+}
+
+// 替换文件中的所有断点
+func (d *Debugger) SetBreakpoints(file string, lines []int) {
+	d.mu.Lock()
+	delete(d.breakpoints, normalizePath(file))
+	d.mu.Unlock()
+
+	for _, line := range lines {
+		d.SetBreakpoint(file, line)
+	}
+}
+
+// 按文件与行排列的所有断点
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	breakpoints := make([]Breakpoint, 0)
+	for _, lines := range d.breakpoints {
+		for line, file := range lines {
+			breakpoints = append(breakpoints, Breakpoint{File: file, Line: line})
+		}
+	}
+	sort.Slice(breakpoints, func(i, j int) bool {
+		if breakpoints[i].File != breakpoints[j].File {
+			return breakpoints[i].File < breakpoints[j].File
+		}
+		return breakpoints[i].Line < breakpoints[j].Line
+	})
+	return breakpoints
+}
+
+func (d *Debugger) hasBreakpoint(file string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.breakpoints[normalizePath(file)][line]
+	return ok
+}
+
+// 在执行下一条语句前暂停, 可以在其他goroutine中调用
+func (d *Debugger) Pause() {
+	atomic.StoreInt32(&d.pause, 1)
+}
+
+// 在执行下一条语句时终止程序, 可以在其他goroutine中调用
+func (d *Debugger) Terminate() {
+	atomic.StoreInt32(&d.terminated, 1)
+}
+
+// 环境所属的文件
+func (d *Debugger) file(environment *Environment) string {
+	if environment != nil && environment.globals != nil && environment.globals.name != "" {
+		return environment.globals.name
+	}
+	return d.main
+}
+
+func (d *Debugger) stepping(depth uint64) bool {
+	switch d.mode {
+	case StepIn:
+		return true
+	case StepOver:
+		return depth <= d.depth
+	case StepOut:
+		return depth < d.depth
+	}
+	return false
+}
+
+// 执行语句前调用
+func (d *Debugger) before(stmt parser.Stmt) error {
+	if atomic.LoadInt32(&d.terminated) == 1 {
+		return &TerminateSignal{}
+	}
+	if d.paused {
+		return nil
+	}
+	// 代码块中的语句会单独暂停, 只有暂停请求在代码块处生效以便中断空循环
+	if _, ok := stmt.(*parser.Block); ok && atomic.LoadInt32(&d.pause) == 0 {
+		return nil
+	}
+
+	file := d.file(d.interpreter.environment)
+	line := stmt.Span().Start.Line
+	depth := d.interpreter.stackSize
+	// 循环中再次执行同一条语句同样视为新的一行
+	newLine := stmt == d.last || line != d.lastLine || file != d.lastFile || depth != d.lastDepth
+	d.last, d.lastFile, d.lastLine, d.lastDepth = stmt, file, line, depth
+
+	var reason StopReason
+	switch {
+	case atomic.CompareAndSwapInt32(&d.pause, 1, 0):
+		reason = StopPause
+	case !newLine:
+		return nil
+	case d.entry:
+		reason = StopEntry
+	case d.hasBreakpoint(file, line):
+		reason = StopBreakpoint
+	case d.stepping(depth):
+		reason = StopStep
+	default:
+		return nil
+	}
+	d.entry = false
+
+	d.paused = true
+	mode, err := d.handler(&Pause{Reason: reason, Frames: d.frames(stmt), debugger: d})
+	d.paused = false
+	if err != nil {
+		d.Terminate()
+		return &TerminateSignal{}
+	}
+	d.mode, d.depth = mode, depth
+	return nil
+}
+
+// 调用栈中的一帧
+type Frame struct {
+	Function string
+	File     string
+	Line     int // 当前执行的位置, 由Go发起调用的帧为0
+	Column   int
+
+	environment *Environment
+}
+
+func (d *Debugger) frames(stmt parser.Stmt) []Frame {
+	frames := make([]Frame, 0)
+	environment := d.interpreter.environment
+	pos := stmt.Span().Start
+	for stack := d.interpreter.stack; ; stack = stack.parent {
+		frame := Frame{
+			Function:    "<script>",
+			File:        d.file(environment),
+			Line:        pos.Line,
+			Column:      pos.Column,
+			environment: environment,
+		}
+		if stack == nil || stack.callee == nil {
+			return append(frames, frame)
+		}
+		if frame.Function = stack.callee.Name(); frame.Function == "" {
+			frame.Function = "<fn>"
+		}
+		frames = append(frames, frame)
+
+		pos.Line, pos.Column = 0, 0
+		if stack.call != nil {
+			pos = stack.call.Span().Start
+		}
+		environment = stack.environment
+	}
+}
+
+// 作用域中的变量
+type DebugVariable struct {
+	Name  string
+	Value interface{}
+}
+
+// 一层环境中的变量
+type Scope struct {
+	Name      string
+	Variables []DebugVariable
+}
+
+// 暂停时的状态, 只在handler返回前有效
+type Pause struct {
+	Reason StopReason
+	Frames []Frame // 最内层的帧在前
+
+	debugger *Debugger
+}
+
+func (p *Pause) frame(n int) (*Frame, error) {
+	if n < 0 || n >= len(p.Frames) {
+		return nil, fmt.Errorf("Frame %d out of range.", n)
+	}
+	return &p.Frames[n], nil
+}
+
+// 帧中由内向外的各层局部作用域与全局作用域, 省略没有变量的局部作用域
+func (p *Pause) Scopes(n int) ([]Scope, error) {
+	frame, err := p.frame(n)
+	if err != nil {
+		return nil, err
+	}
+	scopes := make([]Scope, 0)
+	for environment := frame.environment; environment != nil; environment = environment.enclosing {
+		name := "Local"
+		if len(scopes) > 0 {
+			name = "Enclosing"
+		}
+		global := environment == environment.globals
+		if global {
+			name = "Global"
+		} else if len(environment.values) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(environment.values))
+		for name := range environment.values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		scope := Scope{Name: name, Variables: make([]DebugVariable, len(names))}
+		for i, name := range names {
+			scope.Variables[i] = DebugVariable{Name: name, Value: environment.values[name].Value}
+		}
+		scopes = append(scopes, scope)
+		if global {
+			break
+		}
+	}
+	return scopes, nil
+}
+
+// 在帧中对表达式求值, 可以访问帧中所有可见的变量
+func (p *Pause) Evaluate(n int, expression string) (result interface{}, err error) {
+	defer recoverError(&err)
+
+	frame, err := p.frame(n)
+	if err != nil {
+		return nil, err
+	}
+	expression = strings.TrimSuffix(strings.TrimSpace(expression), ";")
+	statements, err := parser.ParseSource("<watch>", strings.NewReader(expression+";"))
+	if err != nil {
+		return nil, err
+	}
+	stmt, ok := statements[0].(*parser.Expression)
+	if len(statements) != 1 || !ok {
+		return nil, errors.New("Expect an expression.")
+	}
+
+	// 以帧中的各层环境作为作用域进行静态解析
+	i := p.debugger.interpreter
+	resolver := NewResolver(i)
+	for environment := frame.environment; environment != nil && environment != environment.globals; environment = environment.enclosing {
+		scope := make(map[string]*binding)
+		for name := range environment.values {
+			scope[name] = &binding{kind: symbolInternal, defined: true, used: true}
+		}
+		if _, ok := scope["this"]; ok && resolver.classType == ClassTypeNone {
+			resolver.classType = ClassTypeClass
+		}
+		if _, ok := scope["super"]; ok {
+			resolver.classType = ClassTypeSubclass
+		}
+		resolver.scopes.PushFront(scope)
+	}
+	if err = resolver.resolveExpr(stmt.Expr); err != nil {
+		return nil, err
+	}
+	return i.evaluateIn(stmt.Expr, frame.environment)
+}
+
+// 调试器中显示的值, 字符串带有引号
+func DebugString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	}
+	return ToString(value)
+}
@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/WAY29/LoxGo/parser"
+)
+
+const debugSource = `var total = 0;
+fun add(a, b) {
+    var sum = a + b;
+    return sum;
+}
+for (var i = 0; i < 2; i = i + 1) {
+    total = add(total, i);
+}
+print total;
+`
+
+func TestDebugger(t *testing.T) {
+	statements, err := parser.ParseSource("main.lox", strings.NewReader(debugSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := NewInterpreter()
+	i.SetOutput(ioutil.Discard)
+	if err = NewResolver(i).ResolveStmts(statements); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每次暂停后依次执行的操作
+	modes := []StepMode{StepContinue, StepOver, StepOut, StepIn, StepIn, StepContinue}
+	stops := make([]string, 0)
+	debugger := NewDebugger(i, "main.lox", func(pause *Pause) (StepMode, error) {
+		frame := pause.Frames[0]
+		stops = append(stops, fmt.Sprintf("%s %s:%d", pause.Reason, frame.Function, frame.Line))
+		if len(stops) == 2 {
+			if len(pause.Frames) != 2 || pause.Frames[1].Line != 7 {
+				t.Errorf("unexpected frames %+v", pause.Frames)
+			}
+			scopes, _ := pause.Scopes(1)
+			if len(scopes) != 2 || scopes[0].Variables[0].Name != "i" || scopes[1].Name != "Global" {
+				t.Errorf("unexpected scopes %+v", scopes)
+			}
+			if value, err := pause.Evaluate(0, "a + b * 10"); err != nil || value != 0 {
+				t.Errorf("unexpected value %v %v", value, err)
+			}
+			if value, err := pause.Evaluate(1, "total + i + 5"); err != nil || value != 5 {
+				t.Errorf("unexpected value %v %v", value, err)
+			}
+		}
+		mode := modes[0]
+		modes = modes[1:]
+		return mode, nil
+	})
+	debugger.StopOnEntry()
+	debugger.SetBreakpoint("main.lox", 3)
+	i.SetDebugger(debugger)
+
+	if _, err = i.Interpret(statements); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"entry <script>:1",
+		"breakpoint add:3",
+		"step add:4",
+		"step <script>:7",
+		"breakpoint add:3",
+		"step add:4",
+	}
+	if fmt.Sprint(stops) != fmt.Sprint(expected) {
+		t.Errorf("expected %v but got %v", expected, stops)
+	}
+	if total, _ := i.Get("total"); total != 1 {
+		t.Errorf("expected total to be 1 but got %v", total)
+	}
+
+	// 暂停时终止程序
+	i.SetDebugger(NewDebugger(i, "main.lox", func(pause *Pause) (StepMode, error) {
+		return StepContinue, fmt.Errorf("quit")
+	}))
+	i.debugger.SetBreakpoint("main.lox", 9)
+	if _, err = i.Interpret(statements); err == nil {
+		t.Fatal("expected the program to be terminated")
+	} else if _, ok := err.(*TerminateSignal); !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
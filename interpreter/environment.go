@@ -4,32 +4,44 @@ import (
 	"reflect"
 
 	"github.com/WAY29/LoxGo/lexer"
-	"github.com/WAY29/LoxGo/parser"
 )
 
 type Environment struct {
 	enclosing *Environment
+	globals   *Environment // 所在模块的全局环境, 用于查找全局变量
 	values    map[string]Variable
+	name      string // 全局环境所属模块的文件名, 用于调试器确定语句所在的文件
 }
 
 func NewEnvironment(enclosing *Environment) *Environment {
-	return &Environment{
+	environ := &Environment{
 		enclosing: enclosing,
 		values:    make(map[string]Variable),
 	}
+	if enclosing != nil {
+		environ.globals = enclosing.globals
+	}
+	return environ
 }
 
-func (e *Environment) get(name *lexer.Token) interface{} {
+// 模块的全局环境, 外层为内置函数与宿主定义的变量
+func NewGlobalEnvironment(builtins *Environment) *Environment {
+	environ := NewEnvironment(builtins)
+	environ.globals = environ
+	return environ
+}
+
+func (e *Environment) get(name *lexer.Token) (interface{}, error) {
 	if v, ok := e.values[name.GetValue()]; ok {
-		if v.Value == nil {
-			panic(NewRuntimeError(name, "Access empty variable '%s'.", name))
+		if v.Empty {
+			return nil, NewRuntimeError(name, "Access empty variable '%s'.", name.GetValue())
 		}
-		return v.Value
+		return v.Value, nil
 	}
 	if e.enclosing != nil {
 		return e.enclosing.get(name)
 	}
-	panic(NewRuntimeError(name, "Undefined variable '%s'.", name))
+	return nil, NewRuntimeError(name, "Undefined variable '%s'.", name.GetValue())
 }
 func (e *Environment) ancestor(distance int) *Environment {
 	var environ *Environment = e
@@ -39,15 +51,15 @@ func (e *Environment) ancestor(distance int) *Environment {
 	return environ
 }
 
-func (e *Environment) getAt(distance int, name string) interface{} {
+func (e *Environment) getAt(distance int, name string) (interface{}, error) {
 	environ := e.ancestor(distance)
 	if v, ok := environ.values[name]; ok {
-		if v.Value == nil {
-			panic(NewRuntimeError(nil, "Access empty variable '%s'.", name))
+		if v.Empty {
+			return nil, NewRuntimeError(nil, "Access empty variable '%s'.", name)
 		}
-		return v.Value
+		return v.Value, nil
 	}
-	panic(NewRuntimeError(nil, "Undefined variable '%s'.", name))
+	return nil, NewRuntimeError(nil, "Undefined variable '%s'.", name)
 }
 
 func (e *Environment) getWithBool(name string) (interface{}, bool) {
@@ -80,17 +92,22 @@ func (e *Environment) define(name string, value interface{}) {
 	e.set(name, value)
 }
 
-func (e *Environment) assign(name string, value interface{}) {
-	if _, ok := e.values[name]; ok {
-		e.set(name, value)
-		return
+// 声明没有初始值的变量, 赋值前读取时报错
+func (e *Environment) declare(name string) {
+	e.values[name] = Variable{Type: reflect.Ptr, Empty: true}
+}
+
+func (e *Environment) assign(name *lexer.Token, value interface{}) error {
+	if _, ok := e.values[name.GetValue()]; ok {
+		e.set(name.GetValue(), value)
+		return nil
 	}
 	if e.enclosing != nil {
-		e.enclosing.assign(name, value)
-		return
+		return e.enclosing.assign(name, value)
 	}
+	return NewRuntimeError(name, "Undefined variable '%s'.", name.GetValue())
 }
 
-func (e *Environment) assignAt(distance int, name *lexer.Token, value parser.Expr) {
+func (e *Environment) assignAt(distance int, name *lexer.Token, value interface{}) {
 	e.ancestor(distance).set(name.GetValue(), value)
 }
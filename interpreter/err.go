@@ -2,21 +2,34 @@ package interpreter
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/WAY29/LoxGo/lexer"
 )
 
+const maxTrace = 16
+
 type RuntimeError struct {
 	extraMsg string
 	token    *lexer.Token
+	trace    []TraceFrame
+
+	thrown bool        // 由throw语句抛出
+	value  interface{} // throw语句抛出的值
 }
 
 func NewRuntimeError(token *lexer.Token, format string, a ...interface{}) *RuntimeError {
 	return &RuntimeError{
-		extraMsg: "Runtime Error: " + fmt.Sprintf(format, a...),
+		extraMsg: fmt.Sprintf(format, a...),
+		token:    token,
 	}
 }
 
+// 不带位置信息的错误内容
+func (e *RuntimeError) Message() string {
+	return e.extraMsg
+}
+
 func (e *RuntimeError) Error() string {
 	var (
 		where string
@@ -24,16 +37,101 @@ func (e *RuntimeError) Error() string {
 	)
 
 	if token == nil {
-		return fmt.Sprintf("Runtime Error: %s", e.extraMsg)
+		return fmt.Sprintf("Runtime Error: %s", e.extraMsg) + e.traceString()
 	}
 
 	if token.GetType() == lexer.EOF {
-		where = " at end"
+		where = "at end"
 	} else {
 		where = fmt.Sprintf("at '%s'", token.GetValue())
 	}
 
-	return fmt.Sprintf("Runtime Error in line %d: Error %s: %s", token.GetLine(), where, e.extraMsg)
+	return lexer.FormatError(token.GetSource(), token.GetStart(), token.GetEnd(), fmt.Sprintf("Runtime Error %s: %s", where, e.extraMsg)) + e.traceString()
+}
+
+// 调用栈, 最内层的调用在前
+func (e *RuntimeError) Trace() []TraceFrame {
+	return e.trace
+}
+
+func (e *RuntimeError) traceString() string {
+	if len(e.trace) == 0 {
+		return ""
+	}
+	lines := []string{"Stack trace:"}
+	for n, frame := range e.trace {
+		// 过深的调用栈只保留最内层的部分
+		if n == maxTrace {
+			lines = append(lines, fmt.Sprintf("    ... %d more", len(e.trace)-n))
+			break
+		}
+		lines = append(lines, "    "+frame.String())
+	}
+	return "\n" + strings.Join(lines, "\n")
+}
+
+func (e *RuntimeError) GetToken() *lexer.Token {
+	return e.token
+}
+
+// 补充缺少的位置与调用栈, 已有的信息保持不变, 虚拟机也使用此方法定位错误
+func (e *RuntimeError) Locate(token *lexer.Token, trace func() []TraceFrame) *RuntimeError {
+	if e.token == nil {
+		e.token = token
+	}
+	if e.trace == nil {
+		e.trace = trace()
+	}
+	return e
+}
+
+// 由throw语句抛出的错误, 抛出LoxError时保留其原有的信息
+func NewThrowError(token *lexer.Token, value interface{}) *RuntimeError {
+	if e, ok := value.(*LoxError); ok {
+		return &RuntimeError{extraMsg: e.Message, token: token, thrown: true, value: value}
+	}
+	return &RuntimeError{extraMsg: fmt.Sprintf("Uncaught exception: %v", value), token: token, thrown: true, value: value}
+}
+
+// 脚本中捕获到的运行时错误, 通过e.message, e.line, e.stack访问
+type LoxError struct {
+	Message string
+	Line    int
+	Stack   []string
+}
+
+func (e *LoxError) String() string {
+	return e.Message
+}
+
+// 控制流信号以外的错误都可以被catch捕获
+func IsCatchable(err error) bool {
+	switch err.(type) {
+	case *ReturnSignal, *BreakSignal, *ContinueSignal, *TerminateSignal:
+		return false
+	}
+	return err != nil
+}
+
+// 获取catch中绑定的值: throw抛出的值原样返回, 其他错误转换为LoxError
+func ErrorValue(err error) interface{} {
+	switch e := err.(type) {
+	case *RuntimeError:
+		if e.thrown {
+			return e.value
+		}
+		value := &LoxError{Message: e.extraMsg, Stack: make([]string, len(e.trace))}
+		if e.token != nil {
+			value.Line = e.token.GetLine()
+		}
+		for n, frame := range e.trace {
+			value.Stack[n] = frame.String()
+		}
+		return value
+	case *ConvertError:
+		return &LoxError{Message: e.Message(), Stack: []string{}}
+	}
+	return &LoxError{Message: err.Error(), Stack: []string{}}
 }
 
 type ConvertError struct {
@@ -54,9 +152,37 @@ func NewConvertError(value interface{}, typeString, msg string) *ConvertError {
 }
 
 func (e *ConvertError) Error() string {
+	return "Runtime error: " + e.Message()
+}
 
+// 不带前缀的错误内容
+func (e *ConvertError) Message() string {
 	if e.value == nil {
-		return fmt.Sprintf("Runtime error: Convert Error: %s.", e.extraMsg)
+		if len(e.typeString) > 0 {
+			return fmt.Sprintf("Convert error: can't convert nil to %s%s.", e.typeString, e.extraMsg)
+		}
+		return fmt.Sprintf("Convert Error: %s.", e.extraMsg)
+	}
+	return fmt.Sprintf("Convert error: can't convert %v[%T] to %s%s.", e.value, e.value, e.typeString, e.extraMsg)
+}
+
+// 兜底处理执行过程中的panic, 避免脚本错误导致宿主进程崩溃
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		switch e := r.(type) {
+		case *RuntimeError:
+			*err = e
+		case *ConvertError:
+			*err = e
+		default:
+			*err = NewRuntimeError(nil, "Internal error: %v", r)
+		}
+	}
+}
+
+// 将Go函数中的panic转换为错误
+func recoverPanic(name string, err *error) {
+	if r := recover(); r != nil {
+		*err = NewRuntimeError(nil, "Panic in %s: %v", name, r)
 	}
-	return fmt.Sprintf("Runtime error: Convert error: can't convert %v[%T] to %s%s.", e.value, e.value, e.typeString, e.extraMsg)
 }
@@ -1,27 +1,158 @@
 package interpreter
 
-import "fmt"
+import (
+	"fmt"
 
-type CallableFunc = func(interpreter *Interpreter, arguments []interface{}) (interface{}, error)
+	"github.com/WAY29/LoxGo/lexer"
+)
 
-type LoxCallable interface {
-	arity() int
-	call(interpreter *Interpreter, arguments []interface{}) (interface{}, error)
+// 内置函数通过Caller调用作为参数传入的Lox函数, 树遍历解释器与vm都实现了它
+type Caller interface {
+	Call(callee interface{}, arguments ...interface{}) (interface{}, error)
+}
+
+type CallableFunc = func(caller Caller, arguments []interface{}) (interface{}, error)
+
+// 可以被调用的值, 包括vm中的函数与类
+type Callable interface {
+	Arity() int
 	String() string
 	Name() string
 }
 
+type LoxCallable interface {
+	Callable
+	Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error)
+}
+
+// 参数个数可变的函数, max为-1时没有上限
+type ArityRanger interface {
+	ArityRange() (min, max int)
+}
+
+// 获取函数接受的参数个数范围, Arity()为-1的函数可以接受任意个参数
+func ArityRange(callee LoxCallable) (min, max int) {
+	if ranger, ok := callee.(ArityRanger); ok {
+		return ranger.ArityRange()
+	}
+	if arity := callee.Arity(); arity != -1 {
+		return arity, arity
+	}
+	return 0, -1
+}
+
+// 检查参数个数, 由解释器与vm共用
+func CheckArity(token *lexer.Token, callee LoxCallable, argCount int) error {
+	min, max := ArityRange(callee)
+	return CheckArityRange(token, min, max, argCount)
+}
+
+func CheckArityRange(token *lexer.Token, min, max, argCount int) error {
+	if message := arityMessage(min, max, argCount); message != "" {
+		return NewRuntimeError(token, "%s", message)
+	}
+	return nil
+}
+
+// 参数个数不符合时的错误信息, 符合时返回空字符串
+func arityMessage(min, max, argCount int) string {
+	switch {
+	case argCount >= min && (max == -1 || argCount <= max):
+		return ""
+	case min == max:
+		return fmt.Sprintf("Excepted %d arguments but got %d.", min, argCount)
+	case max == -1:
+		return fmt.Sprintf("Excepted at least %d arguments but got %d.", min, argCount)
+	}
+	return fmt.Sprintf("Excepted %d to %d arguments but got %d.", min, max, argCount)
+}
+
+// 内置函数的参数类型, 调用前进行检查
+type ArgType int
+
+const (
+	ArgAny ArgType = iota
+	ArgInt
+	ArgFloat
+	ArgNumber
+	ArgString
+	ArgBool
+	ArgArray
+	ArgCallable
+	ArgMap
+)
+
+var argTypeNames = map[ArgType]string{
+	ArgAny:      "any",
+	ArgInt:      "int",
+	ArgFloat:    "float",
+	ArgNumber:   "number",
+	ArgString:   "string",
+	ArgBool:     "bool",
+	ArgArray:    "array",
+	ArgCallable: "callable",
+	ArgMap:      "map",
+}
+
+func (t ArgType) String() string {
+	return argTypeNames[t]
+}
+
+func (t ArgType) check(value interface{}) bool {
+	switch t {
+	case ArgAny:
+		return true
+	case ArgInt:
+		_, ok := value.(int)
+		return ok
+	case ArgFloat:
+		_, ok := value.(float64)
+		return ok
+	case ArgNumber:
+		return kindOf(value) != notNumber
+	case ArgString:
+		_, ok := value.(string)
+		return ok
+	case ArgBool:
+		_, ok := value.(bool)
+		return ok
+	case ArgArray:
+		_, ok := value.([]interface{})
+		return ok
+	case ArgCallable:
+		_, ok := value.(Callable)
+		return ok
+	case ArgMap:
+		_, ok := value.(*LoxMap)
+		return ok
+	}
+	return false
+}
+
 type LoxBuiltinFunc struct { // Impl LoxCallable
 	name       string
 	argsNumber int
+	argTypes   []ArgType
 	callback   CallableFunc
 }
 
-func (f *LoxBuiltinFunc) call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
-	return f.callback(interpreter, arguments)
+func (f *LoxBuiltinFunc) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return f.CallWith(interpreter, arguments)
 }
 
-func (f *LoxBuiltinFunc) arity() int {
+// 以caller调用内置函数, vm调用时传入自身
+func (f *LoxBuiltinFunc) CallWith(caller Caller, arguments []interface{}) (result interface{}, err error) {
+	defer recoverPanic(f.name, &err)
+
+	for n, argType := range f.argTypes {
+		if n < len(arguments) && !argType.check(arguments[n]) {
+			return nil, NewRuntimeError(nil, "Argument %d of %s must be %s, got %v[%T].", n+1, f.name, argType, arguments[n], arguments[n])
+		}
+	}
+	return f.callback(caller, arguments)
+}
+
+func (f *LoxBuiltinFunc) Arity() int {
 	return f.argsNumber
 }
 
@@ -40,3 +171,13 @@ func NewLoxBuiltinFunc(callback CallableFunc, name string, arity int) *LoxBuilti
 		callback:   callback,
 	}
 }
+
+// 带参数类型检查的内置函数, 参数个数由参数类型个数决定
+func NewTypedLoxBuiltinFunc(callback CallableFunc, name string, argTypes ...ArgType) *LoxBuiltinFunc {
+	return &LoxBuiltinFunc{
+		name:       name,
+		argsNumber: len(argTypes),
+		argTypes:   argTypes,
+		callback:   callback,
+	}
+}
@@ -18,17 +18,16 @@ func (i *LoxInstance) String() string {
 	return i.class.className + " instance"
 }
 
-func (i *LoxInstance) get(name *lexer.Token) (result interface{}) {
-	var ok bool
-	if result, ok = i.fields[name.GetValue()]; ok {
-		return
+func (i *LoxInstance) get(name *lexer.Token) (interface{}, error) {
+	if result, ok := i.fields[name.GetValue()]; ok {
+		return result, nil
 	}
 	method := i.class.findMethod(name.GetValue())
 	if method != nil {
-		return method.bind(i)
+		return method.bind(i), nil
 	}
 
-	panic(NewRuntimeError(name, "Undefined property '%s'.", name.GetValue()))
+	return nil, NewRuntimeError(name, "Undefined property '%s'.", name.GetValue())
 }
 
 func (i *LoxInstance) set(name *lexer.Token, value interface{}) {
@@ -2,7 +2,8 @@ package interpreter
 
 import (
 	"fmt"
-	"math"
+	"io"
+	"os"
 	"sync/atomic"
 
 	"github.com/WAY29/LoxGo/lexer"
@@ -14,63 +15,124 @@ var (
 )
 
 type Interpreter struct { // impl ExprVisitor, StmtVisitor
+	builtins    *Environment // 所有模块共享的内置函数与宿主定义的变量
 	globals     *Environment
 	environment *Environment
 	locals      map[parser.Expr]int
-
-	block *parser.Block
-	while *parser.While
+	modules     *ModuleLoader
 
 	stack     *Stack
 	stackSize uint64
+
+	debugger *Debugger
+
+	out io.Writer
 }
 
 func NewInterpreter() *Interpreter {
-	globals := NewBuiltinEnvironments()
+	builtins := NewBuiltinEnvironments()
+	globals := NewGlobalEnvironment(builtins)
 	return &Interpreter{
+		builtins:    builtins,
 		globals:     globals,
 		environment: globals,
 		locals:      make(map[parser.Expr]int),
-		block:       nil,
+		modules:     NewModuleLoader(),
 		stack:       NewStack(nil, nil, nil),
+		out:         os.Stdout,
 	}
 }
 
-func (i *Interpreter) newEnvironmentState(newEnviron *Environment) func() {
-	oldEnviron := i.environment
-	i.environment = newEnviron
+// 设置print语句的输出位置
+func (i *Interpreter) SetOutput(out io.Writer) {
+	i.out = out
+}
 
-	return func() {
-		i.environment = oldEnviron
-	}
+// 设置模块搜索路径
+func (i *Interpreter) SetModulePath(paths ...string) {
+	i.modules.SetPath(paths...)
 }
 
-func (i *Interpreter) newBlockState(newBlock *parser.Block) func() {
-	oldBlock := i.block
-	i.block = newBlock
+// 开始执行入口脚本, 返回结束执行时调用的函数
+func (i *Interpreter) EnterScript(name string) func() {
+	return i.modules.Enter(name)
+}
 
-	return func() {
-		i.block = oldBlock
+// 定义全局变量, 对所有模块可见
+func (i *Interpreter) Define(name string, value interface{}) {
+	i.builtins.define(name, FromGoGlobal(name, value))
+}
+
+// 附加调试器, 为nil时取消
+func (i *Interpreter) SetDebugger(debugger *Debugger) {
+	i.debugger = debugger
+}
+
+// 获取全局变量
+func (i *Interpreter) Get(name string) (interface{}, bool) {
+	return i.globals.getWithBool(name)
+}
+
+// 从Go中调用Lox函数或类
+func (i *Interpreter) Call(callee interface{}, arguments ...interface{}) (result interface{}, err error) {
+	defer recoverError(&err)
+
+	calleeFunc, ok := callee.(LoxCallable)
+	if !ok {
+		return nil, NewRuntimeError(nil, "Can only call functions and classes.")
+	}
+	if err := CheckArity(nil, calleeFunc, len(arguments)); err != nil {
+		return nil, err
 	}
+	restoreStack, err := i.newStackState(nil, calleeFunc)
+	if err != nil {
+		return nil, err
+	}
+	defer restoreStack()
+
+	result, err = calleeFunc.Call(i, arguments)
+	if err != nil {
+		return nil, i.traceError(signalToError(err), nil)
+	}
+	return result, nil
+}
+
+// 为被调用函数中产生的错误补充位置和调用栈, 只在最内层的调用处记录
+func (i *Interpreter) traceError(err error, paren *lexer.Token) error {
+	if _, ok := err.(*TerminateSignal); ok {
+		return err
+	}
+	e, ok := err.(*RuntimeError)
+	if ce, isConvert := err.(*ConvertError); isConvert {
+		e = NewRuntimeError(paren, "%s", ce.Message())
+	} else if !ok {
+		e = NewRuntimeError(paren, "%v", err)
+	}
+	return e.Locate(paren, i.stack.Trace)
 }
 
-func (i *Interpreter) newWhileState(newWhile *parser.While) func() {
-	oldWhile := i.while
-	i.while = newWhile
+func (i *Interpreter) newEnvironmentState(newEnviron *Environment) func() {
+	oldEnviron := i.environment
+	i.environment = newEnviron
 
 	return func() {
-		i.while = oldWhile
+		i.environment = oldEnviron
 	}
 }
 
-func (i *Interpreter) newStackState(call *parser.Call, callee LoxCallable) func() {
+func (i *Interpreter) newStackState(call *parser.Call, callee LoxCallable) (func(), error) {
+	if i.stackSize+1 >= 8192 {
+		var paren *lexer.Token
+		if call != nil {
+			paren = call.Paren
+		}
+		return nil, NewRuntimeError(paren, "Stack oversize: Can't have more than 8192 stack.")
+	}
 	oldStack := i.stack
 	newStack := NewStack(i.stack, call, callee)
+	newStack.environment = i.environment
 	i.stack = newStack
 	atomic.AddUint64(&i.stackSize, 1)
-	if i.stackSize >= 8192 {
-		panic(NewRuntimeError(call.Paren, "Stack oversize: Can't have more than 8192 stack."))
-	}
 
 	return func() {
 		//todo debug
@@ -78,23 +140,22 @@ func (i *Interpreter) newStackState(call *parser.Call, callee LoxCallable) func(
 
 		atomic.AddUint64(&i.stackSize, -ONE)
 		i.stack = oldStack
-	}
+	}, nil
 }
 
-func (i *Interpreter) Interpret(statemants []parser.Stmt) []interface{} {
-	var (
-		err     error
-		result  interface{}
-		results = make([]interface{}, len(statemants))
-	)
+func (i *Interpreter) Interpret(statemants []parser.Stmt) (results []interface{}, err error) {
+	defer recoverError(&err)
+
+	var result interface{}
+	results = make([]interface{}, len(statemants))
 	for n, stmt := range statemants {
 		result, err = i.execute(stmt)
 		if err != nil {
-			panic(err)
+			return nil, signalToError(err)
 		}
 		results[n] = result
 	}
-	return results
+	return results, nil
 }
 
 func (i *Interpreter) evaluate(expr parser.Expr) (interface{}, error) {
@@ -108,30 +169,35 @@ func (i *Interpreter) execute(stmt parser.Stmt) (interface{}, error) {
 	if stmt == nil {
 		return nil, nil
 	}
+	if i.debugger != nil {
+		if err := i.debugger.before(stmt); err != nil {
+			return nil, err
+		}
+	}
 	return stmt.Accept(i)
 }
 
-func (i *Interpreter) executeBlock(block *parser.Block, environment *Environment) (result interface{}, err error) {
-	block = parser.NewBlock(block.Statements, false, i.block)
+// 在指定的环境中执行语句
+func (i *Interpreter) executeIn(stmt parser.Stmt, environment *Environment) (interface{}, error) {
+	defer i.newEnvironmentState(environment)()
+
+	return i.execute(stmt)
+}
 
+// 在指定环境中对表达式求值
+func (i *Interpreter) evaluateIn(expr parser.Expr, environment *Environment) (interface{}, error) {
+	defer i.newEnvironmentState(environment)()
+	return i.evaluate(expr)
+}
+
+func (i *Interpreter) executeBlock(block *parser.Block, environment *Environment) (result interface{}, err error) {
 	defer i.newEnvironmentState(environment)()
-	defer i.newBlockState(block)()
 
 	for _, stmt := range block.Statements {
-		if block.Stop {
-			block.Stop = false
-			break
-		}
 		_, err = i.execute(stmt)
 		if err != nil {
 			return nil, err
 		}
-
-		if i.stack.call != nil && i.stack.call.ReturnValue != nil {
-			block.Stop = false
-			value := i.stack.call.ReturnValue
-			return value, nil
-		}
 	}
 
 	return nil, nil
@@ -145,10 +211,14 @@ func (i *Interpreter) Resolve(expr parser.Expr, depth int) {
 func (i *Interpreter) lookUpVariable(name *lexer.Token, expr parser.Expr) (interface{}, error) {
 	// fmt.Printf("debug: lookup expr: %#v locals:%#v\n", expr, i.locals)
 	if distance, ok := i.locals[expr]; !ok {
-		return i.globals.get(name), nil
+		return i.environment.globals.get(name)
 	} else {
 		// fmt.Printf("debug: find in %d distance scope: %s\n", distance, name.GetValue())
-		return i.environment.getAt(distance, name.GetValue()), nil
+		result, err := i.environment.getAt(distance, name.GetValue())
+		if e, ok := err.(*RuntimeError); ok {
+			e.token = name
+		}
+		return result, err
 	}
 }
 
@@ -176,16 +246,43 @@ func (i *Interpreter) VisitLogicalExpr(expr *parser.Logical) (interface{}, error
 }
 
 func (i *Interpreter) VisitSetExpr(expr *parser.Set) (result interface{}, err error) {
-	var value interface{}
+	var current, value interface{}
 
-	result, err = i.evaluate(expr.Instance)
-	if instance, ok := result.(*LoxInstance); ok {
-		value, err = i.evaluate(expr.Value)
-		instance.set(expr.Name, value)
-		return value, nil
-	} else {
-		panic(NewRuntimeError(expr.Name, "Only instances have fields."))
+	if result, err = i.evaluate(expr.Instance); err != nil {
+		return nil, err
 	}
+	if expr.Operator != nil {
+		if current, err = i.getProperty(result, expr.Name); err != nil {
+			return nil, err
+		}
+	}
+	if value, err = i.evaluate(expr.Value); err != nil {
+		return nil, err
+	}
+	if expr.Operator != nil {
+		if value, err = i.binaryOperate(expr.Operator, current, value); err != nil {
+			return nil, err
+		}
+	}
+	if err = i.setProperty(result, expr.Name, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (i *Interpreter) setProperty(object interface{}, name *lexer.Token, value interface{}) error {
+	if instance, ok := object.(*LoxInstance); ok {
+		instance.set(name, value)
+		return nil
+	} else if class, ok := object.(*LoxClass); ok {
+		class.set(name, value)
+		return nil
+	}
+	// 通过反射设置Go值
+	if err := SetProperty(object, name.GetValue(), value); err != nil {
+		return i.traceError(err, name)
+	}
+	return nil
 }
 
 func (i *Interpreter) VisitThisExpr(expr *parser.This) (interface{}, error) {
@@ -196,139 +293,89 @@ func (i *Interpreter) VisitGroupingExpr(expr *parser.Grouping) (interface{}, err
 	return i.evaluate(expr.Expression)
 }
 
-func (i *Interpreter) VisitUnaryExpr(expr *parser.Unary) (result interface{}, err error) {
-	// 强制整数转换
-	defer func() {
-		if err == nil {
-			if v, ok := result.(float64); !ok {
-			} else if v2, ok := float642Int(v); ok {
-				result = v2
-			}
-		}
-	}()
-
-	var (
-		ok    bool
-		ve    *parser.Variable
-		vi    interface{}
-		v     float64
-		v2    int
-		isVar bool = false
-	)
+func (i *Interpreter) VisitUnaryExpr(expr *parser.Unary) (interface{}, error) {
+	operator := expr.Operator.GetType()
+	if operator == lexer.PLUSPLUS || operator == lexer.MINUSMINUS {
+		return i.increment(expr)
+	}
 
 	right, err := i.evaluate(expr.Right)
 	if err != nil {
 		return nil, err
 	}
+	result, err := UnaryOperate(operator, right)
+	if err != nil {
+		return nil, i.traceError(err, expr.Operator)
+	}
+	return result, nil
+}
 
-	if expr.Prefix {
-		switch expr.Operator.GetType() {
-		case lexer.MINUS:
-			if v, ok := interfaceToFloat64(right); ok {
-				return -1 * v, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		case lexer.PLUS:
-			if v, ok := interfaceToFloat64(right); ok {
-				return v, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		case lexer.BANG:
-			return !isTruthy(right), nil
-		case lexer.PLUSPLUS:
-			if ve, ok = expr.Right.(*parser.Variable); ok {
-				if vi, ok = i.environment.getWithBool(ve.Name.GetValue()); ok {
-					isVar = true
-				}
-			} else {
-				vi = right
-			}
-			if v, ok = interfaceToFloat64(vi); ok {
-				if v, ok = interfaceToFloat64(vi); ok {
-					if v2, ok = float642Int(v + 1); ok {
-						vi = v2
-					}
-					if isVar {
-						i.environment.assign(ve.Name.GetValue(), vi)
-					}
-					return vi, nil
-				}
-			}
-			return nil, NewConvertError(vi, "float", "")
-		case lexer.MINUSMINUS:
-			if ve, ok = expr.Right.(*parser.Variable); ok {
-				if vi, ok = i.environment.getWithBool(ve.Name.GetValue()); ok {
-					isVar = true
-				}
-			} else {
-				vi = right
-			}
-			if v, ok = interfaceToFloat64(vi); ok {
-				if v2, ok = float642Int(v - 1); ok {
-					vi = v2
-				}
-				if isVar {
-					i.environment.assign(ve.Name.GetValue(), vi)
-				}
-				return vi, nil
-			}
-			return nil, NewConvertError(vi, "float", "")
+// 自增自减: a = a + 1, 后缀形式返回修改前的值; 目标为属性或下标时对象与下标只求值一次
+func (i *Interpreter) increment(expr *parser.Unary) (interface{}, error) {
+	var (
+		current interface{}
+		set     func(value interface{}) error
+		err     error
+	)
+	switch target := expr.Right.(type) {
+	case *parser.Variable:
+		if current, err = i.evaluate(target); err != nil {
+			return nil, err
 		}
-	} else {
-		switch expr.Operator.GetType() {
-		case lexer.PLUSPLUS:
-			if ve, ok = expr.Right.(*parser.Variable); ok {
-				if vi, ok = i.environment.getWithBool(ve.Name.GetValue()); ok {
-					isVar = true
-				}
-			} else {
-				vi = right
-			}
-			if v, ok = interfaceToFloat64(vi); ok {
-				if v2, ok = float642Int(v + 1); ok {
-					vi = v2
-				}
-				if isVar {
-					i.environment.assign(ve.Name.GetValue(), vi)
-				}
-				return v, nil
-			}
-			return nil, NewConvertError(vi, "float", "")
-		case lexer.MINUSMINUS:
-			if ve, ok = expr.Right.(*parser.Variable); ok {
-				if vi, ok = i.environment.getWithBool(ve.Name.GetValue()); ok {
-					isVar = true
-				}
-			} else {
-				vi = right
-			}
-			if v, ok = interfaceToFloat64(vi); ok {
-				if v2, ok = float642Int(v - 1); ok {
-					vi = v2
-				}
-				if isVar {
-					i.environment.assign(ve.Name.GetValue(), vi)
-				}
-				return v, nil
+		set = func(value interface{}) error {
+			return i.assignVariable(target, target.Name, value)
+		}
+	case *parser.Get:
+		object, err := i.evaluate(target.Instance)
+		if err != nil {
+			return nil, err
+		}
+		if current, err = i.getProperty(object, target.Name); err != nil {
+			return nil, err
+		}
+		set = func(value interface{}) error {
+			return i.setProperty(object, target.Name, value)
+		}
+	case *parser.Index:
+		object, err := i.evaluate(target.Object)
+		if err != nil {
+			return nil, err
+		}
+		index, err := i.evaluate(target.Index)
+		if err != nil {
+			return nil, err
+		}
+		if current, err = IndexOperate(object, index); err != nil {
+			return nil, i.traceError(err, target.Bracket)
+		}
+		set = func(value interface{}) error {
+			if err := IndexSetOperate(object, index, value); err != nil {
+				return i.traceError(err, target.Bracket)
 			}
-			return nil, NewConvertError(vi, "float", "")
+			return nil
 		}
+	default:
+		return nil, i.traceError(NewRuntimeError(expr.Operator, "Invalid increment target."), expr.Operator)
 	}
 
-	return nil, NewConvertError(right, "float", "invalid unary expression")
+	binaryOperator := lexer.PLUS
+	if expr.Operator.GetType() == lexer.MINUSMINUS {
+		binaryOperator = lexer.MINUS
+	}
+	result, err := BinaryOperate(binaryOperator, current, 1)
+	if err != nil {
+		return nil, i.traceError(err, expr.Operator)
+	}
+	if err = set(result); err != nil {
+		return nil, err
+	}
+	if expr.Prefix {
+		return result, nil
+	}
+	return current, nil
 }
 
 func (i *Interpreter) VisitBinaryExpr(expr *parser.Binary) (result interface{}, err error) {
-
-	// 强制整数转换
-	defer func() {
-		if err == nil {
-			if v, ok := result.(float64); ok && v == math.Trunc(v) {
-				result = int(v)
-			}
-		}
-	}()
-
 	left, err := i.evaluate(expr.Left)
 	if err != nil {
 		return nil, err
@@ -339,94 +386,22 @@ func (i *Interpreter) VisitBinaryExpr(expr *parser.Binary) (result interface{},
 		return nil, err
 	}
 
-	switch expr.Operator.GetType() {
-	case lexer.MINUS:
-		if v, ok := interfaceToFloat64(left); ok {
-			if v2, ok := interfaceToFloat64(right); ok {
-				return v - v2, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		}
-		return nil, NewConvertError(left, "float", "")
-	case lexer.SLASH:
-		if v, ok := interfaceToFloat64(left); ok {
-			if v2, ok := interfaceToFloat64(right); ok {
-				return v / v2, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		}
-		return nil, NewConvertError(left, "float", "")
-	case lexer.STAR:
-		if v, ok := interfaceToFloat64(left); ok {
-			if v2, ok := interfaceToFloat64(right); ok {
-				return v * v2, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		}
-		return nil, NewConvertError(left, "float", "")
-	case lexer.PLUS:
-		if v, ok := interfaceToFloat64(left); ok {
-			if v2, ok := interfaceToFloat64(right); ok {
-				return v + v2, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		}
-		if v, ok := left.(string); ok {
-			if v2, ok := right.(string); ok {
-				return v + v2, nil
-			}
-			return nil, NewConvertError(right, "string", "")
-		}
-		return nil, NewConvertError(left, "string / float", "")
-	case lexer.GREATER:
-		if v, ok := interfaceToFloat64(left); ok {
-			if v2, ok := interfaceToFloat64(right); ok {
-				return v > v2, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		}
-		return nil, NewConvertError(left, "float", "")
-	case lexer.GREATER_EQUAL:
-		if v, ok := interfaceToFloat64(left); ok {
-			if v2, ok := interfaceToFloat64(right); ok {
-				return v >= v2, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		}
-		return nil, NewConvertError(left, "float", "")
-	case lexer.LESS:
-		if v, ok := interfaceToFloat64(left); ok {
-			if v2, ok := interfaceToFloat64(right); ok {
-				return v < v2, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		}
-		return nil, NewConvertError(left, "float", "")
-	case lexer.LESS_EQUAL:
-		if v, ok := interfaceToFloat64(left); ok {
-			if v2, ok := interfaceToFloat64(right); ok {
-				return v <= v2, nil
-			}
-			return nil, NewConvertError(right, "float", "")
-		}
-		return nil, NewConvertError(left, "float", "")
-	case lexer.EQUAL_EQUAL:
-		return isEqual(left, right), nil
-	case lexer.BANG_EQUAL:
-		return !isEqual(left, right), nil
-	}
+	return i.binaryOperate(expr.Operator, left, right)
+}
 
-	return nil, NewConvertError(nil, "", "invalid binary expression")
+func (i *Interpreter) binaryOperate(operator *lexer.Token, left, right interface{}) (interface{}, error) {
+	result, err := BinaryOperate(operator.GetType(), left, right)
+	if err != nil {
+		return nil, i.traceError(err, operator)
+	}
+	return result, nil
 }
 
 func (i *Interpreter) VisitCallExpr(expr *parser.Call) (result interface{}, err error) {
 	var (
-		callee, v interface{}
+		callee    interface{}
+		arguments []interface{}
 	)
-	expr = parser.NewCall(expr.Callee, expr.Paren, expr.Arguments, nil)
-	defer i.newBlockState(nil)()
-	defer i.newWhileState(nil)()
-
 	callee, err = i.evaluate(expr.Callee)
 	if err != nil {
 		return nil, err
@@ -434,37 +409,53 @@ func (i *Interpreter) VisitCallExpr(expr *parser.Call) (result interface{}, err
 	if calleeFunc, ok := callee.(LoxCallable); !ok {
 		return nil, NewRuntimeError(expr.Paren, "Can only call functions and classes.")
 	} else {
-		defer i.newStackState(expr, calleeFunc)()
-
-		argsLen := len(expr.Arguments)
-		if calleeFunc.arity() != argsLen && calleeFunc.arity() != -1 {
-			return nil, NewRuntimeError(expr.Paren, "Excepted %d arguments but got %d.", calleeFunc.arity(), argsLen)
+		arguments, err = i.evaluateElements(expr.Arguments)
+		if err != nil {
+			return nil, err
 		}
-		arguments := make([]interface{}, argsLen)
-		for n, argument := range expr.Arguments {
-			v, err = i.evaluate(argument)
-			if err != nil {
-				return nil, err
-			}
-			arguments[n] = v
+		if err = CheckArity(expr.Paren, calleeFunc, len(arguments)); err != nil {
+			return nil, err
 		}
-		result, err = calleeFunc.call(i, arguments)
+
+		var restoreStack func()
+		if restoreStack, err = i.newStackState(expr, calleeFunc); err != nil {
+			return nil, err
+		}
+		defer restoreStack()
+
+		result, err = calleeFunc.Call(i, arguments)
 		if err != nil {
-			panic(NewRuntimeError(expr.Paren, "%v", err))
+			return nil, i.traceError(err, expr.Paren)
 		}
 		return
 	}
 }
 
-func (i *Interpreter) VisitGetExpr(expr *parser.Get) (result interface{}, err error) {
-	result, err = i.evaluate(expr.Instance)
+func (i *Interpreter) VisitGetExpr(expr *parser.Get) (interface{}, error) {
+	object, err := i.evaluate(expr.Instance)
 	if err != nil {
 		return nil, err
 	}
-	if instance, ok := result.(*LoxInstance); ok {
-		return instance.get(expr.Name), nil
+	return i.getProperty(object, expr.Name)
+}
+
+func (i *Interpreter) getProperty(object interface{}, name *lexer.Token) (result interface{}, err error) {
+	if instance, ok := object.(*LoxInstance); ok {
+		return instance.get(name)
+	} else if class, ok := object.(*LoxClass); ok {
+		return class.get(name)
+	} else if module, ok := object.(*LoxModule); ok {
+		result, err = module.Get(name.GetValue())
+		if err != nil {
+			return nil, i.traceError(err, name)
+		}
+		return result, nil
+	}
+	// 通过反射访问Go值
+	if result, err = GetProperty(object, name.GetValue()); err != nil {
+		return nil, i.traceError(err, name)
 	}
-	return nil, NewRuntimeError(expr.Name, "Only instances have properties.")
+	return result, nil
 }
 
 func (i *Interpreter) VisitVariableExpr(expr *parser.Variable) (interface{}, error) {
@@ -491,62 +482,133 @@ func (i *Interpreter) VisitTernaryExpr(expr *parser.Ternary) (interface{}, error
 }
 
 func (i *Interpreter) VisitAssignExpr(expr *parser.Assign) (interface{}, error) {
+	var current interface{}
+	if expr.Operator != nil {
+		var err error
+		if current, err = i.lookUpVariable(expr.Name, expr); err != nil {
+			return nil, err
+		}
+	}
 	value, err := i.evaluate(expr.Value)
 	if err != nil {
 		return nil, err
 	}
-	if distance, ok := i.locals[expr]; !ok {
-		i.globals.assign(expr.Name.GetValue(), value)
-	} else {
-		i.environment.assignAt(distance, expr.Name, expr)
+	if expr.Operator != nil {
+		if value, err = i.binaryOperate(expr.Operator, current, value); err != nil {
+			return nil, err
+		}
+	}
+	if err = i.assignVariable(expr, expr.Name, value); err != nil {
+		return nil, err
 	}
-
 	return value, nil
 }
 
+// 根据静态解析的结果给变量赋值
+func (i *Interpreter) assignVariable(expr parser.Expr, name *lexer.Token, value interface{}) error {
+	if distance, ok := i.locals[expr]; ok {
+		i.environment.assignAt(distance, name, value)
+		return nil
+	}
+	return i.environment.globals.assign(name, value)
+}
+
 func (i *Interpreter) VisitArrayExpr(expr *parser.Array) (interface{}, error) {
-	var (
-		value interface{}
-		err   error
-	)
-	array := make([]interface{}, 0)
-	for _, element := range expr.Elements {
-		value, err = element.Accept(i)
+	return i.evaluateElements(expr.Elements)
+}
+
+// 对实参或数组元素求值, 展开其中的 ...arr
+func (i *Interpreter) evaluateElements(exprs []parser.Expr) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(exprs))
+	for _, expr := range exprs {
+		spread, isSpread := expr.(*parser.Spread)
+		if isSpread {
+			expr = spread.Value
+		}
+		value, err := i.evaluate(expr)
 		if err != nil {
 			return nil, err
 		}
-		array = append(array, value)
+		if !isSpread {
+			values = append(values, value)
+			continue
+		}
+		array, err := SpreadElements(value)
+		if err != nil {
+			return nil, i.traceError(err, spread.Token)
+		}
+		values = append(values, array...)
 	}
-	return array, nil
+	return values, nil
+}
+
+// 展开运算只能出现在实参与数组字面量中, 由evaluateElements处理
+func (i *Interpreter) VisitSpreadExpr(expr *parser.Spread) (interface{}, error) {
+	return nil, NewRuntimeError(expr.Token, "Can only spread in arguments and array literals.")
 }
 
 func (i *Interpreter) VisitIndexExpr(expr *parser.Index) (interface{}, error) {
-	var (
-		identifier, indexInterface interface{}
-		array                      []interface{}
-		index                      int
-		ok                         bool
-		err                        error
-	)
-	identifier, err = i.lookUpVariable(expr.Name, expr)
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+	result, err := IndexOperate(object, index)
+	if err != nil {
+		return nil, i.traceError(err, expr.Bracket)
+	}
+	return result, nil
+}
+
+func (i *Interpreter) VisitIndexSetExpr(expr *parser.IndexSet) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.evaluate(expr.Index)
 	if err != nil {
 		return nil, err
 	}
-	if array, ok = identifier.([]interface{}); ok {
-		indexInterface, err = expr.Index.Accept(i)
+	var current interface{}
+	if expr.Operator != nil {
+		if current, err = IndexOperate(object, index); err != nil {
+			return nil, i.traceError(err, expr.Bracket)
+		}
+	}
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	if expr.Operator != nil {
+		if value, err = i.binaryOperate(expr.Operator, current, value); err != nil {
+			return nil, err
+		}
+	}
+	if err = IndexSetOperate(object, index, value); err != nil {
+		return nil, i.traceError(err, expr.Bracket)
+	}
+	return value, nil
+}
+
+func (i *Interpreter) VisitMapExpr(expr *parser.Map) (interface{}, error) {
+	m := NewLoxMap()
+	for n, keyExpr := range expr.Keys {
+		key, err := i.evaluate(keyExpr)
 		if err != nil {
 			return nil, err
 		}
-		if index, ok = interfaceToInt(indexInterface); !ok {
-			return nil, NewRuntimeError(expr.Name, "Index must be int.")
+		value, err := i.evaluate(expr.Values[n])
+		if err != nil {
+			return nil, err
 		}
-		if index > len(array)-1 {
-			return nil, NewRuntimeError(expr.Name, "Array index out of range.")
+		if err = m.Set(key, value); err != nil {
+			return nil, i.traceError(err, expr.Brace)
 		}
-		return array[index], nil
-	} else {
-		return nil, NewRuntimeError(expr.Name, "Can only index array.")
 	}
+	return m, nil
 }
 
 func (i *Interpreter) VisitLambdaExpr(expr *parser.Lambda) (interface{}, error) {
@@ -556,6 +618,40 @@ func (i *Interpreter) VisitLambdaExpr(expr *parser.Lambda) (interface{}, error)
 	return nil, NewRuntimeError(expr.Token, "Invalid Lmabda in line %d.", expr.Token.GetLine())
 }
 
+func (i *Interpreter) VisitStringifyExpr(expr *parser.Stringify) (interface{}, error) {
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	return ToString(value), nil
+}
+
+func (i *Interpreter) VisitSuperExpr(expr *parser.Super) (interface{}, error) {
+	distance := i.locals[expr]
+	var method *LoxCustomFunc
+
+	result, err := i.environment.getAt(distance, "super")
+	if err != nil {
+		return nil, err
+	}
+	superclass := result.(*LoxClass)
+	// "this"总是位于"super"所在环境的内层
+	this, err := i.environment.getAt(distance-1, "this")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := this.(*LoxClass); ok {
+		method = superclass.findClassMethod(expr.Method.GetValue())
+	} else {
+		method = superclass.findMethod(expr.Method.GetValue())
+	}
+	if method == nil {
+		return nil, NewRuntimeError(expr.Method, "Undefined property '%s'.", expr.Method.GetValue())
+	}
+	return method.bind(this), nil
+}
+
 func (i *Interpreter) VisitExpressionStmt(stmt *parser.Expression) (interface{}, error) {
 	result, err := i.evaluate(stmt.Expr)
 	return result, err
@@ -563,7 +659,7 @@ func (i *Interpreter) VisitExpressionStmt(stmt *parser.Expression) (interface{},
 
 func (i *Interpreter) VisitFunctionStmt(stmt *parser.Function) (interface{}, error) {
 	// fmt.Printf("debug: set function: %s\n", stmt.Name.GetValue())
-	i.environment.define(stmt.Name.GetValue(), NewLoxCustomFunc(stmt, NewEnvironment(i.environment), false))
+	i.environment.define(stmt.Name.GetValue(), NewLoxCustomFunc(stmt, i.environment, false))
 	return nil, nil
 }
 
@@ -591,59 +687,25 @@ func (i *Interpreter) VisitPrintStmt(stmt *parser.Print) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("%v\n", result)
+	fmt.Fprintf(i.out, "%v\n", result)
 	return nil, nil
 }
 
 func (i *Interpreter) VisitReturnStmt(stmt *parser.Return) (interface{}, error) {
-	// if i.block == nil {
-	// 	return nil, NewRuntimeError("Invalid return usage in line %d.", stmt.Keyword.GetLine())
-	// }
-
-	recursiveBlockStop(i.block)
-	recursiveWhileStop(i.while)
 	value, err := i.evaluate(stmt.Value)
 	if err != nil {
 		return nil, err
 	}
-	if function, ok := i.stack.callee.(*LoxCustomFunc); ok {
-		if function.isInitializer {
-			value = function.parentEnvironment.getAt(0, "this")
-		}
-	}
 
-	if i.stack.call != nil {
-		i.stack.call.ReturnValue = value
-	}
-
-	return nil, nil
+	return nil, newReturnSignal(stmt.Keyword, value)
 }
 
 func (i *Interpreter) VisitBreakStmt(stmt *parser.Break) (interface{}, error) {
-	if stmt.Parent == nil {
-		return nil, NewRuntimeError(stmt.Token, "Invalid break usage in line %d.", stmt.Token.GetLine())
-	}
-	if while, ok := stmt.Parent.(*parser.While); ok {
-		while.Stop = true
-	}
-	if stmt.Block != nil {
-		if block, ok := stmt.Block.(*parser.Block); ok {
-			block.Stop = true
-		}
-	}
-
-	return nil, nil
+	return nil, newBreakSignal(stmt.Keyword)
 }
 
 func (i *Interpreter) VisitContinueStmt(stmt *parser.Continue) (interface{}, error) {
-	if stmt.Block == nil {
-		return nil, NewRuntimeError(stmt.Token, "Invalid break usage in line %d.", stmt.Token.GetLine())
-	}
-
-	if block, ok := stmt.Block.(*parser.Block); ok {
-		block.Stop = true
-	}
-	return nil, nil
+	return nil, newContinueSignal(stmt.Keyword)
 }
 
 func (i *Interpreter) VisitWhileStmt(stmt *parser.While) (interface{}, error) {
@@ -651,14 +713,8 @@ func (i *Interpreter) VisitWhileStmt(stmt *parser.While) (interface{}, error) {
 		r   interface{}
 		err error
 	)
-	defer i.newWhileState(stmt)()
 
 	for {
-		if stmt.Stop {
-			stmt.Stop = false
-			break
-		}
-
 		r, err = i.evaluate(stmt.Condition)
 		if err != nil {
 			return nil, err
@@ -668,15 +724,124 @@ func (i *Interpreter) VisitWhileStmt(stmt *parser.While) (interface{}, error) {
 		}
 		_, err = i.execute(stmt.Body)
 		if err != nil {
+			if _, ok := err.(*BreakSignal); ok {
+				break
+			} else if _, ok := err.(*ContinueSignal); !ok {
+				return nil, err
+			}
+		}
+		if _, err = i.evaluate(stmt.Increment); err != nil {
 			return nil, err
 		}
 	}
 
+	return nil, nil
+}
+
+func (i *Interpreter) VisitForInStmt(stmt *parser.ForIn) (interface{}, error) {
+	iterable, err := i.evaluate(stmt.Iterable)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := i.iterator(iterable)
+	if err != nil {
+		return nil, i.traceError(err, stmt.In)
+	}
+
+	for {
+		value, ok, err := iterator.Next()
+		if err != nil {
+			return nil, i.traceError(err, stmt.In)
+		}
+		if !ok {
+			break
+		}
+		// 每次迭代使用新的环境, 闭包捕获的是当次迭代的值
+		environ := NewEnvironment(i.environment)
+		environ.define(stmt.Name.GetValue(), value)
+		_, err = i.executeIn(stmt.Body, environ)
+		if err != nil {
+			if _, ok := err.(*BreakSignal); ok {
+				break
+			} else if _, ok := err.(*ContinueSignal); !ok {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func (i *Interpreter) VisitTryStmt(stmt *parser.Try) (interface{}, error) {
+	_, err := i.execute(stmt.Body)
+	if IsCatchable(err) && stmt.CatchBody != nil {
+		environ := NewEnvironment(i.environment)
+		if stmt.CatchName != nil {
+			environ.define(stmt.CatchName.GetValue(), ErrorValue(err))
+		}
+		_, err = i.executeIn(stmt.CatchBody, environ)
+	}
+	if stmt.FinallyBody != nil {
+		// finally中的错误与控制流会覆盖之前的结果
+		if _, finallyErr := i.execute(stmt.FinallyBody); finallyErr != nil {
+			return nil, finallyErr
+		}
+	}
 	return nil, err
 }
 
+func (i *Interpreter) VisitThrowStmt(stmt *parser.Throw) (interface{}, error) {
+	value, err := i.evaluate(stmt.Value)
+	if err != nil {
+		return nil, err
+	}
+	return nil, i.traceError(NewThrowError(stmt.Keyword, value), stmt.Keyword)
+}
+
+func (i *Interpreter) VisitImportStmt(stmt *parser.Import) (interface{}, error) {
+	path := stmt.Path.GetLiteral().(string)
+	module, err := i.modules.Load(path, stmt.Path.GetSource().GetName(), i.evalModule)
+	if _, ok := err.(*RuntimeError); ok {
+		return nil, i.traceError(err, stmt.Path)
+	} else if err != nil {
+		// 模块中的语法错误已带有位置信息
+		return nil, err
+	}
+	i.environment.define(stmt.Name.GetValue(), module)
+	return nil, nil
+}
+
+// 在单独的全局环境中执行模块
+func (i *Interpreter) evalModule(path string, r io.Reader) (*LoxModule, error) {
+	statements, err := parser.ParseSource(path, r)
+	if err != nil {
+		return nil, err
+	}
+	if err = NewResolver(i).ResolveStmts(statements); err != nil {
+		return nil, err
+	}
+
+	globals := NewGlobalEnvironment(i.builtins)
+	globals.name = path
+	defer i.newEnvironmentState(globals)()
+	for _, stmt := range statements {
+		if _, err = i.execute(stmt); err != nil {
+			return nil, signalToError(err)
+		}
+	}
+	return NewLoxModule(path, ExportedNames(statements), globals.getWithBool), nil
+}
+
+func (i *Interpreter) VisitExportStmt(stmt *parser.Export) (interface{}, error) {
+	return i.execute(stmt.Declaration)
+}
+
 func (i *Interpreter) VisitVarStmt(stmt *parser.Var) (interface{}, error) {
 	for n := range stmt.Names {
+		if stmt.Initializers[n] == nil {
+			i.environment.declare(stmt.Names[n].GetValue())
+			continue
+		}
 		result, err := i.evaluate(stmt.Initializers[n])
 
 		if err != nil {
@@ -694,9 +859,27 @@ func (i *Interpreter) VisitBlockStmt(stmt *parser.Block) (interface{}, error) {
 }
 
 func (i *Interpreter) VisitClassStmt(stmt *parser.Class) (interface{}, error) {
+	var superclass *LoxClass = nil
+
 	tokenName := stmt.Name.GetValue()
+	if stmt.Superclass != nil {
+		value, err := i.evaluate(stmt.Superclass)
+		if err != nil {
+			return nil, err
+		}
+		if class, ok := value.(*LoxClass); !ok {
+			return nil, NewRuntimeError(stmt.Superclass.Name, "Superclass must be a class.")
+		} else {
+			superclass = class
+		}
+	}
 	i.environment.define(tokenName, nil)
 
+	if superclass != nil {
+		defer i.newEnvironmentState(NewEnvironment(i.environment))()
+		i.environment.define("super", superclass)
+	}
+
 	methods := make(map[string]*LoxCustomFunc)
 	for _, method := range stmt.Methods {
 		if methodFunction, ok := method.(*parser.Function); !ok {
@@ -707,8 +890,19 @@ func (i *Interpreter) VisitClassStmt(stmt *parser.Class) (interface{}, error) {
 		}
 	}
 
-	class := NewLoxClass(tokenName, methods)
-	i.environment.assign(tokenName, class)
+	classMethods := make(map[string]*LoxCustomFunc)
+	for _, method := range stmt.ClassMethods {
+		if methodFunction, ok := method.(*parser.Function); !ok {
+			return nil, NewRuntimeError(stmt.Name, "Invalid class method")
+		} else {
+			classMethods[methodFunction.Name.GetValue()] = NewLoxCustomFunc(methodFunction, i.environment, false)
+		}
+	}
+
+	class := NewLoxClass(tokenName, superclass, methods, classMethods)
+	if err := i.environment.assign(stmt.Name, class); err != nil {
+		return nil, err
+	}
 
 	return class, nil
 }
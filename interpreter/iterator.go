@@ -0,0 +1,105 @@
+package interpreter
+
+import "unicode/utf8"
+
+// for-in循环使用的迭代器, ok为false时迭代结束
+type Iterator interface {
+	Next() (value interface{}, ok bool, err error)
+}
+
+// 由函数实现的迭代器
+type IteratorFunc func() (interface{}, bool, error)
+
+func (f IteratorFunc) Next() (interface{}, bool, error) {
+	return f()
+}
+
+type arrayIterator struct {
+	array []interface{}
+	index int
+}
+
+func (it *arrayIterator) Next() (interface{}, bool, error) {
+	if it.index >= len(it.array) {
+		return nil, false, nil
+	}
+	it.index++
+	return it.array[it.index-1], true, nil
+}
+
+// 按字符迭代字符串
+type stringIterator struct {
+	str    string
+	offset int
+}
+
+func (it *stringIterator) Next() (interface{}, bool, error) {
+	if it.offset >= len(it.str) {
+		return nil, false, nil
+	}
+	_, size := utf8.DecodeRuneInString(it.str[it.offset:])
+	it.offset += size
+	return it.str[it.offset-size : it.offset], true, nil
+}
+
+// 获取数组, 字符串与字典的迭代器, 字典按插入顺序迭代键
+func NewIterator(value interface{}) (Iterator, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return &arrayIterator{array: v}, nil
+	case string:
+		return &stringIterator{str: v}, nil
+	case *LoxMap:
+		return &arrayIterator{array: v.Keys()}, nil
+	case goContainer:
+		return v.Iterator(), nil
+	case Iterator:
+		return v, nil
+	}
+	return nil, NewRuntimeError(nil, "Can only iterate over arrays, strings, maps and iterators, got %v[%T].", value, value)
+}
+
+// 获取实例的迭代器:
+// 实例有iter()方法时使用其返回值进行迭代, 否则实例自身需要有next()方法, next()返回nil时迭代结束
+func (i *Interpreter) iterator(value interface{}) (Iterator, error) {
+	instance, ok := value.(*LoxInstance)
+	if !ok {
+		return NewIterator(value)
+	}
+
+	iter, err := iteratorMethod(instance, "iter")
+	if err != nil {
+		return nil, err
+	}
+	if iter != nil {
+		result, err := iter.Call(i, nil)
+		if err != nil {
+			return nil, err
+		}
+		if result != value {
+			return i.iterator(result)
+		}
+	}
+	next, err := iteratorMethod(instance, "next")
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, NewRuntimeError(nil, "Can't iterate over %v, it has no 'iter' or 'next' method.", instance)
+	}
+	return IteratorFunc(func() (interface{}, bool, error) {
+		result, err := next.Call(i, nil)
+		return result, result != nil && err == nil, err
+	}), nil
+}
+
+func iteratorMethod(instance *LoxInstance, name string) (*LoxCustomFunc, error) {
+	method := instance.class.findMethod(name)
+	if method == nil {
+		return nil, nil
+	}
+	if min, _ := method.ArityRange(); min != 0 {
+		return nil, NewRuntimeError(nil, "Iterator method '%s' must take no arguments.", name)
+	}
+	return method.bind(instance), nil
+}
@@ -0,0 +1,395 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/WAY29/LoxGo/lexer"
+	"github.com/WAY29/LoxGo/parser"
+)
+
+// 静态检查的规则, 可以用注释 // lint:ignore rule 忽略下一行(或行尾注释所在行)的诊断信息,
+// // lint:file-ignore rule 忽略整个文件, 不指定规则时忽略所有规则
+const (
+	RuleUnusedVariable       = "unused-variable"
+	RuleUnusedParameter      = "unused-parameter"
+	RuleShadowing            = "shadowing"
+	RuleUnreachableCode      = "unreachable-code"
+	RuleUndeclaredAssignment = "undeclared-assignment"
+	RuleThisOutsideMethod    = "this-outside-method"
+	RuleWrongArity           = "wrong-arity"
+	RuleResolveError         = "resolve-error" // 解析器报告的其他错误
+)
+
+// 声明的名字的种类
+type SymbolKind int
+
+const (
+	SymbolVariable SymbolKind = iota
+	SymbolParameter
+	SymbolFunction
+	SymbolClass
+	SymbolModule
+	symbolInternal // this与super
+)
+
+var symbolKindNames = map[SymbolKind]string{
+	SymbolVariable:  "variable",
+	SymbolParameter: "parameter",
+	SymbolFunction:  "function",
+	SymbolClass:     "class",
+	SymbolModule:    "module",
+}
+
+func (k SymbolKind) String() string {
+	return symbolKindNames[k]
+}
+
+// 声明的名字及其引用
+type Symbol struct {
+	Name       *lexer.Token
+	Kind       SymbolKind
+	Global     bool
+	Decl       parser.Stmt    // 函数或类的声明, 由匿名函数初始化的变量为匿名函数的声明
+	References []*lexer.Token // 读取与赋值处的名字, 不包括声明
+}
+
+// 静态检查的结果
+type Analysis struct {
+	Diagnostics []*Diagnostic
+	Symbols     []*Symbol // 按声明的位置排列
+}
+
+// 查找声明或引用覆盖offset的名字, offset位于名字末尾时同样匹配
+func (a *Analysis) SymbolAt(offset int) (*Symbol, *lexer.Token) {
+	contains := func(token *lexer.Token) bool {
+		return token.GetStart().Offset <= offset && offset <= token.GetEnd().Offset
+	}
+	for _, symbol := range a.Symbols {
+		if contains(symbol.Name) {
+			return symbol, symbol.Name
+		}
+		for _, ref := range symbol.References {
+			if contains(ref) {
+				return symbol, ref
+			}
+		}
+	}
+	return nil, nil
+}
+
+// 静态检查发现的问题
+type Diagnostic struct {
+	Rule    string
+	Message string
+	Start   lexer.Position
+	End     lexer.Position
+	source  *lexer.Source
+}
+
+func (d *Diagnostic) Error() string {
+	return lexer.FormatError(d.source, d.Start, d.End, fmt.Sprintf("Lint warning [%s]: %s", d.Rule, d.Message))
+}
+
+func (d *Diagnostic) GetStart() lexer.Position {
+	return d.Start
+}
+
+// 对变量的调用, 检查结束后再确定被调用的函数
+type lintCall struct {
+	expr    *parser.Call
+	name    *lexer.Token
+	binding *binding
+}
+
+// 静态检查过程中收集的信息
+type lintState struct {
+	source      *lexer.Source
+	predefined  map[string]interface{}
+	globals     map[string]*binding
+	bindings    []*binding     // 按声明顺序排列的所有名字
+	globalRefs  []*lexer.Token // 没有对应局部变量的引用
+	assigns     []*lexer.Token // 没有对应局部变量的赋值
+	calls       []lintCall
+	diagnostics []*Diagnostic
+}
+
+func (s *lintState) report(start, end lexer.Position, rule, message string) {
+	s.diagnostics = append(s.diagnostics, &Diagnostic{
+		Rule:    rule,
+		Message: message,
+		Start:   start,
+		End:     end,
+		source:  s.source,
+	})
+}
+
+// 重复声明的全局变量不再视为确定的函数或类
+func (s *lintState) declareGlobal(name *lexer.Token, kind SymbolKind) {
+	if b, ok := s.globals[name.GetValue()]; ok {
+		b.assigned = true
+		b.refs = append(b.refs, name)
+		return
+	}
+	b := &binding{name: name, kind: kind, defined: true, global: true}
+	s.globals[name.GetValue()] = b
+	s.bindings = append(s.bindings, b)
+}
+
+// 记录对名字的引用, b为nil时为全局变量, 检查结束后再查找声明
+func (s *lintState) reference(name *lexer.Token, b *binding) {
+	if b != nil {
+		b.refs = append(b.refs, name)
+	} else {
+		s.globalRefs = append(s.globalRefs, name)
+	}
+}
+
+func (s *lintState) assignGlobal(name *lexer.Token) {
+	s.assigns = append(s.assigns, name)
+}
+
+func (s *lintState) call(expr *parser.Call, name *lexer.Token, b *binding) {
+	s.calls = append(s.calls, lintCall{expr: expr, name: name, binding: b})
+}
+
+func (s *lintState) reportShadowing(name *lexer.Token, outer *binding) {
+	if outer.name == nil {
+		return
+	}
+	s.report(name.GetStart(), name.GetEnd(), RuleShadowing,
+		fmt.Sprintf("'%s' shadows the declaration at %s.", name.GetValue(), outer.name.GetStart()))
+}
+
+func (s *lintState) checkUnused(scope map[string]*binding) {
+	for _, b := range scope {
+		if b.used || b.kind == symbolInternal {
+			continue
+		}
+		rule, what := RuleUnusedVariable, "Local variable"
+		switch b.kind {
+		case SymbolParameter:
+			rule, what = RuleUnusedParameter, "Parameter"
+		case SymbolFunction:
+			what = "Local function"
+		case SymbolClass:
+			what = "Local class"
+		case SymbolModule:
+			what = "Imported module"
+		}
+		s.report(b.name.GetStart(), b.name.GetEnd(), rule, fmt.Sprintf("%s '%s' is never used.", what, b.name.GetValue()))
+	}
+}
+
+// 所有声明都已知后检查全局变量的赋值与函数调用
+func (s *lintState) finish() {
+	for _, name := range s.globalRefs {
+		if b, ok := s.globals[name.GetValue()]; ok {
+			b.refs = append(b.refs, name)
+		}
+	}
+	for _, name := range s.assigns {
+		if b, ok := s.globals[name.GetValue()]; ok {
+			b.assigned = true
+		} else if _, ok := s.predefined[name.GetValue()]; !ok {
+			s.report(name.GetStart(), name.GetEnd(), RuleUndeclaredAssignment,
+				fmt.Sprintf("Assignment to undeclared variable '%s'.", name.GetValue()))
+		}
+	}
+	for _, call := range s.calls {
+		s.checkArity(call)
+	}
+}
+
+func (s *lintState) checkArity(call lintCall) {
+	for _, argument := range call.expr.Arguments {
+		if _, ok := argument.(*parser.Spread); ok {
+			return
+		}
+	}
+
+	var min, max int
+	b := call.binding
+	if b == nil {
+		b = s.globals[call.name.GetValue()]
+	}
+	if b != nil {
+		var ok bool
+		if min, max, ok = bindingArity(b); !ok {
+			return
+		}
+	} else if callee, ok := s.predefined[call.name.GetValue()].(LoxCallable); ok {
+		min, max = ArityRange(callee)
+	} else {
+		return
+	}
+
+	if message := arityMessage(min, max, len(call.expr.Arguments)); message != "" {
+		span := call.expr.Span()
+		s.report(span.Start, span.End, RuleWrongArity, message)
+	}
+}
+
+// 没有被重新赋值的函数与类的参数个数, 类的参数个数由init方法决定
+func bindingArity(b *binding) (int, int, bool) {
+	if b.assigned {
+		return 0, 0, false
+	}
+	switch decl := b.decl.(type) {
+	case *parser.Function:
+		min, max := declarationArity(decl)
+		return min, max, true
+	case *parser.Class:
+		for _, method := range decl.Methods {
+			if function := method.(*parser.Function); function.Name.GetValue() == "init" {
+				min, max := declarationArity(function)
+				return min, max, true
+			}
+		}
+		if decl.Superclass == nil {
+			return 0, 0, true
+		}
+		if b.super != nil {
+			return bindingArity(b.super)
+		}
+	}
+	return 0, 0, false
+}
+
+// 注释中的忽略规则, 空字符串表示所有规则
+type suppressions struct {
+	file  map[string]bool
+	lines map[int]map[string]bool
+}
+
+func newSuppressions(tokens []*lexer.Token) *suppressions {
+	s := &suppressions{
+		file:  make(map[string]bool),
+		lines: make(map[int]map[string]bool),
+	}
+	for n, token := range tokens {
+		for _, trivia := range token.GetTrivia() {
+			if !trivia.IsComment() {
+				continue
+			}
+			text := strings.TrimSpace(strings.TrimPrefix(trivia.Text, "//"))
+			if rules, ok := directive(text, "lint:file-ignore"); ok {
+				for _, rule := range rules {
+					s.file[rule] = true
+				}
+			} else if rules, ok := directive(text, "lint:ignore"); ok {
+				// 行尾注释作用于所在行, 单独一行的注释作用于下一行代码
+				line := token.GetLine()
+				if n > 0 && tokens[n-1].GetEnd().Line == trivia.Start.Line {
+					line = trivia.Start.Line
+				}
+				if s.lines[line] == nil {
+					s.lines[line] = make(map[string]bool)
+				}
+				for _, rule := range rules {
+					s.lines[line][rule] = true
+				}
+			}
+		}
+	}
+	return s
+}
+
+// 解析 name rule1, rule2 形式的指令, 没有规则时返回[""]
+func directive(text, name string) ([]string, bool) {
+	if !strings.HasPrefix(text, name) {
+		return nil, false
+	}
+	rest := text[len(name):]
+	if rest != "" && rest[0] != ' ' && rest[0] != ',' && rest[0] != '\t' {
+		return nil, false
+	}
+	rules := strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(rules) == 0 {
+		rules = []string{""}
+	}
+	return rules, true
+}
+
+func (s *suppressions) ignored(d *Diagnostic) bool {
+	if s.file[""] || s.file[d.Rule] {
+		return true
+	}
+	rules := s.lines[d.Start.Line]
+	return rules[""] || rules[d.Rule]
+}
+
+// 基于Resolver的静态检查
+type Linter struct {
+	predefined map[string]interface{}
+}
+
+// 内置函数作为预定义的全局变量
+func NewLinter() *Linter {
+	predefined := make(map[string]interface{})
+	for _, function := range NewBuiltinFuncs() {
+		predefined[function.name] = function
+	}
+	return &Linter{predefined: predefined}
+}
+
+// 定义宿主提供的全局变量, 对其赋值不会报告未声明, 函数同样检查参数个数
+func (l *Linter) Define(name string, value interface{}) {
+	l.predefined[name] = FromGoGlobal(name, value)
+}
+
+// 预定义的全局变量
+func (l *Linter) Predefined() map[string]interface{} {
+	return l.predefined
+}
+
+// 检查语法分析的结果, tokens用于读取忽略规则的注释, 诊断信息按位置排列
+func (l *Linter) Lint(tokens []*lexer.Token, statements []parser.Stmt) []*Diagnostic {
+	return l.Analyze(tokens, statements).Diagnostics
+}
+
+// 检查并收集所有名字的声明与引用
+func (l *Linter) Analyze(tokens []*lexer.Token, statements []parser.Stmt) *Analysis {
+	state := &lintState{
+		predefined: l.predefined,
+		globals:    make(map[string]*binding),
+	}
+	if len(tokens) > 0 {
+		state.source = tokens[0].GetSource()
+	}
+
+	resolver := NewResolver(nil)
+	resolver.lint = state
+	// 静态检查时解析错误被记录为诊断信息, 不会返回错误
+	_ = resolver.ResolveStmts(statements)
+	state.finish()
+
+	suppressed := newSuppressions(tokens)
+	diagnostics := make([]*Diagnostic, 0, len(state.diagnostics))
+	for _, d := range state.diagnostics {
+		if !suppressed.ignored(d) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Start.Offset < diagnostics[j].Start.Offset
+	})
+
+	symbols := make([]*Symbol, 0, len(state.bindings))
+	for _, b := range state.bindings {
+		symbols = append(symbols, &Symbol{
+			Name:       b.name,
+			Kind:       b.kind,
+			Global:     b.global,
+			Decl:       b.decl,
+			References: b.refs,
+		})
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].Name.GetStart().Offset < symbols[j].Name.GetStart().Offset
+	})
+	return &Analysis{Diagnostics: diagnostics, Symbols: symbols}
+}
@@ -0,0 +1,44 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/WAY29/LoxGo/parser"
+)
+
+func TestLint(t *testing.T) {
+	testCases := map[string][]string{
+		`fun f(a) { var b = 1; return; }`:                                 {"1:7 unused-parameter", "1:16 unused-variable"},
+		`fun f() { return 1; print 2; print 3; }`:                         {"1:21 unreachable-code"},
+		`while (true) { break; print 1; }`:                                {"1:23 unreachable-code"},
+		`var a = 1; fun f() { var a = 2; return a; }`:                     {"1:26 shadowing"},
+		`fun f(x) { return x; } f(); f(1, 2); f(1);`:                      {"1:24 wrong-arity", "1:29 wrong-arity"},
+		`fun f(x, y = 1, ...z) { return [x, y, z]; } f(); f(1, 2, 3, 4);`: {"1:45 wrong-arity"},
+		`class A { init(x) { this.x = x; } } class B < A {} B(); A(1);`:   {"1:52 wrong-arity"},
+		`fun f() {} f = clock; f(1);`:                                     {},
+		`len(); var g = fun (x) { return x; }; g(1, 2);`:                  {"1:1 wrong-arity", "1:39 wrong-arity"},
+		`x = 1; fun f() { y = 2; } var y; len = 3;`:                       {"1:1 undeclared-assignment"},
+		`print this; fun f() { return this; }`:                            {"1:7 this-outside-method", "1:30 this-outside-method"},
+		`{ var a; var a; print a; } return;`:                              {"1:14 resolve-error", "1:28 resolve-error"},
+		`{ var a; } // lint:ignore unused-variable`:                       {},
+		"{\n// lint:ignore\nvar a;\nvar b; }":                             {"4:5 unused-variable"},
+		"// lint:file-ignore unused-variable, shadowing\n{ var a; }":      {},
+		`{ import "m"; } try {} catch (e) {}`:                             {"1:10 unused-variable", "1:31 unused-variable"},
+	}
+
+	for source, expected := range testCases {
+		tokens, statements, err := parser.ParseTokens("", strings.NewReader(source))
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		got := make([]string, 0)
+		for _, d := range NewLinter().Lint(tokens, statements) {
+			got = append(got, fmt.Sprintf("%s %s", d.Start, d.Rule))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("%s:\nexpected %v\nbut got  %v", source, expected, got)
+		}
+	}
+}
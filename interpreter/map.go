@@ -0,0 +1,147 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/WAY29/LoxGo/decimal"
+)
+
+// 字典类型, 键可以是字符串, 数字或布尔值, 遍历时保持插入顺序
+type LoxMap struct {
+	keys   []interface{}
+	values map[interface{}]interface{}
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{
+		keys:   make([]interface{}, 0),
+		values: make(map[interface{}]interface{}),
+	}
+}
+
+// 大整数与定点小数不可比较, 作为键时以字符串形式保存
+type (
+	bigKey     string
+	decimalKey string
+)
+
+// 检查并规范化键, 值为整数的浮点数, 大整数, 定点小数与对应整数视为同一个键
+func mapKey(key interface{}) (interface{}, error) {
+	switch k := key.(type) {
+	case string, bool, int:
+		return k, nil
+	case int64:
+		return int(k), nil
+	case float64:
+		if i, ok := float642Int(k); ok {
+			return i, nil
+		}
+		return k, nil
+	case *big.Int:
+		if k.IsInt64() {
+			return int(k.Int64()), nil
+		}
+		return bigKey(k.String()), nil
+	case *decimal.Decimal:
+		if k.IsInt() {
+			return mapKey(k.Int())
+		}
+		return decimalKey(k.Normalize().String()), nil
+	}
+	return nil, NewRuntimeError(nil, "Map key must be string, number or bool, got %v[%T].", key, key)
+}
+
+// 将规范化的键还原为值
+func keyValue(key interface{}) interface{} {
+	switch k := key.(type) {
+	case bigKey:
+		v, _ := new(big.Int).SetString(string(k), 10)
+		return v
+	case decimalKey:
+		v, _ := decimal.Parse(string(k))
+		return v
+	}
+	return key
+}
+
+func (m *LoxMap) Get(key interface{}) (interface{}, bool, error) {
+	key, err := mapKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := m.values[key]
+	return value, ok, nil
+}
+
+func (m *LoxMap) Set(key, value interface{}) error {
+	key, err := mapKey(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+	return nil
+}
+
+func (m *LoxMap) Has(key interface{}) (bool, error) {
+	_, ok, err := m.Get(key)
+	return ok, err
+}
+
+// 删除键, 返回键是否存在
+func (m *LoxMap) Delete(key interface{}) (bool, error) {
+	key, err := mapKey(key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := m.values[key]; !ok {
+		return false, nil
+	}
+	delete(m.values, key)
+	for n, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:n], m.keys[n+1:]...)
+			break
+		}
+	}
+	return true, nil
+}
+
+func (m *LoxMap) Len() int {
+	return len(m.keys)
+}
+
+// 按插入顺序返回所有键
+func (m *LoxMap) Keys() []interface{} {
+	keys := make([]interface{}, len(m.keys))
+	for n, key := range m.keys {
+		keys[n] = keyValue(key)
+	}
+	return keys
+}
+
+// 按插入顺序返回所有值
+func (m *LoxMap) Values() []interface{} {
+	values := make([]interface{}, len(m.keys))
+	for n, key := range m.keys {
+		values[n] = m.values[key]
+	}
+	return values
+}
+
+func (m *LoxMap) String() string {
+	builder := &strings.Builder{}
+	builder.WriteString("{")
+	for n, key := range m.keys {
+		if n > 0 {
+			builder.WriteString(", ")
+		}
+		fmt.Fprintf(builder, "%v: %v", key, m.values[key])
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
@@ -0,0 +1,187 @@
+package interpreter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/WAY29/LoxGo/parser"
+)
+
+// 模块对象, 只能访问模块导出的名字
+type LoxModule struct {
+	path    string
+	exports map[string]bool
+	lookup  func(name string) (interface{}, bool)
+}
+
+func NewLoxModule(path string, exports []string, lookup func(name string) (interface{}, bool)) *LoxModule {
+	module := &LoxModule{
+		path:    path,
+		exports: make(map[string]bool, len(exports)),
+		lookup:  lookup,
+	}
+	for _, name := range exports {
+		module.exports[name] = true
+	}
+	return module
+}
+
+func (m *LoxModule) Get(name string) (interface{}, error) {
+	if !m.exports[name] {
+		return nil, NewRuntimeError(nil, "Module '%s' has no exported name '%s'.", m.Name(), name)
+	}
+	value, _ := m.lookup(name)
+	return value, nil
+}
+
+// 模块名, 即不含扩展名的文件名
+func (m *LoxModule) Name() string {
+	base := filepath.Base(m.path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func (m *LoxModule) Path() string {
+	return m.path
+}
+
+// 按名字排序的导出列表
+func (m *LoxModule) Exports() []string {
+	names := make([]string, 0, len(m.exports))
+	for name := range m.exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *LoxModule) String() string {
+	return "<module " + m.Name() + ">"
+}
+
+// 收集顶层export声明导出的名字
+func ExportedNames(statements []parser.Stmt) []string {
+	names := make([]string, 0)
+	for _, stmt := range statements {
+		export, ok := stmt.(*parser.Export)
+		if !ok {
+			continue
+		}
+		switch decl := export.Declaration.(type) {
+		case *parser.Function:
+			names = append(names, decl.Name.GetValue())
+		case *parser.Class:
+			names = append(names, decl.Name.GetValue())
+		case *parser.Var:
+			for _, name := range decl.Names {
+				names = append(names, name.GetValue())
+			}
+		}
+	}
+	return names
+}
+
+// 执行模块源码并返回模块对象
+type ModuleEvaluator func(path string, r io.Reader) (*LoxModule, error)
+
+// 模块加载器, 负责查找模块文件, 缓存已加载的模块并检测循环导入
+type ModuleLoader struct {
+	paths   []string
+	modules map[string]*LoxModule
+	loading []string
+}
+
+func NewModuleLoader() *ModuleLoader {
+	return &ModuleLoader{
+		paths:   make([]string, 0),
+		modules: make(map[string]*LoxModule),
+		loading: make([]string, 0),
+	}
+}
+
+// 设置模块搜索路径, 相对路径的模块在导入文件所在目录找不到时依次在搜索路径中查找
+func (l *ModuleLoader) SetPath(paths ...string) {
+	l.paths = paths
+}
+
+// 查找模块文件的绝对路径, from为导入模块的文件名, 为空时相对于当前目录
+func (l *ModuleLoader) Resolve(path, from string) (string, error) {
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(filepath.Dir(from), path)}
+		for _, dir := range l.paths {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+	for _, candidate := range candidates {
+		names := []string{candidate}
+		// 省略扩展名时补全.lox
+		if filepath.Ext(candidate) == "" {
+			names = append(names, candidate+".lox")
+		}
+		for _, name := range names {
+			if info, err := os.Stat(name); err == nil && !info.IsDir() {
+				return filepath.Abs(name)
+			}
+		}
+	}
+	return "", NewRuntimeError(nil, "Can't find module '%s'.", path)
+}
+
+// 加载模块, 同一个文件只会执行一次
+func (l *ModuleLoader) Load(path, from string, eval ModuleEvaluator) (*LoxModule, error) {
+	abs, err := l.Resolve(path, from)
+	if err != nil {
+		return nil, err
+	}
+	if module, ok := l.modules[abs]; ok {
+		return module, nil
+	}
+	for n, loading := range l.loading {
+		if loading == abs {
+			return nil, NewRuntimeError(nil, "Import cycle: %s.", l.cycle(n))
+		}
+	}
+
+	file, err := os.Open(abs)
+	if err != nil {
+		return nil, NewRuntimeError(nil, "Can't open module '%s': %v", path, err)
+	}
+	defer file.Close()
+
+	l.loading = append(l.loading, abs)
+	module, err := eval(abs, file)
+	l.loading = l.loading[:len(l.loading)-1]
+	if err != nil {
+		return nil, err
+	}
+	l.modules[abs] = module
+	return module, nil
+}
+
+// 标记入口脚本正在执行, 使脚本被其导入的模块再次导入时报告循环导入, 返回结束执行时调用的函数
+// name不是已存在的文件(如REPL输入)时不做任何事
+func (l *ModuleLoader) Enter(name string) func() {
+	abs, err := filepath.Abs(name)
+	if name == "" || err != nil {
+		return func() {}
+	}
+	if info, err := os.Stat(abs); err != nil || info.IsDir() {
+		return func() {}
+	}
+	l.loading = append(l.loading, abs)
+	return func() {
+		l.loading = l.loading[:len(l.loading)-1]
+	}
+}
+
+// 从第n个正在加载的模块开始的导入链, 形如a.lox -> b.lox -> a.lox
+func (l *ModuleLoader) cycle(n int) string {
+	chain := append(append([]string{}, l.loading[n:]...), l.loading[n])
+	for k, path := range chain {
+		chain[k] = filepath.Base(path)
+	}
+	return strings.Join(chain, " -> ")
+}
@@ -0,0 +1,423 @@
+package interpreter
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/WAY29/LoxGo/decimal"
+	"github.com/WAY29/LoxGo/lexer"
+)
+
+// 数字分为64位整数(int), 大整数(*big.Int), 定点小数(*decimal.Decimal)与浮点数(float64):
+// 整数之间的+ - * % ** << 结果超出64位整数范围时自动使用大整数, 不会静默回绕, 带n后缀的字面量(如 1n)直接为大整数;
+// 大整数参与运算时结果仍为大整数, 可以使用int()转换回64位整数;
+// 整数相除能整除时结果为整数, 否则为浮点数; 整数除以0报错, 浮点数运算遵循IEEE 754;
+// 定点小数(如 19.99d)与整数运算时结果为定点小数, 运算精确, 除不尽时保留20位小数;
+// 定点小数不能与浮点数混合运算, 避免浮点误差混入; 整数与浮点数混合运算时按浮点数计算;
+// 位运算 & | ^ ~ << >> 只能用于整数与大整数
+
+// 大整数运算结果的最大位数, 避免 2 ** 2 ** 40 之类的运算耗尽内存
+const maxBigBits = 1 << 24
+
+type numberKind int
+
+const (
+	notNumber numberKind = iota
+	intNumber
+	bigNumber
+	decimalNumber
+	floatNumber
+)
+
+func kindOf(value interface{}) numberKind {
+	switch value.(type) {
+	case int, int64:
+		return intNumber
+	case *big.Int:
+		return bigNumber
+	case *decimal.Decimal:
+		return decimalNumber
+	case float64:
+		return floatNumber
+	}
+	return notNumber
+}
+
+func toBig(value interface{}) *big.Int {
+	switch v := value.(type) {
+	case int:
+		return big.NewInt(int64(v))
+	case int64:
+		return big.NewInt(v)
+	case *big.Int:
+		return v
+	}
+	return nil
+}
+
+func toDecimal(value interface{}) *decimal.Decimal {
+	if v, ok := value.(*decimal.Decimal); ok {
+		return v
+	}
+	if v := toBig(value); v != nil {
+		return decimal.FromBig(v)
+	}
+	return nil
+}
+
+func bigToFloat(v *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(v).Float64()
+	return f
+}
+
+// 大整数能用64位整数表示时转换为int
+func NormalizeInt(v *big.Int) interface{} {
+	if v.IsInt64() {
+		return int(v.Int64())
+	}
+	return v
+}
+
+func newIntegerTooLargeError() error {
+	return NewRuntimeError(nil, "Integer too large.")
+}
+
+func newDivisionByZeroError() error {
+	return NewRuntimeError(nil, "Division by zero.")
+}
+
+// 整数加法, 溢出时ok为false
+func AddInt(a, b int) (int, bool) {
+	c := a + b
+	return c, (c > a) == (b > 0)
+}
+
+// 整数减法, 溢出时ok为false
+func SubInt(a, b int) (int, bool) {
+	c := a - b
+	return c, (c < a) == (b > 0)
+}
+
+// 整数乘法, 溢出时ok为false
+func MulInt(a, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) || c/b != a {
+		return c, false
+	}
+	return c, true
+}
+
+// 向零取整的整数除法, 用于整数与大整数
+func IntDiv(a, b interface{}) (interface{}, error) {
+	if kind := kindOf(a); kind != intNumber && kind != bigNumber {
+		return nil, NewConvertError(a, "int", "")
+	}
+	if kind := kindOf(b); kind != intNumber && kind != bigNumber {
+		return nil, NewConvertError(b, "int", "")
+	}
+	if x, ok := a.(int); ok {
+		if y, ok := b.(int); ok && !(x == math.MinInt && y == -1) {
+			if y == 0 {
+				return nil, newDivisionByZeroError()
+			}
+			return x / y, nil
+		}
+	}
+	x, y := toBig(a), toBig(b)
+	if y.Sign() == 0 {
+		return nil, newDivisionByZeroError()
+	}
+	return new(big.Int).Quo(x, y), nil
+}
+
+// 整数乘方, 溢出时ok为false
+func powInt(a, b int) (int, bool) {
+	result := 1
+	for ok := true; b > 0; b >>= 1 {
+		if b&1 == 1 {
+			if result, ok = MulInt(result, a); !ok {
+				return 0, false
+			}
+		}
+		if b > 1 {
+			if a, ok = MulInt(a, a); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+func newNegativeShiftError() error {
+	return NewRuntimeError(nil, "Negative shift count.")
+}
+
+// 是否为只能用于整数的位运算
+func isBitwise(operator lexer.TokenType) bool {
+	switch operator {
+	case lexer.AMPERSAND, lexer.PIPE, lexer.CARET, lexer.LESS_LESS, lexer.GREATER_GREATER:
+		return true
+	}
+	return false
+}
+
+func intOperate(operator lexer.TokenType, a, b int) (interface{}, error) {
+	switch operator {
+	case lexer.PLUS:
+		if result, ok := AddInt(a, b); ok {
+			return result, nil
+		}
+	case lexer.MINUS:
+		if result, ok := SubInt(a, b); ok {
+			return result, nil
+		}
+	case lexer.STAR:
+		if result, ok := MulInt(a, b); ok {
+			return result, nil
+		}
+	case lexer.SLASH:
+		if b == 0 {
+			return nil, newDivisionByZeroError()
+		}
+		if a%b != 0 {
+			return float64(a) / float64(b), nil
+		}
+		if !(a == math.MinInt && b == -1) {
+			return a / b, nil
+		}
+	case lexer.PERCENT:
+		if b == 0 {
+			return nil, newDivisionByZeroError()
+		}
+		if b == -1 {
+			return 0, nil
+		}
+		return a % b, nil
+	case lexer.STAR_STAR:
+		if b < 0 {
+			return math.Pow(float64(a), float64(b)), nil
+		}
+		if result, ok := powInt(a, b); ok {
+			return result, nil
+		}
+	case lexer.AMPERSAND:
+		return a & b, nil
+	case lexer.PIPE:
+		return a | b, nil
+	case lexer.CARET:
+		return a ^ b, nil
+	case lexer.LESS_LESS:
+		if b < 0 {
+			return nil, newNegativeShiftError()
+		}
+		if a == 0 {
+			return 0, nil
+		}
+		if b < 64 && (a<<b)>>b == a {
+			return a << b, nil
+		}
+	case lexer.GREATER_GREATER:
+		// 算术右移, 负数高位补1
+		if b < 0 {
+			return nil, newNegativeShiftError()
+		}
+		if b >= 64 {
+			b = 63
+		}
+		return a >> b, nil
+	case lexer.GREATER:
+		return a > b, nil
+	case lexer.GREATER_EQUAL:
+		return a >= b, nil
+	case lexer.LESS:
+		return a < b, nil
+	case lexer.LESS_EQUAL:
+		return a <= b, nil
+	default:
+		return nil, NewConvertError(nil, "", "invalid binary expression")
+	}
+	// 超出64位整数范围, 改用大整数计算
+	return bigOperate(operator, big.NewInt(int64(a)), big.NewInt(int64(b)))
+}
+
+func bigOperate(operator lexer.TokenType, a, b *big.Int) (interface{}, error) {
+	switch operator {
+	case lexer.PLUS:
+		return new(big.Int).Add(a, b), nil
+	case lexer.MINUS:
+		return new(big.Int).Sub(a, b), nil
+	case lexer.STAR:
+		return new(big.Int).Mul(a, b), nil
+	case lexer.SLASH:
+		if b.Sign() == 0 {
+			return nil, newDivisionByZeroError()
+		}
+		quotient, remainder := new(big.Int).QuoRem(a, b, new(big.Int))
+		if remainder.Sign() != 0 {
+			f, _ := new(big.Rat).SetFrac(a, b).Float64()
+			return f, nil
+		}
+		return quotient, nil
+	case lexer.PERCENT:
+		if b.Sign() == 0 {
+			return nil, newDivisionByZeroError()
+		}
+		return new(big.Int).Rem(a, b), nil
+	case lexer.STAR_STAR:
+		if b.Sign() < 0 {
+			return math.Pow(bigToFloat(a), bigToFloat(b)), nil
+		}
+		if a.CmpAbs(big.NewInt(1)) > 0 && (!b.IsInt64() || int64(a.BitLen()-1)*b.Int64() > maxBigBits) {
+			return nil, newIntegerTooLargeError()
+		}
+		return new(big.Int).Exp(a, b, nil), nil
+	case lexer.AMPERSAND:
+		return new(big.Int).And(a, b), nil
+	case lexer.PIPE:
+		return new(big.Int).Or(a, b), nil
+	case lexer.CARET:
+		return new(big.Int).Xor(a, b), nil
+	case lexer.LESS_LESS:
+		if b.Sign() < 0 {
+			return nil, newNegativeShiftError()
+		}
+		if a.Sign() == 0 {
+			return new(big.Int), nil
+		}
+		if !b.IsInt64() || int64(a.BitLen())+b.Int64() > maxBigBits {
+			return nil, newIntegerTooLargeError()
+		}
+		return new(big.Int).Lsh(a, uint(b.Int64())), nil
+	case lexer.GREATER_GREATER:
+		if b.Sign() < 0 {
+			return nil, newNegativeShiftError()
+		}
+		if !b.IsInt64() || b.Int64() > int64(a.BitLen()) {
+			return big.NewInt(int64(a.Sign() >> 1)), nil
+		}
+		return new(big.Int).Rsh(a, uint(b.Int64())), nil
+	case lexer.GREATER:
+		return a.Cmp(b) > 0, nil
+	case lexer.GREATER_EQUAL:
+		return a.Cmp(b) >= 0, nil
+	case lexer.LESS:
+		return a.Cmp(b) < 0, nil
+	case lexer.LESS_EQUAL:
+		return a.Cmp(b) <= 0, nil
+	}
+	return nil, NewConvertError(nil, "", "invalid binary expression")
+}
+
+func decimalOperate(operator lexer.TokenType, a, b *decimal.Decimal) (interface{}, error) {
+	switch operator {
+	case lexer.PLUS:
+		return a.Add(b), nil
+	case lexer.MINUS:
+		return a.Sub(b), nil
+	case lexer.STAR:
+		return a.Mul(b), nil
+	case lexer.SLASH:
+		if b.Sign() == 0 {
+			return nil, newDivisionByZeroError()
+		}
+		return a.Quo(b)
+	case lexer.PERCENT:
+		if b.Sign() == 0 {
+			return nil, newDivisionByZeroError()
+		}
+		return a.Rem(b)
+	case lexer.STAR_STAR:
+		// 指数只能是整数, 否则结果无法精确表示
+		if !b.IsInt() {
+			return nil, NewRuntimeError(nil, "Exponent of decimal must be an integer, got %v.", b)
+		}
+		n := b.Int()
+		if !n.IsInt64() || n.Int64() > maxBigBits/4 || n.Int64() < -maxBigBits/4 {
+			return nil, newIntegerTooLargeError()
+		}
+		if a.Sign() == 0 && n.Sign() < 0 {
+			return nil, newDivisionByZeroError()
+		}
+		return a.Pow(int(n.Int64()))
+	case lexer.GREATER:
+		return a.Cmp(b) > 0, nil
+	case lexer.GREATER_EQUAL:
+		return a.Cmp(b) >= 0, nil
+	case lexer.LESS:
+		return a.Cmp(b) < 0, nil
+	case lexer.LESS_EQUAL:
+		return a.Cmp(b) <= 0, nil
+	}
+	return nil, NewConvertError(nil, "", "invalid binary expression")
+}
+
+func floatOperate(operator lexer.TokenType, a, b float64) (interface{}, error) {
+	switch operator {
+	case lexer.PLUS:
+		return a + b, nil
+	case lexer.MINUS:
+		return a - b, nil
+	case lexer.STAR:
+		return a * b, nil
+	case lexer.SLASH:
+		return a / b, nil
+	case lexer.PERCENT:
+		return math.Mod(a, b), nil
+	case lexer.STAR_STAR:
+		return math.Pow(a, b), nil
+	case lexer.GREATER:
+		return a > b, nil
+	case lexer.GREATER_EQUAL:
+		return a >= b, nil
+	case lexer.LESS:
+		return a < b, nil
+	case lexer.LESS_EQUAL:
+		return a <= b, nil
+	}
+	return nil, NewConvertError(nil, "", "invalid binary expression")
+}
+
+// 转换为有理数用于精确比较, 非有限的浮点数返回false
+func toRat(value interface{}) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(v), true
+	case *decimal.Decimal:
+		return v.Rat(), true
+	}
+	if v := toBig(value); v != nil {
+		return new(big.Rat).SetInt(v), true
+	}
+	return nil, false
+}
+
+// 数字相等时不区分数字类型, 1 == 1.0 == 1n == 1.00d
+func numberEqual(a, b interface{}) (equal bool, isNumber bool) {
+	ak, bk := kindOf(a), kindOf(b)
+	if ak == notNumber || bk == notNumber {
+		return false, false
+	}
+	if ak == intNumber && bk == intNumber {
+		x, _ := interfaceToInt(a)
+		y, _ := interfaceToInt(b)
+		return x == y, true
+	}
+	if ak == floatNumber && bk == floatNumber {
+		return a.(float64) == b.(float64), true
+	}
+	x, ok := toRat(a)
+	if !ok {
+		return false, true
+	}
+	y, ok := toRat(b)
+	if !ok {
+		return false, true
+	}
+	return x.Cmp(y) == 0, true
+}
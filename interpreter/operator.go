@@ -0,0 +1,182 @@
+package interpreter
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/WAY29/LoxGo/decimal"
+	"github.com/WAY29/LoxGo/lexer"
+)
+
+// 二元与一元运算的语义, 由解释器与vm共用
+
+func UnaryOperate(operator lexer.TokenType, right interface{}) (interface{}, error) {
+	switch operator {
+	case lexer.MINUS:
+		switch v := right.(type) {
+		case *big.Int:
+			return new(big.Int).Neg(v), nil
+		case *decimal.Decimal:
+			return v.Neg(), nil
+		}
+		if v, ok := interfaceToInt(right); ok {
+			if v == math.MinInt {
+				return new(big.Int).Neg(big.NewInt(int64(v))), nil
+			}
+			return -v, nil
+		}
+		if v, ok := interfaceToFloat64(right); ok {
+			return -v, nil
+		}
+		return nil, NewConvertError(right, "float", "")
+	case lexer.PLUS:
+		if kindOf(right) != notNumber {
+			return right, nil
+		}
+		return nil, NewConvertError(right, "float", "")
+	case lexer.TILDE:
+		switch v := right.(type) {
+		case int:
+			return ^v, nil
+		case int64:
+			return int(^v), nil
+		case *big.Int:
+			return new(big.Int).Not(v), nil
+		}
+		return nil, NewConvertError(right, "int", "")
+	case lexer.BANG:
+		return !isTruthy(right), nil
+	}
+
+	return nil, NewConvertError(right, "float", "invalid unary expression")
+}
+
+func BinaryOperate(operator lexer.TokenType, left, right interface{}) (interface{}, error) {
+	switch operator {
+	case lexer.EQUAL_EQUAL:
+		return isEqual(left, right), nil
+	case lexer.BANG_EQUAL:
+		return !isEqual(left, right), nil
+	case lexer.PLUS:
+		if v, ok := left.(string); ok {
+			if v2, ok := right.(string); ok {
+				return v + v2, nil
+			}
+			return nil, NewConvertError(right, "string", "")
+		}
+	}
+
+	leftKind, rightKind := kindOf(left), kindOf(right)
+	if isBitwise(operator) {
+		if leftKind != intNumber && leftKind != bigNumber {
+			return nil, NewConvertError(left, "int", "")
+		}
+		if rightKind != intNumber && rightKind != bigNumber {
+			return nil, NewConvertError(right, "int", "")
+		}
+	}
+	if leftKind == notNumber {
+		if operator == lexer.PLUS {
+			return nil, NewConvertError(left, "string / float", "")
+		}
+		return nil, NewConvertError(left, "float", "")
+	}
+	if rightKind == notNumber {
+		return nil, NewConvertError(right, "float", "")
+	}
+
+	// 按精度更高的一方计算, 两个整数运算时不经过浮点数, 避免丢失精度
+	kind := leftKind
+	if rightKind > kind {
+		kind = rightKind
+	}
+	switch kind {
+	case intNumber:
+		a, _ := interfaceToInt(left)
+		b, _ := interfaceToInt(right)
+		return intOperate(operator, a, b)
+	case bigNumber:
+		return bigOperate(operator, toBig(left), toBig(right))
+	case decimalNumber:
+		return decimalOperate(operator, toDecimal(left), toDecimal(right))
+	}
+	if leftKind == decimalNumber || rightKind == decimalNumber {
+		return nil, NewRuntimeError(nil, "Can't mix decimal and float, convert with decimal() or float() first.")
+	}
+	f, _ := interfaceToFloat64(left)
+	f2, _ := interfaceToFloat64(right)
+	return floatOperate(operator, f, f2)
+}
+
+func NewSpreadError(value interface{}) error {
+	return NewRuntimeError(nil, "Can only spread arrays, got %v[%T].", value, value)
+}
+
+// 展开运算的元素, Go切片展开为其元素的副本
+func SpreadElements(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case *GoSlice:
+		return v.Elements(), nil
+	}
+	return nil, NewSpreadError(value)
+}
+
+func checkIndex(index, length int) error {
+	if index < 0 {
+		return NewRuntimeError(nil, "Array index can't be negative, got %d.", index)
+	}
+	if index >= length {
+		return NewRuntimeError(nil, "Array index %d out of range, length is %d.", index, length)
+	}
+	return nil
+}
+
+func IndexOperate(object, indexInterface interface{}) (interface{}, error) {
+	if m, ok := object.(*LoxMap); ok {
+		value, ok, err := m.Get(indexInterface)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, NewRuntimeError(nil, "Undefined key '%v'.", indexInterface)
+		}
+		return value, nil
+	}
+	if array, ok := object.([]interface{}); ok {
+		index, ok := interfaceToInt(indexInterface)
+		if !ok {
+			return nil, NewRuntimeError(nil, "Index must be int.")
+		}
+		if err := checkIndex(index, len(array)); err != nil {
+			return nil, err
+		}
+		return array[index], nil
+	}
+	if container, ok := object.(goContainer); ok {
+		return container.Get(indexInterface)
+	}
+	return nil, NewRuntimeError(nil, "Can only index array or map.")
+}
+
+func IndexSetOperate(object, indexInterface, value interface{}) error {
+	if m, ok := object.(*LoxMap); ok {
+		return m.Set(indexInterface, value)
+	}
+	if array, ok := object.([]interface{}); ok {
+		index, ok := interfaceToInt(indexInterface)
+		if !ok {
+			return NewRuntimeError(nil, "Index must be int.")
+		}
+		if err := checkIndex(index, len(array)); err != nil {
+			return err
+		}
+		array[index] = value
+		return nil
+	}
+	if container, ok := object.(goContainer); ok {
+		return container.Set(indexInterface, value)
+	}
+	return NewRuntimeError(nil, "Can only index array or map.")
+}
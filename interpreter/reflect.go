@@ -0,0 +1,547 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+type GoFunc struct { // Impl LoxCallable
+	name string
+	fn   reflect.Value
+}
+
+func NewGoFunc(name string, fn interface{}) *GoFunc {
+	return &GoFunc{
+		name: name,
+		fn:   reflect.ValueOf(fn),
+	}
+}
+
+func (f *GoFunc) Arity() int {
+	if f.fn.Type().IsVariadic() {
+		return -1
+	}
+	return f.fn.Type().NumIn()
+}
+
+func (f *GoFunc) ArityRange() (int, int) {
+	if f.fn.Type().IsVariadic() {
+		return f.fn.Type().NumIn() - 1, -1
+	}
+	return f.Arity(), f.Arity()
+}
+
+func (f *GoFunc) Call(interpreter *Interpreter, arguments []interface{}) (result interface{}, err error) {
+	defer recoverPanic(f.name, &err)
+
+	var (
+		fnType = f.fn.Type()
+		numIn  = fnType.NumIn()
+		in     = make([]reflect.Value, len(arguments))
+	)
+	if fnType.IsVariadic() && len(arguments) < numIn-1 {
+		return nil, NewRuntimeError(nil, "Excepted at least %d arguments but got %d.", numIn-1, len(arguments))
+	}
+	for n, argument := range arguments {
+		var paramType reflect.Type
+		if fnType.IsVariadic() && n >= numIn-1 {
+			paramType = fnType.In(numIn - 1).Elem()
+		} else {
+			paramType = fnType.In(n)
+		}
+		if in[n], err = toGoValue(argument, paramType); err != nil {
+			return nil, err
+		}
+	}
+
+	out := f.fn.Call(in)
+	// 最后一个返回值为error时作为错误返回
+	if len(out) > 0 && fnType.Out(len(out)-1) == errorType {
+		if e := out[len(out)-1]; !e.IsNil() {
+			return nil, e.Interface().(error)
+		}
+		out = out[:len(out)-1]
+	}
+	switch len(out) {
+	case 0:
+		return nil, nil
+	case 1:
+		return fromGoValue(out[0]), nil
+	}
+	results := make([]interface{}, len(out))
+	for n, v := range out {
+		results[n] = fromGoValue(v)
+	}
+	return results, nil
+}
+
+func (f *GoFunc) String() string {
+	return fmt.Sprintf("<go-fn %s>", f.name)
+}
+
+func (f *GoFunc) Name() string {
+	return f.name
+}
+
+// 将Go值转换为Lox值, 结构体指针与map保持原样以便通过反射访问
+func FromGoValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return fromGoValue(reflect.ValueOf(value))
+}
+
+// 将宿主定义的全局变量转换为Lox值, Go函数以变量名命名
+func FromGoGlobal(name string, value interface{}) interface{} {
+	value = FromGoValue(value)
+	if function, ok := value.(*GoFunc); ok {
+		function.name = name
+	}
+	return value
+}
+
+func fromGoValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		// 超出64位整数范围的无符号整数使用大整数, 不会静默回绕
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return int(u)
+		}
+		return new(big.Int).SetUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return fromGoValue(v.Elem())
+	case reflect.Ptr, reflect.Map, reflect.Func:
+		if v.IsNil() {
+			return nil
+		}
+		switch v.Kind() {
+		case reflect.Func:
+			return &GoFunc{name: v.Type().String(), fn: v}
+		case reflect.Map:
+			return &GoMap{v: v}
+		}
+	case reflect.Slice, reflect.Array:
+		if array, ok := v.Interface().([]interface{}); ok {
+			return array
+		}
+		// 切片与可寻址的数组不复制, 使脚本中的修改能够生效
+		if v.Kind() == reflect.Slice || v.CanAddr() {
+			return &GoSlice{v: v}
+		}
+		array := make([]interface{}, v.Len())
+		for n := range array {
+			array[n] = fromGoValue(v.Index(n))
+		}
+		return array
+	}
+	return v.Interface()
+}
+
+// 结构体字段与切片元素的值, 结构体返回指针, 使脚本中的修改能够生效
+func elementValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Struct && v.CanAddr() {
+		return v.Addr().Interface()
+	}
+	return fromGoValue(v)
+}
+
+// 通过反射访问的Go切片与map, 支持下标读写, len与for-in
+type goContainer interface {
+	Len() int
+	Get(index interface{}) (interface{}, error)
+	Set(index, value interface{}) error
+	Iterator() Iterator
+}
+
+// Go的切片或数组, 通过下标读写的是原来的元素
+type GoSlice struct {
+	v reflect.Value
+}
+
+func (s *GoSlice) Len() int {
+	return s.v.Len()
+}
+
+func (s *GoSlice) index(indexInterface interface{}) (reflect.Value, error) {
+	index, ok := interfaceToInt(indexInterface)
+	if !ok {
+		return reflect.Value{}, NewRuntimeError(nil, "Index must be int.")
+	}
+	if err := checkIndex(index, s.v.Len()); err != nil {
+		return reflect.Value{}, err
+	}
+	return s.v.Index(index), nil
+}
+
+func (s *GoSlice) Get(index interface{}) (interface{}, error) {
+	element, err := s.index(index)
+	if err != nil {
+		return nil, err
+	}
+	return elementValue(element), nil
+}
+
+func (s *GoSlice) Set(index, value interface{}) error {
+	element, err := s.index(index)
+	if err != nil {
+		return err
+	}
+	if !element.CanSet() {
+		return NewRuntimeError(nil, "Can't set element of %s.", s.v.Type())
+	}
+	ev, err := toGoValue(value, element.Type())
+	if err != nil {
+		return err
+	}
+	element.Set(ev)
+	return nil
+}
+
+// 复制为Lox数组, 用于展开运算
+func (s *GoSlice) Elements() []interface{} {
+	array := make([]interface{}, s.v.Len())
+	for n := range array {
+		array[n] = elementValue(s.v.Index(n))
+	}
+	return array
+}
+
+// 按下标迭代, 每次读取当前的元素
+func (s *GoSlice) Iterator() Iterator {
+	index := 0
+	return IteratorFunc(func() (interface{}, bool, error) {
+		if index >= s.v.Len() {
+			return nil, false, nil
+		}
+		index++
+		return elementValue(s.v.Index(index - 1)), true, nil
+	})
+}
+
+// 包装的Go值
+func (s *GoSlice) Interface() interface{} {
+	return s.v.Interface()
+}
+
+func (s *GoSlice) String() string {
+	return fmt.Sprintf("%v", s.Elements())
+}
+
+// Go的map, 可以通过下标以任意类型的键读写, 也可以通过属性读写字符串键
+type GoMap struct {
+	v reflect.Value
+}
+
+func (m *GoMap) Len() int {
+	return m.v.Len()
+}
+
+func (m *GoMap) Get(key interface{}) (interface{}, error) {
+	kv, err := toGoValue(key, m.v.Type().Key())
+	if err != nil {
+		return nil, err
+	}
+	value := m.v.MapIndex(kv)
+	if !value.IsValid() {
+		return nil, NewRuntimeError(nil, "Undefined key '%v'.", key)
+	}
+	return fromGoValue(value), nil
+}
+
+func (m *GoMap) Set(key, value interface{}) error {
+	kv, err := toGoValue(key, m.v.Type().Key())
+	if err != nil {
+		return err
+	}
+	ev, err := toGoValue(value, m.v.Type().Elem())
+	if err != nil {
+		return err
+	}
+	m.v.SetMapIndex(kv, ev)
+	return nil
+}
+
+// 迭代开始时的所有键, 排序后保证顺序固定
+func (m *GoMap) Iterator() Iterator {
+	keys := m.v.MapKeys()
+	sort.Slice(keys, func(a, b int) bool {
+		return keyLess(keys[a], keys[b])
+	})
+	index := 0
+	return IteratorFunc(func() (interface{}, bool, error) {
+		if index >= len(keys) {
+			return nil, false, nil
+		}
+		index++
+		return fromGoValue(keys[index-1]), true, nil
+	})
+}
+
+func keyLess(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	}
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+// 包装的Go值
+func (m *GoMap) Interface() interface{} {
+	return m.v.Interface()
+}
+
+func (m *GoMap) String() string {
+	return fmt.Sprintf("%v", m.v.Interface())
+}
+
+// 将Lox值转换为指定类型的Go值
+func toGoValue(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+			return reflect.Zero(typ), nil
+		}
+		return reflect.Value{}, NewConvertError(nil, typ.String(), "")
+	}
+
+	// 包装的切片与map还原为原来的Go值
+	switch w := value.(type) {
+	case *GoSlice:
+		if w.v.Type().AssignableTo(typ) {
+			return w.v, nil
+		}
+		value = w.Elements()
+	case *GoMap:
+		if w.v.Type().AssignableTo(typ) {
+			return w.v, nil
+		}
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(typ) {
+		return v, nil
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := value.(int); ok {
+			result := reflect.New(typ).Elem()
+			if result.OverflowInt(int64(i)) {
+				return reflect.Value{}, NewConvertError(value, typ.String(), "overflow")
+			}
+			result.SetInt(int64(i))
+			return result, nil
+		}
+		if i, ok := value.(*big.Int); ok {
+			result := reflect.New(typ).Elem()
+			if !i.IsInt64() || result.OverflowInt(i.Int64()) {
+				return reflect.Value{}, NewConvertError(value, typ.String(), "overflow")
+			}
+			result.SetInt(i.Int64())
+			return result, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := value.(int); ok {
+			result := reflect.New(typ).Elem()
+			if i < 0 || result.OverflowUint(uint64(i)) {
+				return reflect.Value{}, NewConvertError(value, typ.String(), "overflow")
+			}
+			result.SetUint(uint64(i))
+			return result, nil
+		}
+		if i, ok := value.(*big.Int); ok {
+			result := reflect.New(typ).Elem()
+			if !i.IsUint64() || result.OverflowUint(i.Uint64()) {
+				return reflect.Value{}, NewConvertError(value, typ.String(), "overflow")
+			}
+			result.SetUint(i.Uint64())
+			return result, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := interfaceToFloat64(value); ok {
+			return reflect.ValueOf(f).Convert(typ), nil
+		}
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			return reflect.ValueOf(s).Convert(typ), nil
+		}
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			return reflect.ValueOf(b).Convert(typ), nil
+		}
+	case reflect.Slice:
+		if array, ok := value.([]interface{}); ok {
+			result := reflect.MakeSlice(typ, len(array), len(array))
+			for n, element := range array {
+				ev, err := toGoValue(element, typ.Elem())
+				if err != nil {
+					return reflect.Value{}, NewConvertError(value, typ.String(), fmt.Sprintf("element %d: %s", n, err))
+				}
+				result.Index(n).Set(ev)
+			}
+			return result, nil
+		}
+	case reflect.Map:
+		if m, ok := value.(*LoxMap); ok {
+			result := reflect.MakeMapWithSize(typ, m.Len())
+			for _, key := range m.keys {
+				kv, err := toGoValue(key, typ.Key())
+				if err != nil {
+					return reflect.Value{}, NewConvertError(value, typ.String(), fmt.Sprintf("key %v: %s", key, err))
+				}
+				ev, err := toGoValue(m.values[key], typ.Elem())
+				if err != nil {
+					return reflect.Value{}, NewConvertError(value, typ.String(), fmt.Sprintf("value of %v: %s", key, err))
+				}
+				result.SetMapIndex(kv, ev)
+			}
+			return result, nil
+		}
+	}
+	return reflect.Value{}, NewConvertError(value, typ.String(), "")
+}
+
+// 查找结构体字段, 允许脚本中使用小写开头的名字访问导出字段
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	if field := v.FieldByName(name); field.IsValid() {
+		return field, true
+	}
+	if field := v.FieldByName(exportedName(name)); field.IsValid() {
+		return field, true
+	}
+	return reflect.Value{}, false
+}
+
+func methodByName(v reflect.Value, name string) (reflect.Value, bool) {
+	if method := v.MethodByName(name); method.IsValid() {
+		return method, true
+	}
+	if method := v.MethodByName(exportedName(name)); method.IsValid() {
+		return method, true
+	}
+	return reflect.Value{}, false
+}
+
+func exportedName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// Lox自身的值不通过反射访问
+func isGoObject(object interface{}) bool {
+	switch object.(type) {
+	case nil, LoxCallable, *LoxInstance, *LoxMap, *LoxModule, []interface{}:
+		return false
+	}
+	return true
+}
+
+// 通过反射获取Go值的字段, 方法或map中的值
+func GetProperty(object interface{}, name string) (interface{}, error) {
+	if !isGoObject(object) {
+		return nil, NewRuntimeError(nil, "Only instances and classes have properties.")
+	}
+	v := reflect.ValueOf(object)
+	switch w := object.(type) {
+	case *GoSlice:
+		v = w.v
+	case *GoMap:
+		v = w.v
+	}
+	if method, ok := methodByName(v, name); ok {
+		return &GoFunc{name: name, fn: method}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		key, err := toGoValue(name, v.Type().Key())
+		if err != nil {
+			return nil, err
+		}
+		value := v.MapIndex(key)
+		if !value.IsValid() {
+			return nil, NewRuntimeError(nil, "Undefined property '%s'.", name)
+		}
+		return fromGoValue(value), nil
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			break
+		}
+		v = v.Elem()
+		fallthrough
+	case reflect.Struct:
+		field, ok := fieldByName(v, name)
+		if !ok || !field.CanInterface() {
+			return nil, NewRuntimeError(nil, "Undefined property '%s'.", name)
+		}
+		return elementValue(field), nil
+	}
+	return nil, NewRuntimeError(nil, "Only instances and classes have properties.")
+}
+
+// 通过反射设置Go值的字段或map中的值
+func SetProperty(object interface{}, name string, value interface{}) error {
+	if !isGoObject(object) {
+		return NewRuntimeError(nil, "Only instances and classes have fields.")
+	}
+	if m, ok := object.(*GoMap); ok {
+		return m.Set(name, value)
+	}
+	v := reflect.ValueOf(object)
+	switch v.Kind() {
+	case reflect.Map:
+		key, err := toGoValue(name, v.Type().Key())
+		if err != nil {
+			return err
+		}
+		element, err := toGoValue(value, v.Type().Elem())
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, element)
+		return nil
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			break
+		}
+		field, ok := fieldByName(v.Elem(), name)
+		if !ok || !field.CanSet() {
+			return NewRuntimeError(nil, "Undefined field '%s'.", name)
+		}
+		element, err := toGoValue(value, field.Type())
+		if err != nil {
+			return err
+		}
+		field.Set(element)
+		return nil
+	case reflect.Struct:
+		return NewRuntimeError(nil, "Can't set field '%s' of struct value, use a pointer instead.", name)
+	}
+	return NewRuntimeError(nil, "Only instances and classes have fields.")
+}
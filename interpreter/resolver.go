@@ -7,20 +7,42 @@ import (
 	"github.com/WAY29/LoxGo/parser"
 )
 
+// 记录局部变量的作用域距离, 由解释器与vm的编译器实现
+type LocalResolver interface {
+	Resolve(expr parser.Expr, depth int)
+}
+
 type Resolver struct { // impl ExprVisitor, StmtVisitor
-	interpreter *Interpreter
-	scopes      *list.List
+	locals LocalResolver
+	scopes *list.List
 
 	functionType FunctionType
 	classType    ClassType
+	inLoop       bool
+
+	lint *lintState // 不为nil时进行静态检查, 错误记录为诊断信息而不中止解析
+}
+
+// 作用域中声明的名字
+type binding struct {
+	name     *lexer.Token
+	kind     SymbolKind
+	defined  bool
+	used     bool        // 是否被读取过
+	assigned bool        // 声明之后是否被重新赋值
+	decl     parser.Stmt // 函数或类的声明, 用于静态检查调用的参数个数
+	super    *binding    // 类的父类
+	global   bool
+	refs     []*lexer.Token // 读取与赋值处的名字
 }
 
-func NewResolver(i *Interpreter) *Resolver {
+func NewResolver(locals LocalResolver) *Resolver {
 	return &Resolver{
-		interpreter:  i,
+		locals:       locals,
 		scopes:       list.New(),
 		functionType: FunctionTypeNone,
 		classType:    ClassTypeNone,
+		inLoop:       false,
 	}
 }
 
@@ -33,6 +55,15 @@ func (r *Resolver) newFunctionState(functionType FunctionType) func() {
 	}
 }
 
+func (r *Resolver) newLoopState(inLoop bool) func() {
+	oldInLoop := r.inLoop
+	r.inLoop = inLoop
+
+	return func() {
+		r.inLoop = oldInLoop
+	}
+}
+
 func (r *Resolver) newClassState(classType ClassType) func() {
 	oldClassType := r.classType
 	r.classType = classType
@@ -42,51 +73,131 @@ func (r *Resolver) newClassState(classType ClassType) func() {
 	}
 }
 
-func (r *Resolver) ResolveStmts(stmts []parser.Stmt) {
-	for _, stmt := range stmts {
-		r.resolveStmt(stmt)
+func (r *Resolver) ResolveStmts(stmts []parser.Stmt) error {
+	unreachable := false
+	for n, stmt := range stmts {
+		// 只报告第一条不可达的语句
+		if r.lint != nil && !unreachable && n > 0 && isJump(stmts[n-1]) {
+			span := stmt.Span()
+			r.lint.report(span.Start, span.End, RuleUnreachableCode, "Unreachable code.")
+			unreachable = true
+		}
+		if err := r.resolveStmt(stmt); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (r *Resolver) resolveStmt(stmt parser.Stmt) {
-	stmt.Accept(r)
+// 之后的语句不会被执行
+func isJump(stmt parser.Stmt) bool {
+	switch stmt.(type) {
+	case *parser.Return, *parser.Break, *parser.Continue, *parser.Throw:
+		return true
+	}
+	return false
 }
 
-func (r *Resolver) resolveExpr(expr parser.Expr) {
-	expr.Accept(r)
+// 静态检查时记录为诊断信息并继续, 否则返回错误
+func (r *Resolver) error(token *lexer.Token, rule, message string) error {
+	if r.lint == nil {
+		return parser.NewParseError(token, message)
+	}
+	r.lint.report(token.GetStart(), token.GetEnd(), rule, message)
+	return nil
 }
 
-func (r *Resolver) resolveLocal(expr parser.Expr, name *lexer.Token) {
+func (r *Resolver) resolveStmt(stmt parser.Stmt) error {
+	if stmt == nil {
+		return nil
+	}
+	_, err := stmt.Accept(r)
+	return err
+}
+
+func (r *Resolver) resolveExpr(expr parser.Expr) error {
+	if expr == nil {
+		return nil
+	}
+	_, err := expr.Accept(r)
+	return err
+}
+
+func (r *Resolver) resolveExprs(exprs ...parser.Expr) error {
+	for _, expr := range exprs {
+		if err := r.resolveExpr(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 返回找到的局部变量, 全局变量返回nil
+func (r *Resolver) resolveLocal(expr parser.Expr, name *lexer.Token) *binding {
 	n := r.scopes.Len() - 1
 	for i := r.scopes.Back(); i != nil && n >= 0; i = i.Prev() {
-		scope := i.Value.(map[string]bool)
-		if _, ok := scope[name.GetValue()]; ok {
+		scope := i.Value.(map[string]*binding)
+		if b, ok := scope[name.GetValue()]; ok {
 			// fmt.Printf("debug: resolve: %s %#v %d\n", name.GetValue(), expr, r.scopes.Len()-1-n)
-			r.interpreter.Resolve(expr, r.scopes.Len()-1-n)
-			return
+			if r.locals != nil {
+				r.locals.Resolve(expr, r.scopes.Len()-1-n)
+			}
+			return b
 		}
 		n--
 	}
+	return nil
+}
+
+// 查找名字对应的声明, 静态检查时包括已声明的全局变量
+func (r *Resolver) lookup(name string) *binding {
+	for i := r.scopes.Back(); i != nil; i = i.Prev() {
+		if b, ok := i.Value.(map[string]*binding)[name]; ok {
+			return b
+		}
+	}
+	if r.lint != nil {
+		return r.lint.globals[name]
+	}
+	return nil
 }
 
-func (r *Resolver) resolveFunction(function *parser.Function, functionType FunctionType) {
+func (r *Resolver) resolveFunction(function *parser.Function, functionType FunctionType) error {
 	defer r.newScope()()
 	defer r.newFunctionState(functionType)()
+	defer r.newLoopState(false)()
 
-	for _, param := range function.Params {
-		r.decleare(param)
+	for n, param := range function.Params {
+		if err := r.decleare(param, SymbolParameter); err != nil {
+			return err
+		}
+		// 默认值可以引用之前的参数
+		if defaultValue := function.Defaults[n]; defaultValue != nil {
+			if err := r.resolveExpr(defaultValue); err != nil {
+				return err
+			}
+		}
 		r.define(param)
 	}
-	r.resolveStmt(function.Body)
+	if function.Rest != nil {
+		if err := r.decleare(function.Rest, SymbolParameter); err != nil {
+			return err
+		}
+		r.define(function.Rest)
+	}
+	return r.resolveStmt(function.Body)
 }
 
 func (r *Resolver) beginScope() {
-	r.scopes.PushBack(make(map[string]bool))
+	r.scopes.PushBack(make(map[string]*binding))
 }
 
 func (r *Resolver) endScope() {
 	v := r.scopes.Back()
 	r.scopes.Remove(v)
+	if r.lint != nil {
+		r.lint.checkUnused(v.Value.(map[string]*binding))
+	}
 }
 
 func (r *Resolver) newScope() func() {
@@ -95,83 +206,106 @@ func (r *Resolver) newScope() func() {
 	return r.endScope
 }
 
-func (r *Resolver) decleare(name *lexer.Token) {
+func (r *Resolver) decleare(name *lexer.Token, kind SymbolKind) error {
 	if r.scopes.Len() == 0 {
-		return
+		if r.lint != nil {
+			r.lint.declareGlobal(name, kind)
+		}
+		return nil
 	}
-	scopeMap := r.scopes.Back().Value.(map[string]bool)
+	scopeMap := r.scopes.Back().Value.(map[string]*binding)
 	if _, ok := scopeMap[name.GetValue()]; ok {
-		panic(parser.NewParseError(name, "Already variable with this name in this scope."))
+		return r.error(name, RuleResolveError, "Already variable with this name in this scope.")
+	}
+	b := &binding{name: name, kind: kind}
+	if r.lint != nil {
+		if outer := r.lookup(name.GetValue()); outer != nil && outer.kind != symbolInternal {
+			r.lint.reportShadowing(name, outer)
+		}
+		r.lint.bindings = append(r.lint.bindings, b)
 	}
-	scopeMap[name.GetValue()] = false
+	scopeMap[name.GetValue()] = b
+	return nil
 }
 
 func (r *Resolver) define(name *lexer.Token) {
 	if r.scopes.Len() == 0 {
 		return
 	}
-	scopeMap := r.scopes.Back().Value.(map[string]bool)
-	scopeMap[name.GetValue()] = true
+	scopeMap := r.scopes.Back().Value.(map[string]*binding)
+	if b, ok := scopeMap[name.GetValue()]; ok {
+		b.defined = true
+	} else {
+		scopeMap[name.GetValue()] = &binding{name: name, defined: true}
+	}
+}
+
+// 定义this与super
+func (r *Resolver) defineInternal(name string) {
+	scopeMap := r.scopes.Back().Value.(map[string]*binding)
+	scopeMap[name] = &binding{kind: symbolInternal, defined: true, used: true}
 }
 
 func (r *Resolver) VisitTernaryExpr(expr *parser.Ternary) (interface{}, error) {
-	r.resolveExpr(expr.Condition)
-	r.resolveExpr(expr.ThenExpr)
-	r.resolveExpr(expr.ElseExpr)
-	return nil, nil
+	return nil, r.resolveExprs(expr.Condition, expr.ThenExpr, expr.ElseExpr)
 }
 
 func (r *Resolver) VisitAssignExpr(expr *parser.Assign) (interface{}, error) {
-	r.resolveExpr(expr.Value)
-	r.resolveLocal(expr, expr.Name)
+	if err := r.resolveExpr(expr.Value); err != nil {
+		return nil, err
+	}
+	b := r.resolveLocal(expr, expr.Name)
+	if b != nil {
+		b.assigned = true
+	}
+	if r.lint != nil {
+		r.lint.reference(expr.Name, b)
+		if b == nil {
+			r.lint.assignGlobal(expr.Name)
+		}
+	}
 	return nil, nil
 }
 
 func (r *Resolver) VisitBinaryExpr(expr *parser.Binary) (interface{}, error) {
-	r.resolveExpr(expr.Left)
-	r.resolveExpr(expr.Right)
-	return nil, nil
+	return nil, r.resolveExprs(expr.Left, expr.Right)
 }
 
 func (r *Resolver) VisitCallExpr(expr *parser.Call) (interface{}, error) {
-	r.resolveExpr(expr.Callee)
-
-	for _, arg := range expr.Arguments {
-		r.resolveExpr(arg)
+	if err := r.resolveExpr(expr.Callee); err != nil {
+		return nil, err
 	}
-
-	return nil, nil
+	if variable, ok := expr.Callee.(*parser.Variable); ok && r.lint != nil {
+		r.lint.call(expr, variable.Name, r.lookup(variable.Name.GetValue()))
+	}
+	return nil, r.resolveExprs(expr.Arguments...)
 }
 
 func (r *Resolver) VisitGetExpr(expr *parser.Get) (interface{}, error) {
-	r.resolveExpr(expr.Instance)
-	return nil, nil
+	return nil, r.resolveExpr(expr.Instance)
 }
 
 func (r *Resolver) VisitArrayExpr(expr *parser.Array) (interface{}, error) {
-	for _, element := range expr.Elements {
-		r.resolveExpr(element)
-	}
-	return nil, nil
+	return nil, r.resolveExprs(expr.Elements...)
 }
 
 func (r *Resolver) VisitIndexExpr(expr *parser.Index) (interface{}, error) {
-	r.resolveExpr(expr.Index)
+	return nil, r.resolveExprs(expr.Object, expr.Index)
+}
 
-	scope := r.scopes.Back()
-	if scope == nil {
-		return nil, nil
-	}
-	if v, ok := scope.Value.(map[string]bool)[expr.Name.GetValue()]; ok && r.scopes.Len() > 0 && !v {
-		panic(parser.NewParseError(expr.Name, "Can't read local variable in its own initializer."))
+func (r *Resolver) VisitIndexSetExpr(expr *parser.IndexSet) (interface{}, error) {
+	return nil, r.resolveExprs(expr.Object, expr.Index, expr.Value)
+}
+
+func (r *Resolver) VisitMapExpr(expr *parser.Map) (interface{}, error) {
+	if err := r.resolveExprs(expr.Keys...); err != nil {
+		return nil, err
 	}
-	r.resolveLocal(expr, expr.Name)
-	return nil, nil
+	return nil, r.resolveExprs(expr.Values...)
 }
 
 func (r *Resolver) VisitGroupingExpr(expr *parser.Grouping) (interface{}, error) {
-	r.resolveExpr(expr.Expression)
-	return nil, nil
+	return nil, r.resolveExpr(expr.Expression)
 }
 
 func (r *Resolver) VisitLiteralExpr(expr *parser.Literal) (interface{}, error) {
@@ -179,144 +313,280 @@ func (r *Resolver) VisitLiteralExpr(expr *parser.Literal) (interface{}, error) {
 }
 
 func (r *Resolver) VisitLogicalExpr(expr *parser.Logical) (interface{}, error) {
-	r.resolveExpr(expr.Left)
-	r.resolveExpr(expr.Right)
-	return nil, nil
+	return nil, r.resolveExprs(expr.Left, expr.Right)
 }
 
 func (r *Resolver) VisitSetExpr(expr *parser.Set) (interface{}, error) {
-	r.resolveExpr(expr.Instance)
-	r.resolveExpr(expr.Value)
-	return nil, nil
+	return nil, r.resolveExprs(expr.Instance, expr.Value)
 }
 
 func (r *Resolver) VisitThisExpr(expr *parser.This) (interface{}, error) {
 	if r.classType == ClassTypeNone {
-		panic(parser.NewParseError(expr.Keyword, "Can't use 'this' outside of a class."))
+		if err := r.error(expr.Keyword, RuleThisOutsideMethod, "Can't use 'this' outside of a class."); err != nil {
+			return nil, err
+		}
 	}
 	r.resolveLocal(expr, expr.Keyword)
 	return nil, nil
 }
 
-func (r *Resolver) VisitUnaryExpr(expr *parser.Unary) (interface{}, error) {
-	r.resolveExpr(expr.Right)
+func (r *Resolver) VisitStringifyExpr(expr *parser.Stringify) (interface{}, error) {
+	return nil, r.resolveExpr(expr.Value)
+}
+
+func (r *Resolver) VisitSpreadExpr(expr *parser.Spread) (interface{}, error) {
+	return nil, r.resolveExpr(expr.Value)
+}
+
+func (r *Resolver) VisitSuperExpr(expr *parser.Super) (interface{}, error) {
+	if r.classType == ClassTypeNone {
+		return nil, r.error(expr.Keyword, RuleResolveError, "Can't use 'super' outside of a class.")
+	} else if r.classType != ClassTypeSubclass {
+		return nil, r.error(expr.Keyword, RuleResolveError, "Can't use 'super' in a class with no superclass.")
+	}
+	r.resolveLocal(expr, expr.Keyword)
 	return nil, nil
 }
 
+func (r *Resolver) VisitUnaryExpr(expr *parser.Unary) (interface{}, error) {
+	return nil, r.resolveExpr(expr.Right)
+}
+
 func (r *Resolver) VisitVariableExpr(expr *parser.Variable) (interface{}, error) {
-	scope := r.scopes.Back()
-	if scope == nil {
-		return nil, nil
+	if scope := r.scopes.Back(); scope != nil {
+		if b, ok := scope.Value.(map[string]*binding)[expr.Name.GetValue()]; ok && !b.defined {
+			return nil, r.error(expr.Name, RuleResolveError, "Can't read local variable in its own initializer.")
+		}
+	}
+	b := r.resolveLocal(expr, expr.Name)
+	if b != nil {
+		b.used = true
 	}
-	if v, ok := scope.Value.(map[string]bool)[expr.Name.GetValue()]; ok && r.scopes.Len() > 0 && !v {
-		panic(parser.NewParseError(expr.Name, "Can't read local variable in its own initializer."))
+	if r.lint != nil {
+		r.lint.reference(expr.Name, b)
 	}
-	r.resolveLocal(expr, expr.Name)
 	return nil, nil
 }
 
 func (r *Resolver) VisitLambdaExpr(expr *parser.Lambda) (interface{}, error) {
-	r.resolveStmt(expr.Function)
+	// 匿名函数没有名字, 不需要声明
+	if function, ok := expr.Function.(*parser.Function); ok {
+		return nil, r.resolveFunction(function, FunctionTypeFunction)
+	}
 	return nil, nil
 }
 
 func (r *Resolver) VisitBlockStmt(stmt *parser.Block) (interface{}, error) {
 	defer r.newScope()()
 
-	r.ResolveStmts(stmt.Statements)
-
-	return nil, nil
+	return nil, r.ResolveStmts(stmt.Statements)
 }
 
 func (r *Resolver) VisitClassStmt(stmt *parser.Class) (interface{}, error) {
-	r.decleare(stmt.Name)
+	if err := r.decleare(stmt.Name, SymbolClass); err != nil {
+		return nil, err
+	}
 	r.define(stmt.Name)
-	defer r.newScope()()
+	class := r.lookup(stmt.Name.GetValue())
+	if class != nil {
+		class.decl = stmt
+	}
 	defer r.newClassState(ClassTypeClass)()
 
-	scopeMap := r.scopes.Back().Value.(map[string]bool)
-	scopeMap["this"] = true
+	if stmt.Superclass != nil {
+		if stmt.Name.GetValue() == stmt.Superclass.Name.GetValue() {
+			if err := r.error(stmt.Superclass.Name, RuleResolveError, "A class can't inherit from itself."); err != nil {
+				return nil, err
+			}
+		}
+		r.classType = ClassTypeSubclass
+		if err := r.resolveExpr(stmt.Superclass); err != nil {
+			return nil, err
+		}
+		if class != nil {
+			class.super = r.lookup(stmt.Superclass.Name.GetValue())
+		}
+
+		defer r.newScope()()
+		r.defineInternal("super")
+	}
+
+	defer r.newScope()()
+	r.defineInternal("this")
 
 	for _, method := range stmt.Methods {
 		if methodFunction, ok := method.(*parser.Function); !ok {
-			return nil, parser.NewParseError(stmt.Name, "Invalid method")
+			return nil, r.error(stmt.Name, RuleResolveError, "Invalid method")
 		} else {
 			functionType := FunctionTypeMethod
 			if methodFunction.Name.GetValue() == "init" {
 				functionType = FunctionTypeIinitalizer
 			}
-			r.resolveFunction(methodFunction, functionType)
+			if err := r.resolveFunction(methodFunction, functionType); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, method := range stmt.ClassMethods {
+		if methodFunction, ok := method.(*parser.Function); !ok {
+			return nil, r.error(stmt.Name, RuleResolveError, "Invalid class method")
+		} else {
+			if err := r.resolveFunction(methodFunction, FunctionTypeMethod); err != nil {
+				return nil, err
+			}
 		}
 	}
 	return nil, nil
 }
 
 func (r *Resolver) VisitExpressionStmt(stmt *parser.Expression) (interface{}, error) {
-	r.resolveExpr(stmt.Expr)
-	return nil, nil
+	return nil, r.resolveExpr(stmt.Expr)
 }
 
 func (r *Resolver) VisitFunctionStmt(stmt *parser.Function) (interface{}, error) {
-	r.decleare(stmt.Name)
+	if err := r.decleare(stmt.Name, SymbolFunction); err != nil {
+		return nil, err
+	}
 	r.define(stmt.Name)
+	if b := r.lookup(stmt.Name.GetValue()); b != nil {
+		b.decl = stmt
+	}
 
-	r.resolveFunction(stmt, FunctionTypeFunction)
-	return nil, nil
+	return nil, r.resolveFunction(stmt, FunctionTypeFunction)
 }
 
 func (r *Resolver) VisitIfStmt(stmt *parser.If) (interface{}, error) {
-	r.resolveExpr(stmt.Condition)
-	r.resolveStmt(stmt.ThenBranch)
-	if stmt.ElseBranch != nil {
-		r.resolveStmt(stmt.ElseBranch)
+	if err := r.resolveExpr(stmt.Condition); err != nil {
+		return nil, err
 	}
-	return nil, nil
+	if err := r.resolveStmt(stmt.ThenBranch); err != nil {
+		return nil, err
+	}
+	return nil, r.resolveStmt(stmt.ElseBranch)
 }
 
 func (r *Resolver) VisitPrintStmt(stmt *parser.Print) (interface{}, error) {
-	r.resolveExpr(stmt.Expr)
-	return nil, nil
+	return nil, r.resolveExpr(stmt.Expr)
 }
 
 func (r *Resolver) VisitReturnStmt(stmt *parser.Return) (interface{}, error) {
 	if r.functionType == FunctionTypeNone {
-		panic(parser.NewParseError(stmt.Keyword, "Can't return from top-level code."))
-	} else if stmt.Value != nil {
-		if r.functionType == FunctionTypeIinitalizer {
-			panic(parser.NewParseError(stmt.Keyword, "Can't return a value from an initializer."))
+		if err := r.error(stmt.Keyword, RuleResolveError, "Can't return from top-level code."); err != nil {
+			return nil, err
+		}
+	} else if stmt.Value != nil && r.functionType == FunctionTypeIinitalizer {
+		if err := r.error(stmt.Keyword, RuleResolveError, "Can't return a value from an initializer."); err != nil {
+			return nil, err
 		}
-
-		r.resolveExpr(stmt.Value)
 	}
-	return nil, nil
+	return nil, r.resolveExpr(stmt.Value)
 }
 
 func (r *Resolver) VisitVarStmt(stmt *parser.Var) (interface{}, error) {
 	for _, name := range stmt.Names {
-		r.decleare(name)
+		if err := r.decleare(name, SymbolVariable); err != nil {
+			return nil, err
+		}
 	}
 
-	for _, init := range stmt.Initializers {
-		r.resolveExpr(init)
+	if err := r.resolveExprs(stmt.Initializers...); err != nil {
+		return nil, err
 	}
 
-	for _, name := range stmt.Names {
+	for n, name := range stmt.Names {
 		r.define(name)
-
+		// 使用匿名函数初始化的变量可以检查调用的参数个数
+		if lambda, ok := stmt.Initializers[n].(*parser.Lambda); ok {
+			if b := r.lookup(name.GetValue()); b != nil {
+				b.decl = lambda.Function
+			}
+		}
 	}
 	return nil, nil
 }
 
 func (r *Resolver) VisitWhileStmt(stmt *parser.While) (interface{}, error) {
-	r.resolveExpr(stmt.Condition)
-	r.resolveStmt(stmt.Body)
+	defer r.newLoopState(true)()
+
+	if err := r.resolveExpr(stmt.Condition); err != nil {
+		return nil, err
+	}
+	if err := r.resolveStmt(stmt.Body); err != nil {
+		return nil, err
+	}
+	return nil, r.resolveExpr(stmt.Increment)
+}
+
+func (r *Resolver) VisitForInStmt(stmt *parser.ForIn) (interface{}, error) {
+	if err := r.resolveExpr(stmt.Iterable); err != nil {
+		return nil, err
+	}
+
+	defer r.newLoopState(true)()
+	// 循环变量位于单独的作用域中, 每次迭代重新绑定
+	defer r.newScope()()
+	if err := r.decleare(stmt.Name, SymbolVariable); err != nil {
+		return nil, err
+	}
+	r.define(stmt.Name)
+	return nil, r.resolveStmt(stmt.Body)
+}
+
+func (r *Resolver) VisitTryStmt(stmt *parser.Try) (interface{}, error) {
+	if err := r.resolveStmt(stmt.Body); err != nil {
+		return nil, err
+	}
+	if err := r.resolveCatch(stmt); err != nil {
+		return nil, err
+	}
+	return nil, r.resolveStmt(stmt.FinallyBody)
+}
+
+// catch变量位于单独的作用域中
+func (r *Resolver) resolveCatch(stmt *parser.Try) error {
+	if stmt.CatchBody == nil {
+		return nil
+	}
+	defer r.newScope()()
+	if stmt.CatchName != nil {
+		if err := r.decleare(stmt.CatchName, SymbolVariable); err != nil {
+			return err
+		}
+		r.define(stmt.CatchName)
+	}
+	return r.resolveStmt(stmt.CatchBody)
+}
+
+func (r *Resolver) VisitImportStmt(stmt *parser.Import) (interface{}, error) {
+	if err := r.decleare(stmt.Name, SymbolModule); err != nil {
+		return nil, err
+	}
+	r.define(stmt.Name)
 	return nil, nil
 }
 
+func (r *Resolver) VisitExportStmt(stmt *parser.Export) (interface{}, error) {
+	if r.scopes.Len() != 0 {
+		return nil, r.error(stmt.Keyword, RuleResolveError, "Can only export top-level declarations.")
+	}
+	return nil, r.resolveStmt(stmt.Declaration)
+}
+
+func (r *Resolver) VisitThrowStmt(stmt *parser.Throw) (interface{}, error) {
+	return nil, r.resolveExpr(stmt.Value)
+}
+
 func (r *Resolver) VisitBreakStmt(stmt *parser.Break) (interface{}, error) {
+	if !r.inLoop {
+		return nil, r.error(stmt.Keyword, RuleResolveError, "Can't use 'break' outside of a loop.")
+	}
 	return nil, nil
 }
 
 func (r *Resolver) VisitContinueStmt(stmt *parser.Continue) (interface{}, error) {
+	if !r.inLoop {
+		return nil, r.error(stmt.Keyword, RuleResolveError, "Can't use 'continue' outside of a loop.")
+	}
 	return nil, nil
 }
@@ -0,0 +1,70 @@
+package interpreter
+
+import "github.com/WAY29/LoxGo/lexer"
+
+// return/break/continue通过以下信号沿着execute的error返回值向上传递,
+// 分别由函数调用与循环捕获, 不再修改AST上的状态
+
+type ReturnSignal struct {
+	keyword *lexer.Token
+	value   interface{}
+}
+
+func newReturnSignal(keyword *lexer.Token, value interface{}) *ReturnSignal {
+	return &ReturnSignal{
+		keyword: keyword,
+		value:   value,
+	}
+}
+
+func (s *ReturnSignal) Error() string {
+	return "Can't return from top-level code."
+}
+
+type BreakSignal struct {
+	keyword *lexer.Token
+}
+
+func newBreakSignal(keyword *lexer.Token) *BreakSignal {
+	return &BreakSignal{
+		keyword: keyword,
+	}
+}
+
+func (s *BreakSignal) Error() string {
+	return "Can't use 'break' outside of a loop."
+}
+
+type ContinueSignal struct {
+	keyword *lexer.Token
+}
+
+func newContinueSignal(keyword *lexer.Token) *ContinueSignal {
+	return &ContinueSignal{
+		keyword: keyword,
+	}
+}
+
+func (s *ContinueSignal) Error() string {
+	return "Can't use 'continue' outside of a loop."
+}
+
+// 调试器终止程序时沿着execute的error返回值向上传递, 不能被catch捕获
+type TerminateSignal struct{}
+
+func (s *TerminateSignal) Error() string {
+	return "Terminated by debugger."
+}
+
+// 将逃逸出函数或循环的信号转换为运行时错误
+func signalToError(err error) error {
+	switch signal := err.(type) {
+	case *ReturnSignal:
+		return NewRuntimeError(signal.keyword, signal.Error())
+	case *BreakSignal:
+		return NewRuntimeError(signal.keyword, signal.Error())
+	case *ContinueSignal:
+		return NewRuntimeError(signal.keyword, signal.Error())
+	}
+	return err
+}
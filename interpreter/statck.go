@@ -10,6 +10,8 @@ type Stack struct {
 	parent *Stack
 	call   *parser.Call
 	callee LoxCallable
+
+	environment *Environment // 发起调用时调用者所在的环境
 }
 
 func NewStack(parent *Stack, call *parser.Call, callee LoxCallable) *Stack {
@@ -33,3 +35,44 @@ func (s *Stack) String() string {
 	}
 	return value
 }
+
+// 调用栈中的一帧
+type TraceFrame struct {
+	Function string
+	Call     *parser.Call // 调用位置, 由Go发起的调用为nil
+}
+
+// 调用位置, 形如file:line:col
+func (f TraceFrame) Location() string {
+	if f.Call == nil || f.Call.Paren == nil {
+		return "<host>"
+	}
+	return f.Call.Paren.GetSource().Location(f.Call.Span().Start)
+}
+
+func (f TraceFrame) Line() int {
+	if f.Call == nil {
+		return 0
+	}
+	return f.Call.Span().Start.Line
+}
+
+func (f TraceFrame) String() string {
+	name := f.Function
+	if len(name) == 0 {
+		name = "<fn>"
+	}
+	return fmt.Sprintf("in %s() called at %s", name, f.Location())
+}
+
+// 从当前帧开始向外展开调用栈
+func (s *Stack) Trace() []TraceFrame {
+	frames := make([]TraceFrame, 0)
+	for stack := s; stack != nil && stack.callee != nil; stack = stack.parent {
+		frames = append(frames, TraceFrame{
+			Function: stack.callee.Name(),
+			Call:     stack.call,
+		})
+	}
+	return frames
+}
@@ -1,18 +1,28 @@
 package interpreter
 
 import (
+	"fmt"
 	"math"
+	"math/big"
 	"reflect"
 
-	"github.com/WAY29/LoxGo/parser"
+	"github.com/WAY29/LoxGo/decimal"
 )
 
+// 值转换为字符串, 与print的输出一致
+func ToString(value interface{}) string {
+	return fmt.Sprintf("%v", value)
+}
+
 func isEqual(a, b interface{}) bool {
 	if a == nil && b == nil {
 		return true
 	} else if a == nil {
 		return false
 	}
+	if equal, ok := numberEqual(a, b); ok {
+		return equal
+	}
 
 	return reflect.DeepEqual(a, b)
 }
@@ -25,6 +35,10 @@ func interfaceToFloat64(a interface{}) (float64, bool) {
 		return float64(v), true
 	case int64:
 		return float64(v), true
+	case *big.Int:
+		return bigToFloat(v), true
+	case *decimal.Decimal:
+		return v.Float64(), true
 	}
 
 	return 0, false
@@ -43,6 +57,10 @@ func interfaceToInt(a interface{}) (int, bool) {
 		return v, true
 	case int64:
 		return int(v), true
+	case *big.Int:
+		if v.IsInt64() {
+			return int(v.Int64()), true
+		}
 	}
 
 	return 0, false
@@ -59,32 +77,6 @@ func isTruthy(a interface{}) bool {
 	return true
 }
 
-func recursiveBlockStop(block *parser.Block) {
-	if block == nil {
-		return
-	}
-	for {
-		block.Stop = true
-		if block.Parent == nil {
-			break
-		}
-		block = block.Parent
-	}
-}
-
-func recursiveWhileStop(while *parser.While) {
-	if while == nil {
-		return
-	}
-	for {
-		while.Stop = true
-		if while.Parent == nil {
-			break
-		}
-		while = while.Parent
-	}
-}
-
 // func interfaceToBool(a interface{}) (bool, bool) {
 // 	if a == nil {
 // 		return false, true
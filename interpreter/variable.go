@@ -5,4 +5,5 @@ import "reflect"
 type Variable struct {
 	Value interface{}
 	Type  reflect.Kind
+	Empty bool // 声明时没有初始值且尚未赋值, 与值为nil的变量区分
 }
@@ -0,0 +1,58 @@
+package lexer
+
+import (
+	"sort"
+	"strings"
+)
+
+type LexError struct {
+	start    Position
+	end      Position
+	source   *Source
+	extraMsg string
+}
+
+func (e *LexError) Error() string {
+	return FormatError(e.source, e.start, e.end, "Lexer error: "+e.extraMsg)
+}
+
+// 不带位置信息的错误内容
+func (e *LexError) Message() string {
+	return e.extraMsg
+}
+
+func (e *LexError) GetStart() Position {
+	return e.start
+}
+
+func (e *LexError) GetEnd() Position {
+	return e.end
+}
+
+// 一次分析中收集到的多个错误, 按出现顺序排列
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for n, err := range l {
+		msgs[n] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// 没有错误时返回nil, 避免得到非nil的空error
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// 按错误在源码中的起始位置排序, 无法获取位置的错误保持原有顺序
+func (l ErrorList) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, ok1 := l[i].(interface{ GetStart() Position })
+		b, ok2 := l[j].(interface{ GetStart() Position })
+		return ok1 && ok2 && a.GetStart().Offset < b.GetStart().Offset
+	})
+}
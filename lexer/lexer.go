@@ -1,36 +1,60 @@
 package lexer
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/big"
 	"strconv"
 	"strings"
-	"sync"
-)
+	"unicode/utf8"
 
-var (
-	lexerPool = &sync.Pool{
-		New: func() interface{} {
-			return new(Lexer)
-		},
-	}
+	"github.com/WAY29/LoxGo/decimal"
 )
 
+// 正在扫描的字符串插值
+type interpolation struct {
+	braces int  // 插值表达式中未闭合的'{'个数
+	triple bool // 是否位于三引号字符串中
+}
+
 type Lexer struct {
 	tokens []*Token
 
-	line   uint16
-	buffer *bufio.Reader
-	err    error
-	end    bool
+	source  *Source
+	src     []byte
+	start   int // 当前token起始的字节偏移
+	current int // 下一个待读取字符的字节偏移
+	line    int
+	column  int
+	startAt Position
+	errs    ErrorList
+
+	interpolations []interpolation
+
+	trivia   []Trivia // 尚未附加到token上的注释与空行
+	newlines int      // 上一个token或注释之后的换行数
+	blankAt  Position // 上一个token或注释之后第一个换行的下一行起始位置
 }
 
 func NewLexer(reader io.Reader) *Lexer {
-	l := lexerPool.Get().(*Lexer)
-	l.buffer = bufio.NewReader(reader)
-	l.line = 1
-	l.end = false
+	return NewNamedLexer("", reader)
+}
+
+// 指定源码名称, 用于错误信息中的file:line:col
+func NewNamedLexer(name string, reader io.Reader) *Lexer {
+	text, err := ioutil.ReadAll(reader)
+	l := &Lexer{
+		tokens: make([]*Token, 0),
+		source: NewSource(name, text),
+		src:    text,
+		line:   1,
+		column: 1,
+	}
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("lexer error: %v", err))
+	}
 
 	return l
 }
@@ -39,218 +63,332 @@ func (l *Lexer) GetTokens() []*Token {
 	return l.tokens
 }
 
+// 返回扫描过程中的所有错误, 多个错误时为ErrorList
 func (l *Lexer) GetError() error {
-	return l.err
+	return l.errs.Err()
 }
 
-func (l *Lexer) peekOne() (rune, error) {
-	chars, err := l.buffer.Peek(1)
-	if err != nil {
-		return 0, err
-	}
-
-	return rune(chars[0]), err
+func (l *Lexer) GetErrors() []error {
+	return l.errs
 }
 
-func (l *Lexer) peek(n int) ([]byte, error) {
-	chars, err := l.buffer.Peek(n)
-	if err != nil {
-		return nil, err
-	}
-
-	return chars, err
+func (l *Lexer) GetSource() *Source {
+	return l.source
 }
 
-func (l *Lexer) read(n int) ([]byte, error) {
-	bytes := make([]byte, n)
-	_, err := l.buffer.Read(bytes)
-	if err == io.EOF {
-		err = nil
-		l.end = true
-	}
+func (l *Lexer) isAtEnd() bool {
+	return l.current >= len(l.src)
+}
 
-	return bytes, err
+func (l *Lexer) position() Position {
+	return Position{Line: l.line, Column: l.column, Offset: l.current}
 }
 
-func (l *Lexer) unreadByte() error {
-	if l.end == true {
-		return nil
+func (l *Lexer) advance() rune {
+	r, size := utf8.DecodeRune(l.src[l.current:])
+	l.current += size
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
 	}
-	return l.buffer.UnreadByte()
+	return r
 }
 
-func (l *Lexer) readUntil(delim byte) ([]byte, error) {
-	if l.end == true {
-		return nil, nil
+func (l *Lexer) peek() rune {
+	if l.isAtEnd() {
+		return 0
 	}
+	r, _ := utf8.DecodeRune(l.src[l.current:])
+	return r
+}
 
-	bytes, err := l.buffer.ReadBytes(delim)
-	if err == io.EOF {
-		err = nil
-		l.end = true
+func (l *Lexer) peekNext() rune {
+	if l.isAtEnd() {
+		return 0
 	}
-
-	if bytes[len(bytes)-1] == delim {
-		l.buffer.UnreadByte()
-		return bytes[:len(bytes)-1], err
+	_, size := utf8.DecodeRune(l.src[l.current:])
+	if l.current+size >= len(l.src) {
+		return 0
 	}
-	return bytes, err
+	r, _ := utf8.DecodeRune(l.src[l.current+size:])
+	return r
 }
 
-func (l *Lexer) readRune() (rune, error) {
-	ch, _, err := l.buffer.ReadRune()
-	if err == io.EOF {
-		err = nil
-		l.end = true
+func (l *Lexer) match(c rune) bool {
+	if l.peek() != c || l.isAtEnd() {
+		return false
 	}
-	return ch, err
+	l.advance()
+	return true
 }
 
-func (l *Lexer) unreadRune() error {
-	if l.end == true {
-		return nil
-	}
-	return l.buffer.UnreadRune()
+func (l *Lexer) error(format string, a ...interface{}) error {
+	return l.errorAt(l.startAt, format, a...)
 }
 
-func (l *Lexer) match(c rune) (bool, error) {
-	pc, err := l.peekOne()
-	if l.end == true {
-		return false, nil
+func (l *Lexer) errorAt(start Position, format string, a ...interface{}) error {
+	return &LexError{
+		start:    start,
+		end:      l.position(),
+		source:   l.source,
+		extraMsg: fmt.Sprintf(format, a...),
 	}
-	ok := pc == c
+}
 
-	if ok {
-		_, err = l.readRune()
-		if err != nil {
-			return false, err
-		}
+// 是否紧接着三个引号
+func (l *Lexer) matchTriple() bool {
+	if !bytes.HasPrefix(l.src[l.current:], []byte(`"""`)) {
+		return false
 	}
-	return ok, nil
+	l.current += 3
+	l.column += 3
+	return true
 }
 
-func (l *Lexer) scanString() error {
-	var (
-		c       rune
-		err     error = nil
-		builder       = &strings.Builder{}
-	)
-
+// 扫描字符串直到结束引号或${, triple表示三引号字符串, 其中可以换行
+func (l *Lexer) scanString(triple bool) error {
+	builder := &strings.Builder{}
 	for {
-		c, err = l.readRune()
-		if l.end {
-			err = fmt.Errorf("unterminated string")
-			break
+		if l.isAtEnd() {
+			return l.error("unterminated string")
 		}
-		if err != nil {
+		if triple && l.matchTriple() {
+			break
+		} else if !triple && l.match('"') {
 			break
 		}
 
-		if c == '"' {
-			l.addToken(STRING, builder.String(), builder.String())
-			break
-		} else if c == '\n' {
-			err = l.unreadByte()
-			if err != nil {
-				break
-			}
-			err = fmt.Errorf("unexpect '\\n' in string")
-		} else {
-			_, err = builder.WriteRune(c)
-			if err != nil {
-				break
+		switch c := l.peek(); {
+		case c == '\n' && !triple:
+			return l.error("unexpect '\\n' in string")
+		case c == '\\':
+			// 转义错误不中断字符串的扫描
+			if err := l.scanEscape(builder); err != nil {
+				l.errs = append(l.errs, err)
 			}
+		case c == '$' && l.peekNext() == '{':
+			l.advance()
+			l.advance()
+			l.interpolations = append(l.interpolations, interpolation{triple: triple})
+			value := builder.String()
+			l.addToken(INTERPOLATION, value, value)
+			return nil
+		default:
+			builder.WriteRune(l.advance())
 		}
 	}
-	return err
+
+	value := builder.String()
+	l.addToken(STRING, value, value)
+	return nil
 }
 
-func (l *Lexer) scanNumber(hc rune) error {
-	var (
-		c            rune
-		err          error = nil
-		builder            = &strings.Builder{}
-		hasScanPoint bool  = false
-		v            interface{}
-		vString      string
-	)
-	builder.WriteRune(hc)
+// 反引号字符串, 不处理转义与插值, 可以换行
+func (l *Lexer) scanRawString() error {
+	for !l.isAtEnd() && l.peek() != '`' {
+		l.advance()
+	}
+	if l.isAtEnd() {
+		return l.error("unterminated string")
+	}
+	l.advance()
 
-	for {
-		c, err = l.readRune()
-		if err != nil {
-			break
-		}
+	value := string(l.src[l.start+1 : l.current-1])
+	l.addToken(STRING, value, value)
+	return nil
+}
 
-		if isDigit(c) {
-			_, err = builder.WriteRune(c)
-			if err != nil {
-				break
-			}
-		} else {
-			if hasScanPoint || c != '.' {
-				err = l.unreadByte()
-				vString = builder.String()
-				if strings.Contains(vString, ".") {
-					v, err = strconv.ParseFloat(vString, 64)
-				} else {
-					v, err = strconv.Atoi(vString)
-				}
-				l.addToken(NUMBER, vString, v)
-				break
-			} else if c == '.' {
-				hasScanPoint = true
-				_, err = builder.WriteRune(c)
-				if err != nil {
-					break
-				}
-				continue
-			}
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+	'$':  '$',
+}
 
+// 处理转义序列: \n \t \r \0 \\ \" \' \$ \xHH \uHHHH \u{H...}
+func (l *Lexer) scanEscape(builder *strings.Builder) error {
+	start := l.position()
+	l.advance()
+	if l.isAtEnd() {
+		return l.errorAt(start, "unterminated escape sequence")
+	}
+	c := l.advance()
+	if r, ok := escapes[c]; ok {
+		builder.WriteRune(r)
+		return nil
+	}
+
+	var digits string
+	switch {
+	case c == 'x':
+		digits = l.scanHex(2)
+	case c == 'u' && l.match('{'):
+		for !l.isAtEnd() && l.peek() != '}' && l.peek() != '"' && l.peek() != '\n' {
+			l.advance()
 		}
+		if !l.match('}') {
+			return l.errorAt(start, "unterminated unicode escape sequence")
+		}
+		digits = string(l.src[start.Offset+3 : l.current-1])
+	case c == 'u':
+		digits = l.scanHex(4)
+	default:
+		return l.errorAt(start, "invalid escape sequence '\\%c'", c)
+	}
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || (c == 'x' && len(digits) != 2) || (c == 'u' && len(digits) == 0) || !utf8.ValidRune(rune(code)) {
+		return l.errorAt(start, "invalid escape sequence '%s'", string(l.src[start.Offset:l.current]))
 	}
-	return err
+	builder.WriteRune(rune(code))
+	return nil
 }
 
-func (l *Lexer) scanIdentifier(hc rune) error {
-	var (
-		c         rune
-		err       error = nil
-		builder         = &strings.Builder{}
-		value     string
-		tokenType TokenType
-		ok        bool
-	)
-	builder.WriteRune(hc)
+// 读取最多n个十六进制字符
+func (l *Lexer) scanHex(n int) string {
+	start := l.current
+	for i := 0; i < n && isHexDigit(l.peek()); i++ {
+		l.advance()
+	}
+	return string(l.src[start:l.current])
+}
+
+// 向后查看第n个字节, 数字字面量只包含ASCII字符
+func (l *Lexer) peekByte(n int) byte {
+	if l.current+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.current+n]
+}
 
+// 读取指定进制的数字, 数字之间可以使用_分隔
+func (l *Lexer) scanDigits(base int) {
 	for {
-		c, err = l.readRune()
-		if err != nil {
-			return err
+		if isDigitOf(rune(l.peekByte(0)), base) {
+			l.advance()
+		} else if l.peekByte(0) == '_' && isDigitOf(rune(l.peekByte(1)), base) {
+			l.advance()
+		} else {
+			return
 		}
+	}
+}
 
-		if isAlphaNumeric(c) {
-			_, err = builder.WriteRune(c)
-			if err != nil {
-				return err
-			}
-		} else {
-			value = builder.String()
-			if tokenType, ok = KEYWORDS[value]; ok {
-				l.addToken(tokenType, value)
-			} else {
-				l.addToken(IDENTIFIER, value)
+// 支持十进制, 0x十六进制, 0o八进制, 0b二进制整数, 小数与科学计数法, 如 1_000, 0xff, 1.5e-3, 以及大整数与定点小数后缀
+func (l *Lexer) scanNumber() error {
+	base := 10
+	if l.src[l.start] == '0' {
+		switch l.peek() {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			l.advance()
+		}
+	}
+	digitsStart := l.current
+	l.scanDigits(base)
+
+	isFloat := false
+	if base == 10 && l.peek() == '.' && isDigit(l.peekNext()) {
+		isFloat = true
+		l.advance()
+		l.scanDigits(10)
+	}
+	if base == 10 && (l.peek() == 'e' || l.peek() == 'E') {
+		sign := 0
+		if l.peekByte(1) == '+' || l.peekByte(1) == '-' {
+			sign = 1
+		}
+		if isDigit(rune(l.peekByte(1 + sign))) {
+			isFloat = true
+			l.advance()
+			if sign == 1 {
+				l.advance()
 			}
+			l.scanDigits(10)
+		}
+	}
 
-			err = l.unreadRune()
-			if err != nil {
-				return err
-			}
-			break
+	// n后缀表示大整数, d后缀表示定点小数, 如 1n, 19.99d
+	suffix := byte(0)
+	if c := l.peekByte(0); (c == 'n' && !isFloat) || (c == 'd' && base == 10) {
+		if next := rune(l.peekByte(1)); !isAlphaNumeric(next) && next != '_' {
+			suffix = c
+			l.advance()
+		}
+	}
+
+	// 数字后紧跟字母, 数字或_时整体视为非法数字, 如 0x, 12abc, 0b102, 1__0
+	invalid := l.current == digitsStart && base != 10
+	for isAlphaNumeric(l.peek()) || l.peek() == '_' {
+		invalid = true
+		l.advance()
+	}
+	vString := string(l.src[l.start:l.current])
+	// 出错时仍然生成token, 避免语法分析产生多余的错误
+	if invalid {
+		l.addToken(NUMBER, vString, 0)
+		return l.error("invalid number %s", vString)
+	}
+
+	digits := strings.ReplaceAll(vString, "_", "")
+	if suffix != 0 {
+		digits = digits[:len(digits)-1]
+	}
+	if suffix == 'd' {
+		v, err := decimal.Parse(digits)
+		if err != nil {
+			l.addToken(NUMBER, vString, 0)
+			return l.error("invalid number %s", vString)
+		}
+		l.addToken(NUMBER, vString, v)
+		return nil
+	}
+	if isFloat {
+		v, err := strconv.ParseFloat(digits, 64)
+		if err != nil {
+			l.addToken(NUMBER, vString, 0)
+			return l.error("invalid number %s", vString)
 		}
+		l.addToken(NUMBER, vString, v)
+		return nil
+	}
+	if base != 10 {
+		digits = digits[2:]
+	}
+	// 超出64位整数范围时自动使用大整数
+	v, err := strconv.ParseInt(digits, base, 64)
+	if err != nil || suffix == 'n' {
+		b, _ := new(big.Int).SetString(digits, base)
+		l.addToken(NUMBER, vString, b)
+		return nil
+	}
+	l.addToken(NUMBER, vString, int(v))
+	return nil
+}
+
+func (l *Lexer) scanIdentifier() {
+	for isAlphaNumeric(l.peek()) {
+		l.advance()
 	}
 
-	return err
+	value := string(l.src[l.start:l.current])
+	if tokenType, ok := KEYWORDS[value]; ok {
+		l.addToken(tokenType, value)
+	} else {
+		l.addToken(IDENTIFIER, value)
+	}
 }
 
 func (l *Lexer) addToken(_type TokenType, value string, literals ...interface{}) {
@@ -259,24 +397,42 @@ func (l *Lexer) addToken(_type TokenType, value string, literals ...interface{})
 		literal = literals[0]
 	}
 
-	l.tokens = append(l.tokens, NewToken(_type, value, literal, l.line))
+	l.addBlankLine()
+	l.tokens = append(l.tokens, &Token{
+		_type:   _type,
+		value:   value,
+		literal: literal,
+		start:   l.startAt,
+		end:     l.position(),
+		source:  l.source,
+		trivia:  l.trivia,
+	})
+	l.trivia = nil
 }
 
-func (l *Lexer) scanToken() error {
-	var (
-		c   rune
-		err error
-
-		ok bool
-	)
-
-	c, err = l.readRune()
-	if err != nil {
-		return err
+// 与上一个token或注释之间有空行时记录一个空行
+func (l *Lexer) addBlankLine() {
+	if l.newlines > 1 {
+		l.trivia = append(l.trivia, Trivia{Kind: BlankLineTrivia, Start: l.blankAt, End: l.startAt})
 	}
-	if l.end {
-		return nil
+	l.newlines = 0
+}
+
+func (l *Lexer) scanComment() {
+	for !l.isAtEnd() && l.peek() != '\n' {
+		l.advance()
 	}
+	l.addBlankLine()
+	l.trivia = append(l.trivia, Trivia{
+		Kind:  CommentTrivia,
+		Text:  strings.TrimRight(string(l.src[l.start:l.current]), " \t\r"),
+		Start: l.startAt,
+		End:   l.position(),
+	})
+}
+
+func (l *Lexer) scanToken() error {
+	c := l.advance()
 
 	switch c {
 	case '(':
@@ -284,8 +440,22 @@ func (l *Lexer) scanToken() error {
 	case ')':
 		l.addToken(RIGHT_PAREN, ")")
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].braces++
+		}
 		l.addToken(LEFT_BRACE, "{")
 	case '}':
+		// 插值表达式结束, 继续扫描字符串的剩余部分
+		if n := len(l.interpolations); n > 0 && l.interpolations[n-1].braces == 0 {
+			triple := l.interpolations[n-1].triple
+			l.interpolations = l.interpolations[:n-1]
+			l.addToken(RIGHT_BRACE, "}")
+			l.start = l.current
+			l.startAt = l.position()
+			return l.scanString(triple)
+		} else if n > 0 {
+			l.interpolations[n-1].braces--
+		}
 		l.addToken(RIGHT_BRACE, "}")
 	case '[':
 		l.addToken(LEFT_BRACKET, "[")
@@ -294,94 +464,145 @@ func (l *Lexer) scanToken() error {
 	case ',':
 		l.addToken(COMMA, ",")
 	case '.':
-		l.addToken(DOT, ".")
+		if l.peek() == '.' && l.peekNext() == '.' {
+			l.advance()
+			l.advance()
+			l.addToken(DOT_DOT_DOT, "...")
+		} else {
+			l.addToken(DOT, ".")
+		}
 	case '?':
 		l.addToken(QUESTION, "?")
 	case ':':
 		l.addToken(COLON, ":")
 	case '-':
-		if ok, err = l.match('-'); ok {
+		if l.match('-') {
 			l.addToken(MINUSMINUS, "--")
+		} else if l.match('=') {
+			l.addToken(MINUS_EQUAL, "-=")
 		} else {
 			l.addToken(MINUS, "-")
 		}
 	case '+':
-		if ok, err = l.match('+'); ok {
+		if l.match('+') {
 			l.addToken(PLUSPLUS, "++")
+		} else if l.match('=') {
+			l.addToken(PLUS_EQUAL, "+=")
 		} else {
 			l.addToken(PLUS, "+")
 		}
 	case ';':
 		l.addToken(SEMICOLON, ";")
 	case '*':
-		l.addToken(STAR, "*")
+		if l.match('*') {
+			l.addToken(STAR_STAR, "**")
+		} else if l.match('=') {
+			l.addToken(STAR_EQUAL, "*=")
+		} else {
+			l.addToken(STAR, "*")
+		}
+	case '%':
+		if l.match('=') {
+			l.addToken(PERCENT_EQUAL, "%=")
+		} else {
+			l.addToken(PERCENT, "%")
+		}
+	case '&':
+		l.addToken(AMPERSAND, "&")
+	case '|':
+		l.addToken(PIPE, "|")
+	case '^':
+		l.addToken(CARET, "^")
+	case '~':
+		l.addToken(TILDE, "~")
 	case '/':
-		if ok, err = l.match('/'); ok {
-			_, err = l.readUntil('\n')
+		if l.match('/') {
+			l.scanComment()
+		} else if l.match('=') {
+			l.addToken(SLASH_EQUAL, "/=")
 		} else {
 			l.addToken(SLASH, "/")
 		}
 	case '!':
-		if ok, err = l.match('='); ok {
+		if l.match('=') {
 			l.addToken(BANG_EQUAL, "!=")
 		} else {
 			l.addToken(BANG, "!")
 		}
 	case '=':
-		if ok, err = l.match('='); ok {
+		if l.match('=') {
 			l.addToken(EQUAL_EQUAL, "==")
 		} else {
 			l.addToken(EQUAL, "=")
 		}
 	case '<':
-		if ok, err = l.match('='); ok {
+		if l.match('=') {
 			l.addToken(LESS_EQUAL, "<=")
+		} else if l.match('<') {
+			l.addToken(LESS_LESS, "<<")
 		} else {
 			l.addToken(LESS, "<")
 		}
 	case '>':
-		if ok, err = l.match('='); ok {
+		if l.match('=') {
 			l.addToken(GREATER_EQUAL, ">=")
+		} else if l.match('>') {
+			l.addToken(GREATER_GREATER, ">>")
 		} else {
 			l.addToken(GREATER, ">")
 		}
-	case ' ':
-	case '\r':
-	case '\t':
+	case ' ', '\r', '\t':
 	case '\n':
-		l.line++
+		l.newlines++
+		if l.newlines == 1 {
+			l.blankAt = l.position()
+		}
 	case '"': // string
-		err = l.scanString()
+		triple := l.peek() == '"' && l.peekNext() == '"'
+		if triple {
+			l.advance()
+			l.advance()
+			// 三引号后紧跟的换行不计入字符串
+			if !l.match('\n') && bytes.HasPrefix(l.src[l.current:], []byte("\r\n")) {
+				l.advance()
+				l.advance()
+			}
+		}
+		return l.scanString(triple)
+	case '`':
+		return l.scanRawString()
 	default:
 		if isDigit(c) {
-			err = l.scanNumber(c)
+			return l.scanNumber()
 		} else if isAlpha(c) {
-			err = l.scanIdentifier(c)
+			l.scanIdentifier()
 		} else if c != '\u0000' {
-			err = fmt.Errorf("line %d error: unexpected character: %c", l.line, c)
+			return l.error("unexpected character: %c", c)
 		}
 	}
 
-	return err
+	return nil
 }
 
 func (l *Lexer) ScanTokens() {
-	var (
-		err error
-	)
-	for !l.end {
-		err = l.scanToken()
-		if err != nil {
-			break
+	if len(l.errs) > 0 {
+		return
+	}
+	// 出错后跳过出错的字符继续扫描, 一次报告所有错误
+	for !l.isAtEnd() {
+		l.start = l.current
+		l.startAt = l.position()
+		if err := l.scanToken(); err != nil {
+			l.errs = append(l.errs, err)
+			// 留下占位token, 使语法分析不再为同一处报告错误
+			l.addToken(ILLEGAL, string(l.src[l.start:l.current]))
 		}
 	}
 
-	l.addToken(EOF, "", nil)
-
-	if err == io.EOF {
-		return
-	} else if err != nil {
-		l.err = fmt.Errorf("lexer error: %v", err)
-		return
+	l.start = l.current
+	l.startAt = l.position()
+	if len(l.interpolations) > 0 {
+		l.errs = append(l.errs, l.error("unterminated string interpolation"))
 	}
+	l.addToken(EOF, "", nil)
 }
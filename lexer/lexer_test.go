@@ -19,3 +19,109 @@ func TestLexer(t *testing.T) {
 		fmt.Println(l.tokens)
 	}
 }
+
+func TestScanStrings(t *testing.T) {
+	testCases := map[string][]interface{}{
+		`"a\tb\n\"c\"\\"`:              {"a\tb\n\"c\"\\"},
+		`"\x41é\u{1F600}"`:             {"Aé😀"},
+		"`raw\\n ${x}`":                {"raw\\n ${x}"},
+		"\"\"\"\nline\n\"two\" \"\"\"": {"line\n\"two\" "},
+		`"a ${b} c ${d}"`:              {"a ", "b", "}", " c ", "d", "}", ""},
+		`"${ {1: 2}[1] }"`:             {"", "{", 1, ":", 2, "}", "[", 1, "]", "}", ""},
+	}
+	for source, expected := range testCases {
+		l := NewLexer(strings.NewReader(source))
+		l.ScanTokens()
+		if err := l.GetError(); err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		tokens := l.GetTokens()[:len(l.GetTokens())-1]
+		if len(tokens) != len(expected) {
+			t.Fatalf("%s: expected %d tokens, but got %v", source, len(expected), tokens)
+		}
+		for n, token := range tokens {
+			value := token.GetLiteral()
+			if value == nil {
+				value = token.GetValue()
+			}
+			if value != expected[n] {
+				t.Errorf("%s: token %d expected %q, but got %q", source, n, expected[n], value)
+			}
+		}
+	}
+}
+
+func TestScanNumbers(t *testing.T) {
+	testCases := map[string]interface{}{
+		`123`:                 123,
+		`1_000_000`:           1000000,
+		`0xff_ff`:             0xffff,
+		`0o17`:                017,
+		`0b1010`:              10,
+		`1.5`:                 1.5,
+		`1.5e3`:               1500.0,
+		`2E-2`:                0.02,
+		`9223372036854775807`: 9223372036854775807,
+	}
+	for source, expected := range testCases {
+		l := NewLexer(strings.NewReader(source))
+		l.ScanTokens()
+		if err := l.GetError(); err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		if value := l.GetTokens()[0].GetLiteral(); value != expected {
+			t.Errorf("%s: expected %v[%T], but got %v[%T]", source, expected, expected, value, value)
+		}
+	}
+
+	bigNumbers := map[string]string{
+		`9223372036854775808`: "9223372036854775808",
+		`1n`:                  "1",
+		`0xffn`:               "255",
+		`19.99d`:              "19.99",
+		`1_000.50d`:           "1000.50",
+		`1e3d`:                "1000",
+	}
+	for source, expected := range bigNumbers {
+		l := NewLexer(strings.NewReader(source))
+		l.ScanTokens()
+		if err := l.GetError(); err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		if value := fmt.Sprint(l.GetTokens()[0].GetLiteral()); value != expected {
+			t.Errorf("%s: expected %s, but got %s", source, expected, value)
+		}
+	}
+
+	for _, source := range []string{`0x`, `12abc`, `0b102`, `1__0`, `1_`, `1.5n`, `0b1d`, `1nd`} {
+		l := NewLexer(strings.NewReader(source))
+		l.ScanTokens()
+		if l.GetError() == nil {
+			t.Errorf("%s: expected error", source)
+		}
+	}
+}
+
+func TestScanTrivia(t *testing.T) {
+	source := "// a\nvar x; // b\n\n\n// c\nx;\n// d"
+	l := NewLexer(strings.NewReader(source))
+	l.ScanTokens()
+	if err := l.GetError(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int][]string{
+		0: {"// a"},
+		3: {"// b", "", "// c"},
+		5: {"// d"},
+	}
+	for n, token := range l.GetTokens() {
+		got := make([]string, 0)
+		for _, trivia := range token.GetTrivia() {
+			got = append(got, trivia.Text)
+		}
+		if want := expected[n]; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("trivia of token %d %v: expected %q, but got %q", n, token, want, got)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package lexer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 源码中的位置, 行与列从1开始, 列按字符计数, Offset为字节偏移
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+type Source struct {
+	Name string
+	Text []byte
+
+	lines []int // 每一行起始的字节偏移
+}
+
+func NewSource(name string, text []byte) *Source {
+	lines := []int{0}
+	for i, b := range text {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &Source{
+		Name:  name,
+		Text:  text,
+		lines: lines,
+	}
+}
+
+// 获取第n行的内容(不包括换行符)
+func (s *Source) Line(n int) string {
+	if s == nil || n < 1 || n > len(s.lines) {
+		return ""
+	}
+	start := s.lines[n-1]
+	end := len(s.Text)
+	if n < len(s.lines) {
+		end = s.lines[n] - 1
+	}
+	return strings.TrimRight(string(s.Text[start:end]), "\r")
+}
+
+// 根据字节偏移计算位置
+func (s *Source) Position(offset int) Position {
+	if s == nil {
+		return Position{}
+	}
+	if offset > len(s.Text) {
+		offset = len(s.Text)
+	}
+	line := sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > offset })
+	column := utf8.RuneCount(s.Text[s.lines[line-1]:offset]) + 1
+	return Position{Line: line, Column: column, Offset: offset}
+}
+
+// 根据行与列(按字符计数)计算位置, 超出范围时取最近的有效位置
+func (s *Source) PositionAt(line, column int) Position {
+	if s == nil || line < 1 {
+		return Position{Line: 1, Column: 1}
+	}
+	if line > len(s.lines) {
+		return s.Position(len(s.Text))
+	}
+	offset := s.lines[line-1]
+	for n := 1; n < column && offset < len(s.Text) && s.Text[offset] != '\n'; n++ {
+		_, size := utf8.DecodeRune(s.Text[offset:])
+		offset += size
+	}
+	return s.Position(offset)
+}
+
+// 形如file:line:col的位置描述
+func (s *Source) Location(pos Position) string {
+	if s == nil || len(s.Name) == 0 {
+		return pos.String()
+	}
+	return fmt.Sprintf("%s:%s", s.Name, pos)
+}
+
+// 输出start所在的源码行, 并在[start, end)下方标注^
+func (s *Source) Snippet(start, end Position) string {
+	line := s.Line(start.Line)
+	if strings.TrimSpace(line) == "" {
+		return ""
+	}
+
+	var (
+		builder = &strings.Builder{}
+		column  = 1
+		width   = 1
+	)
+	if end.Line == start.Line && end.Column > start.Column {
+		width = end.Column - start.Column
+	} else if end.Line > start.Line {
+		width = utf8.RuneCountInString(line) - start.Column + 1
+	}
+
+	builder.WriteString("    " + line + "\n    ")
+	// 保留制表符使^与源码对齐
+	for _, r := range line {
+		if column >= start.Column {
+			break
+		}
+		if r == '\t' {
+			builder.WriteRune('\t')
+		} else {
+			builder.WriteRune(' ')
+		}
+		column++
+	}
+	if width < 1 {
+		width = 1
+	}
+	builder.WriteString(strings.Repeat("^", width))
+	return builder.String()
+}
+
+// 生成带有位置与源码片段的错误信息
+func FormatError(source *Source, start, end Position, message string) string {
+	msg := fmt.Sprintf("%s: %s", source.Location(start), message)
+	if snippet := source.Snippet(start, end); len(snippet) > 0 {
+		msg += "\n" + snippet
+	}
+	return msg
+}
+
+// 获取文件名, source为nil时返回空字符串
+func (s *Source) GetName() string {
+	if s == nil {
+		return ""
+	}
+	return s.Name
+}
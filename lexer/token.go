@@ -6,7 +6,10 @@ type Token struct {
 	_type   TokenType
 	value   string
 	literal interface{}
-	line    uint16
+	start   Position
+	end     Position
+	source  *Source
+	trivia  []Trivia // 位于该token之前的注释与空行
 }
 
 func (t *Token) GetType() TokenType {
@@ -21,19 +24,59 @@ func (t *Token) GetLiteral() interface{} {
 	return t.literal
 }
 
-func (t *Token) GetLine() uint16 {
-	return t.line
+func (t *Token) GetLine() int {
+	return t.start.Line
+}
+
+func (t *Token) GetColumn() int {
+	return t.start.Column
+}
+
+// token起始位置
+func (t *Token) GetStart() Position {
+	return t.start
+}
+
+// token结束位置(不包含)
+func (t *Token) GetEnd() Position {
+	return t.end
+}
+
+func (t *Token) GetSource() *Source {
+	return t.source
+}
+
+// token之前的注释与空行, 按出现顺序排列
+func (t *Token) GetTrivia() []Trivia {
+	return t.trivia
+}
+
+// 形如file:line:col的位置描述
+func (t *Token) Location() string {
+	return t.source.Location(t.start)
 }
 
 func (t *Token) String() string {
 	return fmt.Sprintf("%s:%s", t._type, t.value)
 }
 
-func NewToken(_type TokenType, value string, literal interface{}, line uint16) *Token {
+func NewToken(_type TokenType, value string, literal interface{}, line int) *Token {
 	return &Token{
 		_type:   _type,
 		value:   value,
 		literal: literal,
-		line:    line,
+		start:   Position{Line: line, Column: 1},
+		end:     Position{Line: line, Column: 1},
+	}
+}
+
+// 使用已有token的位置创建新的token
+func NewTokenFrom(token *Token, _type TokenType, value string) *Token {
+	return &Token{
+		_type:  _type,
+		value:  value,
+		start:  token.start,
+		end:    token.end,
+		source: token.source,
 	}
 }
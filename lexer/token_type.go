@@ -21,6 +21,11 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
+	PERCENT
+	AMPERSAND
+	PIPE
+	CARET
+	TILDE
 
 	// One or two character tokens.
 	BANG
@@ -33,10 +38,20 @@ const (
 	LESS_EQUAL
 	PLUSPLUS
 	MINUSMINUS
+	STAR_STAR
+	LESS_LESS
+	GREATER_GREATER
+	PLUS_EQUAL
+	MINUS_EQUAL
+	STAR_EQUAL
+	SLASH_EQUAL
+	PERCENT_EQUAL
+	DOT_DOT_DOT
 
 	// Literals.
 	IDENTIFIER
 	STRING
+	INTERPOLATION // 插值字符串中位于${之前的部分
 	NUMBER
 
 	// Keywords.
@@ -58,7 +73,16 @@ const (
 	WHILE
 	BREAK
 	CONTINUE
+	IN
+	TRY
+	CATCH
+	FINALLY
+	THROW
+	IMPORT
+	EXPORT
 
+	// 词法错误处的占位token, 错误已由词法分析器报告
+	ILLEGAL
 	EOF
 )
 
@@ -81,4 +105,11 @@ var KEYWORDS = map[string]TokenType{
 	"while":    WHILE,
 	"break":    BREAK,
 	"continue": CONTINUE,
+	"in":       IN,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"import":   IMPORT,
+	"export":   EXPORT,
 }
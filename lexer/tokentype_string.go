@@ -24,43 +24,66 @@ func _() {
 	_ = x[SEMICOLON-13]
 	_ = x[SLASH-14]
 	_ = x[STAR-15]
-	_ = x[BANG-16]
-	_ = x[BANG_EQUAL-17]
-	_ = x[EQUAL-18]
-	_ = x[EQUAL_EQUAL-19]
-	_ = x[GREATER-20]
-	_ = x[GREATER_EQUAL-21]
-	_ = x[LESS-22]
-	_ = x[LESS_EQUAL-23]
-	_ = x[PLUSPLUS-24]
-	_ = x[MINUSMINUS-25]
-	_ = x[IDENTIFIER-26]
-	_ = x[STRING-27]
-	_ = x[NUMBER-28]
-	_ = x[AND-29]
-	_ = x[CLASS-30]
-	_ = x[ELSE-31]
-	_ = x[FALSE-32]
-	_ = x[FUN-33]
-	_ = x[FOR-34]
-	_ = x[IF-35]
-	_ = x[NIL-36]
-	_ = x[OR-37]
-	_ = x[PRINT-38]
-	_ = x[RETURN-39]
-	_ = x[SUPER-40]
-	_ = x[THIS-41]
-	_ = x[TRUE-42]
-	_ = x[VAR-43]
-	_ = x[WHILE-44]
-	_ = x[BREAK-45]
-	_ = x[CONTINUE-46]
-	_ = x[EOF-47]
+	_ = x[PERCENT-16]
+	_ = x[AMPERSAND-17]
+	_ = x[PIPE-18]
+	_ = x[CARET-19]
+	_ = x[TILDE-20]
+	_ = x[BANG-21]
+	_ = x[BANG_EQUAL-22]
+	_ = x[EQUAL-23]
+	_ = x[EQUAL_EQUAL-24]
+	_ = x[GREATER-25]
+	_ = x[GREATER_EQUAL-26]
+	_ = x[LESS-27]
+	_ = x[LESS_EQUAL-28]
+	_ = x[PLUSPLUS-29]
+	_ = x[MINUSMINUS-30]
+	_ = x[STAR_STAR-31]
+	_ = x[LESS_LESS-32]
+	_ = x[GREATER_GREATER-33]
+	_ = x[PLUS_EQUAL-34]
+	_ = x[MINUS_EQUAL-35]
+	_ = x[STAR_EQUAL-36]
+	_ = x[SLASH_EQUAL-37]
+	_ = x[PERCENT_EQUAL-38]
+	_ = x[DOT_DOT_DOT-39]
+	_ = x[IDENTIFIER-40]
+	_ = x[STRING-41]
+	_ = x[INTERPOLATION-42]
+	_ = x[NUMBER-43]
+	_ = x[AND-44]
+	_ = x[CLASS-45]
+	_ = x[ELSE-46]
+	_ = x[FALSE-47]
+	_ = x[FUN-48]
+	_ = x[FOR-49]
+	_ = x[IF-50]
+	_ = x[NIL-51]
+	_ = x[OR-52]
+	_ = x[PRINT-53]
+	_ = x[RETURN-54]
+	_ = x[SUPER-55]
+	_ = x[THIS-56]
+	_ = x[TRUE-57]
+	_ = x[VAR-58]
+	_ = x[WHILE-59]
+	_ = x[BREAK-60]
+	_ = x[CONTINUE-61]
+	_ = x[IN-62]
+	_ = x[TRY-63]
+	_ = x[CATCH-64]
+	_ = x[FINALLY-65]
+	_ = x[THROW-66]
+	_ = x[IMPORT-67]
+	_ = x[EXPORT-68]
+	_ = x[ILLEGAL-69]
+	_ = x[EOF-70]
 }
 
-const _TokenType_name = "TokenNoneLEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACELEFT_BRACKETRIGHT_BRACKETCOMMADOTQUESTIONCOLONMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALPLUSPLUSMINUSMINUSIDENTIFIERSTRINGNUMBERANDCLASSELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILEBREAKCONTINUEEOF"
+const _TokenType_name = "TokenNoneLEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACELEFT_BRACKETRIGHT_BRACKETCOMMADOTQUESTIONCOLONMINUSPLUSSEMICOLONSLASHSTARPERCENTAMPERSANDPIPECARETTILDEBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALPLUSPLUSMINUSMINUSSTAR_STARLESS_LESSGREATER_GREATERPLUS_EQUALMINUS_EQUALSTAR_EQUALSLASH_EQUALPERCENT_EQUALDOT_DOT_DOTIDENTIFIERSTRINGINTERPOLATIONNUMBERANDCLASSELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILEBREAKCONTINUEINTRYCATCHFINALLYTHROWIMPORTEXPORTILLEGALEOF"
 
-var _TokenType_index = [...]uint16{0, 9, 19, 30, 40, 51, 63, 76, 81, 84, 92, 97, 102, 106, 115, 120, 124, 128, 138, 143, 154, 161, 174, 178, 188, 196, 206, 216, 222, 228, 231, 236, 240, 245, 248, 251, 253, 256, 258, 263, 269, 274, 278, 282, 285, 290, 295, 303, 306}
+var _TokenType_index = [...]uint16{0, 9, 19, 30, 40, 51, 63, 76, 81, 84, 92, 97, 102, 106, 115, 120, 124, 131, 140, 144, 149, 154, 158, 168, 173, 184, 191, 204, 208, 218, 226, 236, 245, 254, 269, 279, 290, 300, 311, 324, 335, 345, 351, 364, 370, 373, 378, 382, 387, 390, 393, 395, 398, 400, 405, 411, 416, 420, 424, 427, 432, 437, 445, 447, 450, 455, 462, 467, 473, 479, 486, 489}
 
 func (i TokenType) String() string {
 	if i >= TokenType(len(_TokenType_index)-1) {
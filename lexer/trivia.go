@@ -0,0 +1,20 @@
+package lexer
+
+type TriviaKind int
+
+const (
+	CommentTrivia   TriviaKind = iota // 行注释, Text包含开头的//
+	BlankLineTrivia                   // 一个或多个连续的空行
+)
+
+// 注释与空行等不影响语法的内容, 附加在其后的第一个token上
+type Trivia struct {
+	Kind  TriviaKind
+	Text  string
+	Start Position
+	End   Position
+}
+
+func (t Trivia) IsComment() bool {
+	return t.Kind == CommentTrivia
+}
@@ -11,3 +11,20 @@ func isAlpha(r rune) bool {
 func isAlphaNumeric(r rune) bool {
 	return isAlpha(r) || isDigit(r)
 }
+
+// 是否为指定进制(2, 8, 10, 16)的数字
+func isDigitOf(r rune, base int) bool {
+	switch base {
+	case 2:
+		return r == '0' || r == '1'
+	case 8:
+		return '0' <= r && r <= '7'
+	case 16:
+		return isHexDigit(r)
+	}
+	return isDigit(r)
+}
+
+func isHexDigit(r rune) bool {
+	return isDigit(r) || ('a' <= r && r <= 'f') || ('A' <= r && r <= 'F')
+}
@@ -0,0 +1,240 @@
+package lox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/WAY29/LoxGo/interpreter"
+)
+
+// 附加调试器, 之后由树遍历解释器执行的代码在断点与单步时暂停并调用handler, main为主程序的文件名
+func (lox *Lox) Debug(main string, handler interpreter.DebugHandler) *interpreter.Debugger {
+	debugger := interpreter.NewDebugger(lox.interpreter, main, handler)
+	lox.interpreter.SetDebugger(debugger)
+	return debugger
+}
+
+// 在命令行调试器中运行文件, 在第一条语句前暂停, 从in读取调试命令
+func (lox *Lox) DebugFile(file string, in io.Reader) {
+	fp, err := os.Open(file)
+	if err != nil {
+		panic(err)
+	}
+	defer fp.Close()
+
+	c := &console{
+		out:     lox.out,
+		scanner: bufio.NewScanner(in),
+		sources: make(map[string][]string),
+	}
+	c.debugger = lox.Debug(file, c.stop)
+	c.debugger.StopOnEntry()
+	defer lox.interpreter.SetDebugger(nil)
+
+	_, err = lox.EvalNamed(file, fp)
+	if _, ok := err.(*interpreter.TerminateSignal); !ok && err != nil {
+		fmt.Fprintf(lox.out, "[ERROR] %s\n", err)
+	}
+}
+
+var errQuit = errors.New("quit")
+
+const consoleHelp = `Commands:
+  c, continue          run until the next breakpoint
+  s, step              step into the next line
+  n, next              step over calls to the next line
+  o, out               run until the current function returns
+  b, break [file:]line set a breakpoint, list breakpoints without argument
+  d, delete [file:]line
+                       delete a breakpoint
+  bt, backtrace        show the call stack
+  f, frame n           select a frame of the call stack
+  v, vars              show variables of each scope in the selected frame
+  p, print expr        evaluate an expression in the selected frame
+  w, watch [expr]      evaluate an expression on every stop, list watches without argument
+  unwatch n            remove a watch expression
+  l, list              show source around the current line
+  q, quit              terminate the program`
+
+// 命令行调试器
+type console struct {
+	out      io.Writer
+	scanner  *bufio.Scanner
+	debugger *interpreter.Debugger
+	watches  []string
+	sources  map[string][]string // 按行缓存的源码
+}
+
+func (c *console) stop(pause *interpreter.Pause) (interpreter.StepMode, error) {
+	frame := 0
+	c.where(pause, frame, true)
+	c.showWatches(pause, frame)
+
+	for {
+		fmt.Fprint(c.out, "debug > ")
+		if !c.scanner.Scan() {
+			fmt.Fprintln(c.out)
+			return interpreter.StepContinue, errQuit
+		}
+		line := strings.TrimSpace(c.scanner.Text())
+		if line == "" {
+			continue
+		}
+		command, arg := line, ""
+		if n := strings.IndexAny(line, " \t"); n > 0 {
+			command, arg = line[:n], strings.TrimSpace(line[n:])
+		}
+
+		switch command {
+		case "c", "continue":
+			return interpreter.StepContinue, nil
+		case "s", "step":
+			return interpreter.StepIn, nil
+		case "n", "next":
+			return interpreter.StepOver, nil
+		case "o", "out":
+			return interpreter.StepOut, nil
+		case "q", "quit":
+			return interpreter.StepContinue, errQuit
+		case "b", "break":
+			if arg == "" {
+				for _, breakpoint := range c.debugger.Breakpoints() {
+					fmt.Fprintf(c.out, "%s:%d\n", breakpoint.File, breakpoint.Line)
+				}
+			} else if file, line, ok := c.location(pause.Frames[frame], arg); ok {
+				c.debugger.SetBreakpoint(file, line)
+				fmt.Fprintf(c.out, "Breakpoint at %s:%d\n", file, line)
+			}
+		case "d", "delete":
+			if file, line, ok := c.location(pause.Frames[frame], arg); ok && !c.debugger.ClearBreakpoint(file, line) {
+				fmt.Fprintf(c.out, "No breakpoint at %s:%d\n", file, line)
+			}
+		case "bt", "backtrace":
+			for n, f := range pause.Frames {
+				marker := " "
+				if n == frame {
+					marker = "*"
+				}
+				fmt.Fprintf(c.out, "%s #%d %s at %s\n", marker, n, f.Function, position(f))
+			}
+		case "f", "frame":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(pause.Frames) {
+				fmt.Fprintf(c.out, "Frame must be between 0 and %d.\n", len(pause.Frames)-1)
+				continue
+			}
+			frame = n
+			c.where(pause, frame, false)
+		case "v", "vars":
+			scopes, _ := pause.Scopes(frame)
+			for _, scope := range scopes {
+				fmt.Fprintf(c.out, "%s:\n", scope.Name)
+				for _, variable := range scope.Variables {
+					fmt.Fprintf(c.out, "  %s = %s\n", variable.Name, interpreter.DebugString(variable.Value))
+				}
+			}
+		case "p", "print":
+			c.evaluate(pause, frame, arg)
+		case "w", "watch":
+			if arg != "" {
+				c.watches = append(c.watches, arg)
+			}
+			c.showWatches(pause, frame)
+		case "unwatch":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(c.watches) {
+				fmt.Fprintln(c.out, "No such watch expression.")
+				continue
+			}
+			c.watches = append(c.watches[:n], c.watches[n+1:]...)
+		case "l", "list":
+			c.list(pause.Frames[frame], 5)
+		case "h", "help":
+			fmt.Fprintln(c.out, consoleHelp)
+		default:
+			fmt.Fprintf(c.out, "Unknown command '%s', type 'help' for a list of commands.\n", command)
+		}
+	}
+}
+
+func position(frame interpreter.Frame) string {
+	if frame.Line == 0 {
+		return "<host>"
+	}
+	return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+}
+
+// 显示帧的位置与所在行的源码
+func (c *console) where(pause *interpreter.Pause, n int, stopped bool) {
+	frame := pause.Frames[n]
+	if stopped {
+		fmt.Fprintf(c.out, "Stopped (%s) in %s at %s\n", pause.Reason, frame.Function, position(frame))
+	} else {
+		fmt.Fprintf(c.out, "#%d %s at %s\n", n, frame.Function, position(frame))
+	}
+	c.list(frame, 0)
+}
+
+func (c *console) evaluate(pause *interpreter.Pause, frame int, expression string) {
+	value, err := pause.Evaluate(frame, expression)
+	if err != nil {
+		fmt.Fprintf(c.out, "[ERROR] %s\n", err)
+		return
+	}
+	fmt.Fprintln(c.out, interpreter.DebugString(value))
+}
+
+func (c *console) showWatches(pause *interpreter.Pause, frame int) {
+	for n, watch := range c.watches {
+		value, err := pause.Evaluate(frame, watch)
+		if err != nil {
+			fmt.Fprintf(c.out, "  #%d %s: %s\n", n, watch, strings.SplitN(err.Error(), "\n", 2)[0])
+		} else {
+			fmt.Fprintf(c.out, "  #%d %s = %s\n", n, watch, interpreter.DebugString(value))
+		}
+	}
+}
+
+// 显示帧所在行前后context行的源码
+func (c *console) list(frame interpreter.Frame, context int) {
+	if frame.Line == 0 {
+		return
+	}
+	lines, ok := c.sources[frame.File]
+	if !ok {
+		if data, err := ioutil.ReadFile(frame.File); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		c.sources[frame.File] = lines
+	}
+	for line := frame.Line - context; line <= frame.Line+context; line++ {
+		if line < 1 || line > len(lines) {
+			continue
+		}
+		marker := " "
+		if line == frame.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(c.out, "%s %4d | %s\n", marker, line, lines[line-1])
+	}
+}
+
+// 解析 [file:]line 形式的位置, 省略文件时为帧所在的文件
+func (c *console) location(frame interpreter.Frame, arg string) (string, int, bool) {
+	file, lineText := frame.File, arg
+	if n := strings.LastIndexByte(arg, ':'); n >= 0 {
+		file, lineText = arg[:n], arg[n+1:]
+	}
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		fmt.Fprintln(c.out, "Expect a location like 'file.lox:12' or '12'.")
+		return "", 0, false
+	}
+	return file, line, true
+}
@@ -0,0 +1,181 @@
+package lox_test
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/WAY29/LoxGo/interpreter"
+	"github.com/WAY29/LoxGo/lox"
+)
+
+func ExampleLox() {
+	x := lox.NewLox()
+	x.Define("name", "lox")
+	x.RegisterFunc("upper", func(_ interpreter.Caller, arguments []interface{}) (interface{}, error) {
+		return strings.ToUpper(arguments[0].(string)), nil
+	}, interpreter.ArgString)
+
+	if _, err := x.EvalString(`fun greet(n) { return "hello " + upper(name) + n; }`); err != nil {
+		fmt.Println(err)
+		return
+	}
+	result, err := x.Call("greet", "!")
+	fmt.Println(result, err)
+
+	_, err = x.EvalString(`upper(1);`)
+	fmt.Println(err)
+
+	// 回调通过caller调用传入的Lox函数, 两种后端相同
+	for _, useVM := range []bool{false, true} {
+		x := lox.NewLox()
+		if useVM {
+			x.UseVM()
+		}
+		x.RegisterFunc("twice", func(caller interpreter.Caller, arguments []interface{}) (interface{}, error) {
+			value, err := caller.Call(arguments[0], arguments[1])
+			if err != nil {
+				return nil, err
+			}
+			return caller.Call(arguments[0], value)
+		}, interpreter.ArgCallable, interpreter.ArgAny)
+		_, err = x.EvalString(`
+class Adder { init(n) { this.n = n; } add(x) { return x + this.n; } }
+print twice(fun (x) { return x * 3; }, 2);
+print twice(Adder(5).add, 1);
+print twice(string, 7);
+`)
+		fmt.Println(err)
+	}
+	// Output:
+	// hello LOX! <nil>
+	// 1:8: Runtime Error at ')': Argument 1 of upper must be string, got 1[int].
+	//     upper(1);
+	//            ^
+	// Stack trace:
+	//     in upper() called at 1:1
+	// 18
+	// 11
+	// 7
+	// <nil>
+	// 18
+	// 11
+	// 7
+	// <nil>
+}
+
+type user struct {
+	Name  string
+	Age   int
+	Views uint64
+}
+
+func (u *user) Greet(prefix string) string {
+	return prefix + u.Name
+}
+
+func ExampleLox_Define() {
+	x := lox.NewLox()
+	u := &user{Name: "bob", Age: 3}
+	x.Define("u", u)
+
+	_, err := x.EvalString(`u.age = u.age + 1; print u.Greet("hi ");`)
+	fmt.Println(u.Age, err)
+
+	x.Define("max", uint64(math.MaxUint64))
+	_, err = x.EvalString(`print max > 0; u.views = max; print u.views;`)
+	fmt.Println(u.Views == math.MaxUint64, err)
+
+	_, err = x.EvalString(`u.age = "x";`)
+	fmt.Println(err)
+	// Output:
+	// hi bob
+	// 4 <nil>
+	// true
+	// 18446744073709551615
+	// true <nil>
+	// 1:3: Runtime Error at 'age': Convert error: can't convert x[string] to int.
+	//     u.age = "x";
+	//       ^^^
+}
+
+type item struct {
+	N int
+}
+
+type bag struct {
+	Tags  []string
+	Items []item
+	M     map[string]int
+	Names map[int]string
+}
+
+func ExampleLox_Define_containers() {
+	for _, useVM := range []bool{false, true} {
+		x := lox.NewLox()
+		if useVM {
+			x.UseVM()
+		}
+		b := &bag{
+			Tags:  []string{"a", "b"},
+			Items: []item{{N: 1}},
+			M:     map[string]int{"k": 1},
+			Names: map[int]string{2: "two", 1: "one"},
+		}
+		tags := []int{1, 2, 3}
+		x.Define("b", b)
+		x.Define("tags", tags)
+
+		_, err := x.EvalString(`
+b.tags[0] = "z";
+b.items[0].n = 5;
+b.m["k"] = 3;
+b.m.j = 4;
+b.names[3] = "three";
+tags[1] = tags[1] * 10;
+for (var n in b.names) print string(n) + " " + b.names[n];
+print len(b.tags) + len(b.names);
+`)
+		fmt.Println(b.Tags, b.Items, b.M, b.Names[3], tags, err)
+	}
+	// Output:
+	// 1 one
+	// 2 two
+	// 3 three
+	// 5
+	// [z b] [{5}] map[j:4 k:3] three [1 20 3] <nil>
+	// 1 one
+	// 2 two
+	// 3 three
+	// 5
+	// [z b] [{5}] map[j:4 k:3] three [1 20 3] <nil>
+}
+
+// 两种后端的运行时错误都提供调用栈
+type tracer interface {
+	Trace() []interpreter.TraceFrame
+}
+
+func ExampleLox_Call() {
+	for _, useVM := range []bool{false, true} {
+		x := lox.NewLox()
+		if useVM {
+			x.UseVM()
+		}
+		x.EvalString(`
+fun inner(x) { return x + missing; }
+fun outer(x) { return inner(x); }
+`)
+		_, err := x.Call("outer", 1)
+		if e, ok := err.(tracer); ok {
+			for _, frame := range e.Trace() {
+				fmt.Println(frame.Function, frame.Line())
+			}
+		}
+	}
+	// Output:
+	// inner 3
+	// outer 0
+	// inner 3
+	// outer 0
+}
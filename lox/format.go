@@ -0,0 +1,60 @@
+package lox
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/WAY29/LoxGo/parser"
+)
+
+// 展开参数中的目录, 递归查找其中的.lox文件, 文件参数原样保留
+func SourceFiles(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(file, ".lox") {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// 格式化文件, 返回文件内容是否不符合格式, check为true时只检查不写回
+func FormatFile(file string, check bool) (bool, error) {
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+	formatted, err := parser.Format(file, source)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(source, formatted) {
+		return false, nil
+	}
+	if !check {
+		if err = ioutil.WriteFile(file, formatted, 0644); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
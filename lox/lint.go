@@ -0,0 +1,23 @@
+package lox
+
+import (
+	"os"
+
+	"github.com/WAY29/LoxGo/interpreter"
+	"github.com/WAY29/LoxGo/parser"
+)
+
+// 静态检查文件, 词法与语法错误以error返回
+func LintFile(file string) ([]*interpreter.Diagnostic, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	tokens, statements, err := parser.ParseTokens(file, fp)
+	if err != nil {
+		return nil, err
+	}
+	return interpreter.NewLinter().Lint(tokens, statements), nil
+}
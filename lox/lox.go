@@ -8,27 +8,93 @@ import (
 	"strings"
 
 	"github.com/WAY29/LoxGo/interpreter"
-	"github.com/WAY29/LoxGo/lexer"
 	"github.com/WAY29/LoxGo/parser"
+	"github.com/WAY29/LoxGo/vm"
 )
 
 type Lox struct {
 	interpreter *interpreter.Interpreter
+	vm          *vm.VM
+	out         io.Writer
+	modulePath  []string
 }
 
 func NewLox() *Lox {
 	return &Lox{
 		interpreter: interpreter.NewInterpreter(),
+		out:         os.Stdout,
 	}
 }
 
+// 使用字节码虚拟机代替树遍历解释器执行代码, 需要在定义全局变量之前调用
+func (lox *Lox) UseVM() {
+	lox.vm = vm.NewVM()
+	lox.vm.SetOutput(lox.out)
+	lox.vm.SetModulePath(lox.modulePath...)
+}
+
+// 设置print语句的输出位置
+func (lox *Lox) SetOutput(out io.Writer) {
+	lox.out = out
+	lox.interpreter.SetOutput(out)
+	if lox.vm != nil {
+		lox.vm.SetOutput(out)
+	}
+}
+
+// 设置模块搜索路径, import的相对路径在导入文件所在目录找不到时依次在这些目录中查找
+func (lox *Lox) SetModulePath(paths ...string) {
+	lox.modulePath = paths
+	lox.interpreter.SetModulePath(paths...)
+	if lox.vm != nil {
+		lox.vm.SetModulePath(paths...)
+	}
+}
+
+// 定义全局变量
+func (lox *Lox) Define(name string, value interface{}) {
+	if lox.vm != nil {
+		lox.vm.Define(name, value)
+	} else {
+		lox.interpreter.Define(name, value)
+	}
+}
+
+// 获取全局变量
+func (lox *Lox) Get(name string) (interface{}, bool) {
+	if lox.vm != nil {
+		return lox.vm.Get(name)
+	}
+	return lox.interpreter.Get(name)
+}
+
+// 注册Go函数, 调用时按argTypes检查参数个数与类型
+func (lox *Lox) RegisterFunc(name string, callback interpreter.CallableFunc, argTypes ...interpreter.ArgType) {
+	lox.Define(name, interpreter.NewTypedLoxBuiltinFunc(callback, name, argTypes...))
+}
+
+// 通过名字调用Lox中定义的函数或类
+func (lox *Lox) Call(name string, arguments ...interface{}) (interface{}, error) {
+	callee, ok := lox.Get(name)
+	if !ok {
+		return nil, interpreter.NewRuntimeError(nil, "Undefined variable '%s'.", name)
+	}
+	if lox.vm != nil {
+		return lox.vm.Call(callee, arguments...)
+	}
+	return lox.interpreter.Call(callee, arguments...)
+}
+
 func (lox *Lox) RunFile(file string) {
 	fp, err := os.Open(file)
 	if err != nil {
 		panic(err)
 	}
+	defer fp.Close()
 
-	lox.Eval(fp)
+	if _, err = lox.EvalNamed(file, fp); err != nil {
+		fmt.Printf("[ERROR] %s\n", err)
+	}
 }
 
 func (lox *Lox) RunPrompt() {
@@ -36,6 +102,7 @@ func (lox *Lox) RunPrompt() {
 	var (
 		line    string
 		results []interface{}
+		err     error
 	)
 
 	scanner := bufio.NewScanner(os.Stdin)
@@ -52,8 +119,9 @@ func (lox *Lox) RunPrompt() {
 			line += ";"
 		}
 
-		results = lox.Eval(strings.NewReader(line))
-		if len(results) < 1 {
+		results, err = lox.EvalNamed("<stdin>", strings.NewReader(line))
+		if err != nil {
+			fmt.Printf("[ERROR] %s\n", err)
 			continue
 		}
 		for _, result := range results {
@@ -65,37 +133,45 @@ func (lox *Lox) RunPrompt() {
 	}
 }
 
-func (lox *Lox) Eval(r io.Reader) []interface{} {
-	// error handle
-	defer func() {
-		var r interface{}
-		if r = recover(); r != nil {
-			if _, ok := r.(*interpreter.ConvertError); ok {
-				fmt.Printf("[ERROR] %s\n", r)
-			} else if _, ok := r.(*interpreter.RuntimeError); ok {
-				fmt.Printf("[ERROR] %s\n", r)
-			} else if _, ok := r.(*parser.ParseError); ok {
-				fmt.Printf("[ERROR] %s\n", r)
-			} else {
-				panic(r)
-			}
-		}
-	}()
+// 执行源码字符串
+func (lox *Lox) EvalString(source string) ([]interface{}, error) {
+	return lox.Eval(strings.NewReader(source))
+}
 
-	l := lexer.NewLexer(r)
-	l.ScanTokens()
-	if l.GetError() != nil {
-		panic(l.GetError())
-	}
+func (lox *Lox) Eval(r io.Reader) ([]interface{}, error) {
+	return lox.EvalNamed("", r)
+}
 
-	p := parser.NewParaser(l.GetTokens())
-	statements := p.Parse()
-	if statements == nil {
-		return nil
+// 执行源码, name用于错误信息中的文件名
+func (lox *Lox) EvalNamed(name string, r io.Reader) ([]interface{}, error) {
+	statements, err := parser.ParseSource(name, r)
+	if err != nil {
+		return nil, err
 	}
+	if lox.vm != nil {
+		defer lox.vm.EnterScript(name)()
+		return lox.evalVM(statements)
+	}
+	defer lox.interpreter.EnterScript(name)()
+
 	resolver := interpreter.NewResolver(lox.interpreter)
-	resolver.ResolveStmts(statements)
+	if err = resolver.ResolveStmts(statements); err != nil {
+		return nil, err
+	}
+
+	return lox.interpreter.Interpret(statements)
+}
 
-	results := lox.interpreter.Interpret(statements)
-	return results
+func (lox *Lox) evalVM(statements []parser.Stmt) ([]interface{}, error) {
+	compiler := vm.NewCompiler()
+	resolver := interpreter.NewResolver(compiler)
+	if err := resolver.ResolveStmts(statements); err != nil {
+		return nil, err
+	}
+
+	function, err := compiler.Compile(statements)
+	if err != nil {
+		return nil, err
+	}
+	return lox.vm.Interpret(function)
 }
@@ -0,0 +1,336 @@
+package lsp
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/WAY29/LoxGo/interpreter"
+	"github.com/WAY29/LoxGo/lexer"
+	"github.com/WAY29/LoxGo/parser"
+)
+
+// 编辑器中打开的文档
+type document struct {
+	uri         string
+	version     int
+	diagnostics []Diagnostic
+
+	// 最近一次成功解析的结果, 存在语法错误时保留之前的结果用于跳转与补全
+	parsed *parsedDocument
+}
+
+type parsedDocument struct {
+	source     *lexer.Source
+	tokens     []*lexer.Token
+	statements []parser.Stmt
+	analysis   *interpreter.Analysis
+}
+
+// file:///a/b.lox 转换为 /a/b.lox, 用作源码名称
+func uriToName(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return uri
+}
+
+// 分析文档, 词法与语法错误时沿用previous中的解析结果
+func newDocument(linter *interpreter.Linter, uri string, version int, text string, previous *document) *document {
+	doc := &document{uri: uri, version: version, diagnostics: make([]Diagnostic, 0)}
+	name := uriToName(uri)
+	tokens, statements, err := parser.ParseTokens(name, strings.NewReader(text))
+	if err != nil {
+		source := lexer.NewSource(name, []byte(text))
+		errs, ok := err.(lexer.ErrorList)
+		if !ok {
+			errs = lexer.ErrorList{err}
+		}
+		for _, err := range errs {
+			doc.diagnostics = append(doc.diagnostics, errorDiagnostic(source, err))
+		}
+		if previous != nil {
+			doc.parsed = previous.parsed
+		}
+		return doc
+	}
+
+	analysis := linter.Analyze(tokens, statements)
+	source := tokens[0].GetSource()
+	for _, d := range analysis.Diagnostics {
+		severity := SeverityWarning
+		// 解析器报告的错误会使程序无法运行
+		if d.Rule == interpreter.RuleResolveError || d.Rule == interpreter.RuleThisOutsideMethod {
+			severity = SeverityError
+		}
+		doc.diagnostics = append(doc.diagnostics, Diagnostic{
+			Range:    toRange(source, d.Start, d.End),
+			Severity: severity,
+			Code:     d.Rule,
+			Source:   "lox",
+			Message:  d.Message,
+		})
+	}
+	doc.parsed = &parsedDocument{
+		source:     source,
+		tokens:     tokens,
+		statements: statements,
+		analysis:   analysis,
+	}
+	return doc
+}
+
+func errorDiagnostic(source *lexer.Source, err error) Diagnostic {
+	var (
+		start   = lexer.Position{Line: 1, Column: 1}
+		end     = start
+		message = err.Error()
+	)
+	switch e := err.(type) {
+	case *lexer.LexError:
+		start, end, message = e.GetStart(), e.GetEnd(), e.Message()
+	case *parser.ParseError:
+		start, end, message = e.GetToken().GetStart(), e.GetToken().GetEnd(), e.Message()
+	}
+	return Diagnostic{
+		Range:    toRange(source, start, end),
+		Severity: SeverityError,
+		Source:   "lox",
+		Message:  message,
+	}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// lexer的位置(从1开始, 列按字符计数)转换为LSP的位置(从0开始, 列按UTF-16编码单元计数)
+func toPosition(source *lexer.Source, pos lexer.Position) Position {
+	character := 0
+	column := 1
+	for _, r := range source.Line(pos.Line) {
+		if column >= pos.Column {
+			break
+		}
+		character += utf16Len(r)
+		column++
+	}
+	return Position{Line: pos.Line - 1, Character: character}
+}
+
+func fromPosition(source *lexer.Source, pos Position) lexer.Position {
+	character := 0
+	column := 1
+	for _, r := range source.Line(pos.Line + 1) {
+		if character >= pos.Character {
+			break
+		}
+		character += utf16Len(r)
+		column++
+	}
+	return source.PositionAt(pos.Line+1, column)
+}
+
+func toRange(source *lexer.Source, start, end lexer.Position) Range {
+	return Range{Start: toPosition(source, start), End: toPosition(source, end)}
+}
+
+func tokenRange(source *lexer.Source, token *lexer.Token) Range {
+	return toRange(source, token.GetStart(), token.GetEnd())
+}
+
+func (p *parsedDocument) location(uri string, token *lexer.Token) Location {
+	return Location{URI: uri, Range: tokenRange(p.source, token)}
+}
+
+// 位于pos的名字及其声明
+func (p *parsedDocument) symbolAt(pos Position) (*interpreter.Symbol, *lexer.Token) {
+	return p.analysis.SymbolAt(fromPosition(p.source, pos).Offset)
+}
+
+// 位于pos的标识符, 用于查找内置函数
+func (p *parsedDocument) identifierAt(pos Position) *lexer.Token {
+	offset := fromPosition(p.source, pos).Offset
+	for _, token := range p.tokens {
+		if token.GetType() == lexer.IDENTIFIER && token.GetStart().Offset <= offset && offset <= token.GetEnd().Offset {
+			return token
+		}
+	}
+	return nil
+}
+
+// 函数签名, 如 add(a, b = 1, ...rest)
+func signature(name string, function *parser.Function) string {
+	printer := parser.NewSourcePrinter()
+	params := make([]string, 0, len(function.Params)+1)
+	for n, param := range function.Params {
+		if function.Defaults[n] != nil {
+			params = append(params, param.GetValue()+" = "+printer.Print(function.Defaults[n]))
+		} else {
+			params = append(params, param.GetValue())
+		}
+	}
+	if function.Rest != nil {
+		params = append(params, "..."+function.Rest.GetValue())
+	}
+	return name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// 悬停时显示的名字种类与声明
+func describe(symbol *interpreter.Symbol) string {
+	name := symbol.Name.GetValue()
+	switch decl := symbol.Decl.(type) {
+	case *parser.Function:
+		if symbol.Kind == interpreter.SymbolFunction {
+			return "function " + signature(name, decl)
+		}
+		return scopeName(symbol) + "variable " + name + " = fun " + signature("", decl)
+	case *parser.Class:
+		if decl.Superclass != nil {
+			return "class " + name + " < " + decl.Superclass.Name.GetValue()
+		}
+		return "class " + name
+	}
+	if symbol.Kind == interpreter.SymbolVariable {
+		return scopeName(symbol) + "variable " + name
+	}
+	return symbol.Kind.String() + " " + name
+}
+
+func scopeName(symbol *interpreter.Symbol) string {
+	if symbol.Global {
+		return "global "
+	}
+	return "local "
+}
+
+func (p *parsedDocument) documentSymbols() []DocumentSymbol {
+	return p.statementSymbols(p.statements)
+}
+
+func (p *parsedDocument) statementSymbols(statements []parser.Stmt) []DocumentSymbol {
+	symbols := make([]DocumentSymbol, 0)
+	for _, stmt := range statements {
+		symbols = append(symbols, p.stmtSymbols(stmt)...)
+	}
+	return symbols
+}
+
+// 函数, 类与变量的声明, 嵌套在函数与类中的声明作为子节点
+func (p *parsedDocument) stmtSymbols(stmt parser.Stmt) []DocumentSymbol {
+	span := func(stmt parser.Stmt) Range {
+		return toRange(p.source, stmt.Span().Start, stmt.Span().End)
+	}
+	switch stmt := stmt.(type) {
+	case *parser.Function:
+		return []DocumentSymbol{p.functionSymbol(stmt, SymbolKindFunction, span(stmt))}
+	case *parser.Class:
+		symbol := DocumentSymbol{
+			Name:           stmt.Name.GetValue(),
+			Kind:           SymbolKindClass,
+			Range:          span(stmt),
+			SelectionRange: tokenRange(p.source, stmt.Name),
+		}
+		for _, methods := range [][]parser.Stmt{stmt.ClassMethods, stmt.Methods} {
+			for _, method := range methods {
+				symbol.Children = append(symbol.Children, p.functionSymbol(method.(*parser.Function), SymbolKindMethod, span(method)))
+			}
+		}
+		return []DocumentSymbol{symbol}
+	case *parser.Var:
+		symbols := make([]DocumentSymbol, 0, len(stmt.Names))
+		for n, name := range stmt.Names {
+			symbol := DocumentSymbol{
+				Name:           name.GetValue(),
+				Kind:           SymbolKindVariable,
+				Range:          span(stmt),
+				SelectionRange: tokenRange(p.source, name),
+			}
+			if lambda, ok := stmt.Initializers[n].(*parser.Lambda); ok {
+				function := lambda.Function.(*parser.Function)
+				symbol.Kind = SymbolKindFunction
+				symbol.Detail = "fun " + signature("", function)
+				symbol.Children = p.statementSymbols(function.Body.(*parser.Block).Statements)
+			}
+			symbols = append(symbols, symbol)
+		}
+		return symbols
+	case *parser.Import:
+		return []DocumentSymbol{{
+			Name:           stmt.Name.GetValue(),
+			Kind:           SymbolKindModule,
+			Range:          span(stmt),
+			SelectionRange: tokenRange(p.source, stmt.Name),
+		}}
+	case *parser.Export:
+		return p.stmtSymbols(stmt.Declaration)
+	case *parser.Block:
+		return p.statementSymbols(stmt.Statements)
+	case *parser.If:
+		return p.statementSymbols([]parser.Stmt{stmt.ThenBranch, stmt.ElseBranch})
+	case *parser.While:
+		return p.stmtSymbols(stmt.Body)
+	case *parser.ForIn:
+		return p.stmtSymbols(stmt.Body)
+	case *parser.Try:
+		return p.statementSymbols([]parser.Stmt{stmt.Body, stmt.CatchBody, stmt.FinallyBody})
+	}
+	return nil
+}
+
+func (p *parsedDocument) functionSymbol(function *parser.Function, kind SymbolKind, r Range) DocumentSymbol {
+	return DocumentSymbol{
+		Name:           function.Name.GetValue(),
+		Detail:         signature(function.Name.GetValue(), function),
+		Kind:           kind,
+		Range:          r,
+		SelectionRange: tokenRange(p.source, function.Name),
+		Children:       p.statementSymbols(function.Body.(*parser.Block).Statements),
+	}
+}
+
+var completionKinds = map[interpreter.SymbolKind]CompletionItemKind{
+	interpreter.SymbolVariable:  CompletionKindVariable,
+	interpreter.SymbolParameter: CompletionKindVariable,
+	interpreter.SymbolFunction:  CompletionKindFunction,
+	interpreter.SymbolClass:     CompletionKindClass,
+	interpreter.SymbolModule:    CompletionKindModule,
+}
+
+// 关键字, 内置函数与文档中声明的名字, 同名的只保留第一个
+func completion(p *parsedDocument, predefined map[string]interface{}) []CompletionItem {
+	items := make([]CompletionItem, 0)
+	seen := make(map[string]bool)
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	if p != nil {
+		for _, symbol := range p.analysis.Symbols {
+			add(CompletionItem{Label: symbol.Name.GetValue(), Kind: completionKinds[symbol.Kind], Detail: describe(symbol)})
+		}
+	}
+	names := make([]string, 0, len(predefined))
+	for name := range predefined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(CompletionItem{Label: name, Kind: CompletionKindFunction, Detail: "builtin function " + name})
+	}
+	keywords := make([]string, 0, len(lexer.KEYWORDS))
+	for keyword := range lexer.KEYWORDS {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		add(CompletionItem{Label: keyword, Kind: CompletionKindKeyword})
+	}
+	return items
+}
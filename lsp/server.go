@@ -0,0 +1,258 @@
+// Package lsp 通过标准输入输出提供语言服务器协议(LSP)的服务:
+// 修改时报告诊断信息, 跳转到定义, 查找引用, 悬停提示, 文档符号与补全
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/WAY29/LoxGo/framing"
+	"github.com/WAY29/LoxGo/interpreter"
+	"github.com/WAY29/LoxGo/lexer"
+)
+
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	linter    *interpreter.Linter
+	documents map[string]*document
+	shutdown  bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		linter:    interpreter.NewLinter(),
+		documents: make(map[string]*document),
+	}
+}
+
+// 处理消息直到收到exit通知, 没有先收到shutdown请求时返回错误
+func (s *Server) Run() error {
+	for {
+		body, err := framing.Read(s.in)
+		if err == io.EOF {
+			return errors.New("connection closed before exit")
+		} else if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(req.Method, req.Params)
+		// 通知不需要回复
+		if req.ID == nil {
+			continue
+		}
+		if err := s.reply(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, err error) error {
+	if err == nil {
+		return framing.Write(s.out, &response{JSONRPC: "2.0", ID: id, Result: result})
+	}
+	respErr, ok := err.(*responseError)
+	if !ok {
+		respErr = &responseError{Code: codeInvalidRequest, Message: err.Error()}
+	}
+	return framing.Write(s.out, &errorResponse{JSONRPC: "2.0", ID: id, Error: respErr})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return framing.Write(s.out, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, error) {
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "Server is shutting down."}
+	}
+
+	switch method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.update(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text)
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.update(p.TextDocument.URI, p.TextDocument.Version, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.documents, p.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(p)
+	case "textDocument/references":
+		var p ReferenceParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.references(p)
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(p)
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		parsed, err := s.parsed(p.TextDocument.URI)
+		if parsed == nil {
+			return []DocumentSymbol{}, err
+		}
+		return parsed.documentSymbols(), nil
+	case "textDocument/completion":
+		var p TextDocumentPositionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		parsed, err := s.parsed(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return completion(parsed, s.linter.Predefined()), nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("Method not found: %s", method)}
+}
+
+func (s *Server) initialize() *InitializeResult {
+	result := &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       textDocumentSyncFull,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			HoverProvider:          true,
+			DocumentSymbolProvider: true,
+			CompletionProvider:     &CompletionOptions{},
+		},
+	}
+	result.ServerInfo.Name = "LoxGo"
+	return result
+}
+
+// 重新分析文档并发送诊断信息
+func (s *Server) update(uri string, version int, text string) error {
+	doc := newDocument(s.linter, uri, version, text, s.documents[uri])
+	s.documents[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         uri,
+		Version:     version,
+		Diagnostics: doc.diagnostics,
+	})
+}
+
+// 文档最近一次成功解析的结果, 还没有成功解析过时返回nil
+func (s *Server) parsed(uri string) (*parsedDocument, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("Document not open: %s", uri)}
+	}
+	return doc.parsed, nil
+}
+
+func (s *Server) definition(p TextDocumentPositionParams) (interface{}, error) {
+	parsed, err := s.parsed(p.TextDocument.URI)
+	if parsed == nil {
+		return nil, err
+	}
+	symbol, _ := parsed.symbolAt(p.Position)
+	if symbol == nil {
+		return nil, nil
+	}
+	return parsed.location(p.TextDocument.URI, symbol.Name), nil
+}
+
+func (s *Server) references(p ReferenceParams) (interface{}, error) {
+	parsed, err := s.parsed(p.TextDocument.URI)
+	if parsed == nil {
+		return nil, err
+	}
+	symbol, _ := parsed.symbolAt(p.Position)
+	if symbol == nil {
+		return []Location{}, nil
+	}
+	tokens := append([]*lexer.Token{}, symbol.References...)
+	if p.Context.IncludeDeclaration {
+		tokens = append(tokens, symbol.Name)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].GetStart().Offset < tokens[j].GetStart().Offset
+	})
+	locations := make([]Location, len(tokens))
+	for n, token := range tokens {
+		locations[n] = parsed.location(p.TextDocument.URI, token)
+	}
+	return locations, nil
+}
+
+func (s *Server) hover(p TextDocumentPositionParams) (interface{}, error) {
+	parsed, err := s.parsed(p.TextDocument.URI)
+	if parsed == nil {
+		return nil, err
+	}
+	symbol, token := parsed.symbolAt(p.Position)
+	var text string
+	if symbol != nil {
+		text = describe(symbol)
+	} else if token = parsed.identifierAt(p.Position); token != nil {
+		if _, ok := s.linter.Predefined()[token.GetValue()]; !ok {
+			return nil, nil
+		}
+		text = "builtin function " + token.GetValue()
+	} else {
+		return nil, nil
+	}
+	r := tokenRange(parsed.source, token)
+	return &Hover{Contents: MarkupContent{Kind: "plaintext", Value: text}, Range: &r}, nil
+}
@@ -0,0 +1,237 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/WAY29/LoxGo/framing"
+)
+
+// 通过管道与服务器交换JSON-RPC消息的客户端
+type client struct {
+	t           *testing.T
+	in          io.Writer
+	out         *bufio.Reader
+	id          int
+	diagnostics map[string][]Diagnostic
+}
+
+func startServer(t *testing.T) (*client, chan error) {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+	}()
+	return &client{t: t, in: clientOut, out: bufio.NewReader(clientIn), diagnostics: make(map[string][]Diagnostic)}, done
+}
+
+func (c *client) notify(method string, params interface{}) {
+	if err := framing.Write(c.in, &notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// 发送请求并等待回复, 期间收到的诊断信息按文档保存, result为nil时只检查没有错误
+func (c *client) call(method string, params interface{}, result interface{}) {
+	c.id++
+	id := json.RawMessage([]byte(jsonString(c.t, c.id)))
+	if err := framing.Write(c.in, map[string]interface{}{"jsonrpc": "2.0", "id": &id, "method": method, "params": params}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		var msg struct {
+			ID     *json.RawMessage
+			Method string
+			Params json.RawMessage
+			Result json.RawMessage
+			Error  *responseError
+		}
+		c.read(&msg)
+		if msg.ID == nil {
+			c.publish(msg.Method, msg.Params)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("%s: unexpected response id %s", method, *msg.ID)
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%s: %s", method, msg.Error.Message)
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: %v", method, err)
+			}
+		}
+		return
+	}
+}
+
+// 等待下一条诊断信息
+func (c *client) waitDiagnostics(uri string) []Diagnostic {
+	var msg struct {
+		Method string
+		Params json.RawMessage
+	}
+	c.read(&msg)
+	c.publish(msg.Method, msg.Params)
+	diagnostics, ok := c.diagnostics[uri]
+	if !ok {
+		c.t.Fatalf("expected diagnostics of %s", uri)
+	}
+	return diagnostics
+}
+
+func (c *client) read(v interface{}) {
+	body, err := framing.Read(c.out)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) publish(method string, params json.RawMessage) {
+	if method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("unexpected notification %s", method)
+	}
+	var p PublishDiagnosticsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		c.t.Fatal(err)
+	}
+	c.diagnostics[p.URI] = p.Diagnostics
+}
+
+func jsonString(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+const testURI = "file:///test.lox"
+
+const testSource = `var count = 0;
+fun add(a, b = 1) {
+    var e = "😀"; var sum = a + b + len(e);
+    return sum;
+}
+class Point {
+    init(x) {
+        this.x = x;
+    }
+}
+count = add(count);
+print Point(count);
+`
+
+func position(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func TestServer(t *testing.T) {
+	c, done := startServer(t)
+
+	var initialized InitializeResult
+	c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &initialized)
+	if !initialized.Capabilities.DefinitionProvider || initialized.Capabilities.TextDocumentSync != textDocumentSyncFull {
+		t.Fatalf("unexpected capabilities %+v", initialized.Capabilities)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: testURI, Version: 1, Text: testSource}})
+	if diagnostics := c.waitDiagnostics(testURI); len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics but got %+v", diagnostics)
+	}
+
+	// 引用处跳转到声明, 列按UTF-16计数
+	var location Location
+	c.call("textDocument/definition", position(testURI, 3, 12), &location)
+	if location.Range != (Range{Start: Position{2, 22}, End: Position{2, 25}}) {
+		t.Errorf("unexpected definition %+v", location)
+	}
+
+	var references []Location
+	params := ReferenceParams{TextDocumentPositionParams: position(testURI, 0, 5)}
+	params.Context.IncludeDeclaration = true
+	c.call("textDocument/references", params, &references)
+	lines := make([]int, len(references))
+	for n, ref := range references {
+		lines[n] = ref.Range.Start.Line
+	}
+	if jsonString(t, lines) != "[0,10,10,11]" {
+		t.Errorf("unexpected references %+v", references)
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", position(testURI, 10, 9), &hover)
+	if hover.Contents.Value != "function add(a, b = 1)" {
+		t.Errorf("unexpected hover %q", hover.Contents.Value)
+	}
+	c.call("textDocument/hover", position(testURI, 3, 12), &hover)
+	if hover.Contents.Value != "local variable sum" {
+		t.Errorf("unexpected hover %q", hover.Contents.Value)
+	}
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &symbols)
+	if len(symbols) != 3 || symbols[1].Name != "add" || symbols[2].Kind != SymbolKindClass || symbols[2].Children[0].Name != "init" {
+		t.Errorf("unexpected symbols %+v", symbols)
+	}
+
+	var items []CompletionItem
+	c.call("textDocument/completion", position(testURI, 11, 0), &items)
+	labels := make(map[string]CompletionItemKind)
+	for _, item := range items {
+		labels[item.Label] = item.Kind
+	}
+	if labels["Point"] != CompletionKindClass || labels["while"] != CompletionKindKeyword || labels["clock"] != CompletionKindFunction {
+		t.Errorf("unexpected completion %+v", items)
+	}
+
+	// 语法错误时报告错误, 仍使用之前的解析结果
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: testURI, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: testSource + "var = 1;\n"}},
+	})
+	diagnostics := c.waitDiagnostics(testURI)
+	if len(diagnostics) != 1 || diagnostics[0].Severity != SeverityError || diagnostics[0].Range.Start.Line != 12 {
+		t.Errorf("unexpected diagnostics %+v", diagnostics)
+	}
+	c.call("textDocument/hover", position(testURI, 10, 9), &hover)
+	if hover.Contents.Value != "function add(a, b = 1)" {
+		t.Errorf("unexpected hover %q", hover.Contents.Value)
+	}
+
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: testURI, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "fun f(x) {\n    var y;\n    return this;\n}\n"}},
+	})
+	diagnostics = c.waitDiagnostics(testURI)
+	codes := make([]string, len(diagnostics))
+	for n, d := range diagnostics {
+		codes[n] = d.Code
+	}
+	if jsonString(t, codes) != `["unused-parameter","unused-variable","this-outside-method"]` {
+		t.Errorf("unexpected diagnostics %+v", diagnostics)
+	}
+
+	c.notify("textDocument/didClose", &DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}})
+	if diagnostics := c.waitDiagnostics(testURI); len(diagnostics) != 0 {
+		t.Errorf("expected diagnostics to be cleared but got %+v", diagnostics)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
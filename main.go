@@ -1,23 +1,162 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/WAY29/LoxGo/dap"
 	"github.com/WAY29/LoxGo/lox"
+	"github.com/WAY29/LoxGo/lsp"
 )
 
 //go:generate go run ./tools/ast/generator.go ./parser
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			os.Exit(format(os.Args[2:]))
+		case "lint":
+			os.Exit(lint(os.Args[2:]))
+		case "lsp":
+			os.Exit(serve(os.Args[2:]))
+		case "dap":
+			os.Exit(debugAdapter(os.Args[2:]))
+		}
+	}
+
+	useVM := flag.Bool("vm", false, "run on the bytecode virtual machine")
+	debug := flag.Bool("debug", false, "run the script in the interactive debugger")
+	modulePath := flag.String("path", os.Getenv("LOXPATH"), "module search path, separated by "+string(os.PathListSeparator))
+	flag.Usage = func() {
+		fmt.Println("Usage LoxGo [--vm] [--path dirs] [script]")
+		fmt.Println("      LoxGo --debug [--path dirs] script")
+		fmt.Println("      LoxGo fmt [--check] [files or dirs]")
+		fmt.Println("      LoxGo lint [files or dirs]")
+		fmt.Println("      LoxGo lsp")
+		fmt.Println("      LoxGo dap")
+	}
+	flag.Parse()
+
 	x := lox.NewLox()
+	if *modulePath != "" {
+		x.SetModulePath(filepath.SplitList(*modulePath)...)
+	}
+	if *useVM {
+		x.UseVM()
+	}
 
-	argsLen := len(os.Args)
-	if argsLen > 2 {
-		fmt.Println("Usage LoxGo [script]")
+	args := flag.Args()
+	argsLen := len(args)
+	if argsLen > 1 || (*debug && (argsLen == 0 || *useVM)) {
+		// 调试器只支持树遍历解释器
+		flag.Usage()
 		os.Exit(1)
-	} else if argsLen == 2 {
-		x.RunFile(os.Args[1])
+	} else if *debug {
+		x.DebugFile(args[0], os.Stdin)
+	} else if argsLen == 1 {
+		x.RunFile(args[0])
 	} else {
 		x.RunPrompt()
 	}
 }
+
+// 格式化源码文件, 默认格式化当前目录, --check时只列出不符合格式的文件, 存在时返回1
+func format(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "list files whose formatting differs instead of rewriting them")
+	flags.Usage = func() {
+		fmt.Println("Usage LoxGo fmt [--check] [files or dirs]")
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := lox.SourceFiles(paths)
+	if err != nil {
+		fmt.Printf("[ERROR] %s\n", err)
+		return 1
+	}
+
+	status := 0
+	for _, file := range files {
+		changed, err := lox.FormatFile(file, *check)
+		if err != nil {
+			fmt.Printf("[ERROR] %s\n", err)
+			status = 1
+		} else if changed {
+			fmt.Println(file)
+			if *check {
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// 静态检查源码文件, 默认检查当前目录, 发现问题时返回1
+func lint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage LoxGo lint [files or dirs]")
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := lox.SourceFiles(paths)
+	if err != nil {
+		fmt.Printf("[ERROR] %s\n", err)
+		return 1
+	}
+
+	status := 0
+	for _, file := range files {
+		diagnostics, err := lox.LintFile(file)
+		if err != nil {
+			fmt.Printf("[ERROR] %s\n", err)
+			status = 1
+			continue
+		}
+		for _, diagnostic := range diagnostics {
+			fmt.Println(diagnostic)
+			status = 1
+		}
+	}
+	return status
+}
+
+// 通过标准输入输出运行语言服务器, 标准输出只用于协议消息, 错误信息输出到标准错误
+func serve(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage LoxGo lsp")
+	}
+	flags.Parse(args)
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// 通过标准输入输出运行调试适配器, 程序的输出以output事件发送
+func debugAdapter(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage LoxGo dap")
+	}
+	flags.Parse(args)
+
+	if err := dap.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -26,10 +26,23 @@ func (e *ParseError) Error() string {
 	)
 
 	if token.GetType() == lexer.EOF {
-		where = " at end"
+		where = "at end"
 	} else {
 		where = fmt.Sprintf("at '%s'", token.GetValue())
 	}
 
-	return fmt.Sprintf("Parse error in line %d: Error %s: %s", token.GetLine(), where, e.extraMsg)
+	return lexer.FormatError(token.GetSource(), token.GetStart(), token.GetEnd(), fmt.Sprintf("Parse error %s: %s", where, e.extraMsg))
+}
+
+// 不带位置信息的错误内容
+func (e *ParseError) Message() string {
+	return e.extraMsg
+}
+
+func (e *ParseError) GetToken() *lexer.Token {
+	return e.token
+}
+
+func (e *ParseError) GetStart() lexer.Position {
+	return e.token.GetStart()
 }
@@ -6,9 +6,11 @@ import "github.com/WAY29/LoxGo/lexer"
 
 type Expr interface {
 	Accept(v ExprVisitor) (interface{}, error)
+	Span() Span
 }
 
 type Ternary struct {
+	node
 	Condition Expr
 	ThenExpr  Expr
 	ElseExpr  Expr
@@ -22,18 +24,21 @@ func (n *Ternary) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Assign struct {
-	Name  *lexer.Token
-	Value Expr
+	node
+	Name     *lexer.Token
+	Value    Expr
+	Operator *lexer.Token
 }
 
-func NewAssign(name *lexer.Token, value Expr) *Assign {
-	return &Assign{Name: name, Value: value}
+func NewAssign(name *lexer.Token, value Expr, operator *lexer.Token) *Assign {
+	return &Assign{Name: name, Value: value, Operator: operator}
 }
 func (n *Assign) Accept(v ExprVisitor) (interface{}, error) {
 	return v.VisitAssignExpr(n)
 }
 
 type Binary struct {
+	node
 	Left     Expr
 	Operator *lexer.Token
 	Right    Expr
@@ -47,20 +52,21 @@ func (n *Binary) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Call struct {
-	Callee      Expr
-	Paren       *lexer.Token
-	Arguments   []Expr
-	ReturnValue interface{}
+	node
+	Callee    Expr
+	Paren     *lexer.Token
+	Arguments []Expr
 }
 
-func NewCall(callee Expr, paren *lexer.Token, arguments []Expr, returnvalue interface{}) *Call {
-	return &Call{Callee: callee, Paren: paren, Arguments: arguments, ReturnValue: returnvalue}
+func NewCall(callee Expr, paren *lexer.Token, arguments []Expr) *Call {
+	return &Call{Callee: callee, Paren: paren, Arguments: arguments}
 }
 func (n *Call) Accept(v ExprVisitor) (interface{}, error) {
 	return v.VisitCallExpr(n)
 }
 
 type Get struct {
+	node
 	Instance Expr
 	Name     *lexer.Token
 }
@@ -73,6 +79,7 @@ func (n *Get) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Grouping struct {
+	node
 	Expression Expr
 }
 
@@ -84,6 +91,7 @@ func (n *Grouping) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Literal struct {
+	node
 	Value interface{}
 }
 
@@ -95,6 +103,7 @@ func (n *Literal) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Logical struct {
+	node
 	Left     Expr
 	Operator *lexer.Token
 	Right    Expr
@@ -108,19 +117,22 @@ func (n *Logical) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Set struct {
+	node
 	Instance Expr
 	Name     *lexer.Token
 	Value    Expr
+	Operator *lexer.Token
 }
 
-func NewSet(instance Expr, name *lexer.Token, value Expr) *Set {
-	return &Set{Instance: instance, Name: name, Value: value}
+func NewSet(instance Expr, name *lexer.Token, value Expr, operator *lexer.Token) *Set {
+	return &Set{Instance: instance, Name: name, Value: value, Operator: operator}
 }
 func (n *Set) Accept(v ExprVisitor) (interface{}, error) {
 	return v.VisitSetExpr(n)
 }
 
 type This struct {
+	node
 	Keyword *lexer.Token
 }
 
@@ -132,6 +144,7 @@ func (n *This) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Unary struct {
+	node
 	Operator *lexer.Token
 	Right    Expr
 	Prefix   bool
@@ -145,6 +158,7 @@ func (n *Unary) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Variable struct {
+	node
 	Name *lexer.Token
 }
 
@@ -156,6 +170,7 @@ func (n *Variable) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Array struct {
+	node
 	Token    *lexer.Token
 	Elements []Expr
 }
@@ -168,18 +183,51 @@ func (n *Array) Accept(v ExprVisitor) (interface{}, error) {
 }
 
 type Index struct {
-	Name  *lexer.Token
-	Index Expr
+	node
+	Object  Expr
+	Bracket *lexer.Token
+	Index   Expr
 }
 
-func NewIndex(name *lexer.Token, index Expr) *Index {
-	return &Index{Name: name, Index: index}
+func NewIndex(object Expr, bracket *lexer.Token, index Expr) *Index {
+	return &Index{Object: object, Bracket: bracket, Index: index}
 }
 func (n *Index) Accept(v ExprVisitor) (interface{}, error) {
 	return v.VisitIndexExpr(n)
 }
 
+type IndexSet struct {
+	node
+	Object   Expr
+	Bracket  *lexer.Token
+	Index    Expr
+	Value    Expr
+	Operator *lexer.Token
+}
+
+func NewIndexSet(object Expr, bracket *lexer.Token, index Expr, value Expr, operator *lexer.Token) *IndexSet {
+	return &IndexSet{Object: object, Bracket: bracket, Index: index, Value: value, Operator: operator}
+}
+func (n *IndexSet) Accept(v ExprVisitor) (interface{}, error) {
+	return v.VisitIndexSetExpr(n)
+}
+
+type Map struct {
+	node
+	Brace  *lexer.Token
+	Keys   []Expr
+	Values []Expr
+}
+
+func NewMap(brace *lexer.Token, keys []Expr, values []Expr) *Map {
+	return &Map{Brace: brace, Keys: keys, Values: values}
+}
+func (n *Map) Accept(v ExprVisitor) (interface{}, error) {
+	return v.VisitMapExpr(n)
+}
+
 type Lambda struct {
+	node
 	Token    *lexer.Token
 	Function Stmt
 }
@@ -190,3 +238,41 @@ func NewLambda(token *lexer.Token, function Stmt) *Lambda {
 func (n *Lambda) Accept(v ExprVisitor) (interface{}, error) {
 	return v.VisitLambdaExpr(n)
 }
+
+type Super struct {
+	node
+	Keyword *lexer.Token
+	Method  *lexer.Token
+}
+
+func NewSuper(keyword *lexer.Token, method *lexer.Token) *Super {
+	return &Super{Keyword: keyword, Method: method}
+}
+func (n *Super) Accept(v ExprVisitor) (interface{}, error) {
+	return v.VisitSuperExpr(n)
+}
+
+type Stringify struct {
+	node
+	Value Expr
+}
+
+func NewStringify(value Expr) *Stringify {
+	return &Stringify{Value: value}
+}
+func (n *Stringify) Accept(v ExprVisitor) (interface{}, error) {
+	return v.VisitStringifyExpr(n)
+}
+
+type Spread struct {
+	node
+	Token *lexer.Token
+	Value Expr
+}
+
+func NewSpread(token *lexer.Token, value Expr) *Spread {
+	return &Spread{Token: token, Value: value}
+}
+func (n *Spread) Accept(v ExprVisitor) (interface{}, error) {
+	return v.VisitSpreadExpr(n)
+}
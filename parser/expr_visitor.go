@@ -17,5 +17,10 @@ type ExprVisitor interface {
 	VisitVariableExpr(expr *Variable) (interface{}, error)
 	VisitArrayExpr(expr *Array) (interface{}, error)
 	VisitIndexExpr(expr *Index) (interface{}, error)
+	VisitIndexSetExpr(expr *IndexSet) (interface{}, error)
+	VisitMapExpr(expr *Map) (interface{}, error)
 	VisitLambdaExpr(expr *Lambda) (interface{}, error)
+	VisitSuperExpr(expr *Super) (interface{}, error)
+	VisitStringifyExpr(expr *Stringify) (interface{}, error)
+	VisitSpreadExpr(expr *Spread) (interface{}, error)
 }
@@ -0,0 +1,14 @@
+package parser
+
+import (
+	"bytes"
+)
+
+// 将源码格式化为统一的风格: 四个空格缩进, 左大括号不换行, 保留注释与空行(连续的空行合并为一个)
+func Format(name string, source []byte) ([]byte, error) {
+	tokens, statements, err := ParseTokens(name, bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(NewSourcePrinter().WithComments(tokens).PrintProgram(statements)), nil
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	testCases := map[string]string{
+		"var a=1;var b;": "var a = 1;\nvar b;\n",
+		"// header\n\n\n\nvar a; // a\n// before f\nfun f(x){ // open\nif(x)print x;// then\nelse{print 0;\n// end\n}}\n// eof\n": `// header
+
+var a; // a
+
+// before f
+fun f(x) {
+    // open
+    if (x)
+        print x; // then
+    else {
+        print 0;
+        // end
+    }
+}
+// eof
+`,
+		"class A{\n// m\nm(){}\nclass s(){}// s\n}\nwhile(true){// empty\n}": `class A {
+    // m
+    m() {}
+
+    class s() {} // s
+}
+
+while (true) {
+    // empty
+}
+`,
+		"var m = {\n\"k\": 1 // inside\n};": "// inside\nvar m = {\"k\": 1};\n",
+		// 字面量保持原来的写法
+		"print 0xffffffff+0b1010_1010;":           "print 0xffffffff + 0b1010_1010;\n",
+		"var n=1_000_000*123456789.123456789e-3;": "var n = 1_000_000 * 123456789.123456789e-3;\n",
+		"var s=\"\"\"a\n  b ${x}\n\"\"\";":        "var s = \"\"\"a\n  b ${x}\n\"\"\";\n",
+		"var r=`raw\\n\n${x}`;":                   "var r = `raw\\n\n${x}`;\n",
+		"print \"\\u00e9${x}\\t${ y }\";":         "print \"\\u00e9${x}\\t${y}\";\n",
+		// 源码中写出的+不合并到插值字符串中
+		"print \"${x}\"+\"y\";": "print \"${x}\" + \"y\";\n",
+	}
+	for source, expected := range testCases {
+		formatted, err := Format("", []byte(source))
+		if err != nil {
+			t.Fatalf("%q: %v", source, err)
+		}
+		if string(formatted) != expected {
+			t.Errorf("%q: expected\n%s\nbut got\n%s", source, expected, formatted)
+		}
+	}
+
+	if _, err := Format("", []byte("var a = ;")); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+// 格式化的结果再次格式化应当不变
+func TestFormatStable(t *testing.T) {
+	files, err := filepath.Glob("../tests/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		once, err := Format(file, source)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		twice, err := Format(file, once)
+		if err != nil {
+			t.Fatalf("%s (formatted): %v", file, err)
+		}
+		if string(once) != string(twice) {
+			t.Errorf("%s: formatting is not stable\nfirst:\n%s\nsecond:\n%s", file, once, twice)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package parser
+
+import "github.com/WAY29/LoxGo/lexer"
+
+// 节点在源码中的区间[Start, End)
+type Span struct {
+	Start lexer.Position
+	End   lexer.Position
+}
+
+func (s Span) String() string {
+	return s.Start.String() + "-" + s.End.String()
+}
+
+// 是否包含该位置
+func (s Span) Contains(pos lexer.Position) bool {
+	return s.Start.Offset <= pos.Offset && pos.Offset < s.End.Offset
+}
+
+// 所有语法树节点都嵌入node以记录区间
+type node struct {
+	span Span
+}
+
+func (n *node) Span() Span {
+	return n.span
+}
+
+func (n *node) setSpan(span Span) {
+	n.span = span
+}
+
+type spanner interface {
+	setSpan(span Span)
+}
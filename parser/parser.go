@@ -5,58 +5,81 @@ program        → declaration* EOF ;
 declaration    → classDecl
 			   | funDecl
 			   | varDecl
+			   | importDecl
+			   | exportDecl
                | statement ;
-classDecl      → "class" IDENTIFIER "{" function* "}" ;
+importDecl     → "import" STRING ( "as" IDENTIFIER )? ";" ;
+exportDecl     → "export" ( classDecl | funDecl | varDecl ) ;
+classDecl      → "class" IDENTIFIER ( "<" IDENTIFIER )? "{" ( "class"? function )* "}" ;
 funDecl        → "fun" function ;
 function       → IDENTIFIER "(" parameters? ")" block ;
+parameters     → ( param ( "," param )* ( "," "..." IDENTIFIER )? | "..." IDENTIFIER ) ;
+param          → IDENTIFIER ( "=" expression )? ;
 varDecl        → "var" IDENTIFIER ( "=" expression )? ";" ;
-statement      → exprStmt | forStmt | ifStmt | printStmt | returnStmt | whileStmt | block ;
+statement      → exprStmt | forStmt | forInStmt | ifStmt | printStmt | returnStmt | whileStmt
+               | tryStmt | throwStmt | block ;
 forStmt        → "for" "(" ( varDecl | exprStmt | ";" )
                  expression? ";"
                  expression? ")" statement ;
+forInStmt      → "for" "(" "var" IDENTIFIER "in" expression ")" statement ;
 ifStmt         → "if" "(" expression ")" statement
                ( "else" statement )? ;
 whileStmt      → "while" "(" expression ")" statement ;
 exprStmt       → expression";" ;
 printStmt      → "print" expression ";" ;
 returnStmt     → "return" expression? ";"
+tryStmt        → "try" block ( "catch" ( "(" IDENTIFIER ")" )? block )? ( "finally" block )? ;
+throwStmt      → "throw" expression ";" ;
 block          → "{" declaration* "}" ;
 
 expression     → assignment;
-assignment     → ( call "." )? IDENTIFIER "=" assignment
+assignment     → ( call "." IDENTIFIER | call "[" expression "]" | IDENTIFIER )
+				   ( "=" | "+=" | "-=" | "*=" | "/=" | "%=" ) assignment
 				 | ternary ;
 ternary        -> logic_or ("?": ternary ":" ternary)? ;
 logic_or       → logic_and ( "or" logic_and )* ;
 logic_and      → equality ( "and" equality )* ;
 equality       → comparison ( ( "!=" | "==" ) comparison )* ;
-comparison     → term ( ( ">" | ">=" | "<" | "<=" ) term )* ;
+comparison     → bit_or ( ( ">" | ">=" | "<" | "<=" ) bit_or )* ;
+bit_or         → bit_xor ( "|" bit_xor )* ;
+bit_xor        → bit_and ( "^" bit_and )* ;
+bit_and        → shift ( "&" shift )* ;
+shift          → term ( ( "<<" | ">>" ) term )* ;
 term           → factor ( ( "-" | "+" ) factor )* ;
-factor         → unary ( ( "/" | "*" ) unary )* ;
-unary          → ( "!" | "-" ) unary
-               | primary ("++" | "--")? | call;
-call           → primary ( "(" arguments? ")" | "." IDENTIFIER )* ;
-arguments      → expression ( "," expression )* ;
+factor         → unary ( ( "/" | "*" | "%" ) unary )* ;
+unary          → ( "!" | "-" | "~" | "++" | "--" ) unary
+               | exponent ;
+exponent       → postfix ( "**" unary )? ;
+postfix        → call ("++" | "--")? ;
+call           → primary ( "(" arguments? ")" | "." IDENTIFIER | "[" expression "]" )* ;
+arguments      → spread ( "," spread )* ;
+spread         → "..."? expression ;
 primary        → NUMBER | STRING | "true" | "false" | "this" | "nil"
-               | IDENTIFIER ("[" expression "]")? | grouping | array | lambda;
+               | "super" "." IDENTIFIER
+               | IDENTIFIER | grouping | array | map | lambda | interpolation;
+interpolation  → INTERPOLATION expression ( "}" INTERPOLATION expression )* "}" STRING ;
 grouping       → "(" expression ")"
-array          → "[" expression ( "," expression )* "]";
+array          → "[" spread ( "," spread )* "]";
+map            → "{" ( expression ":" expression ( "," expression ":" expression )* )? "}";
 lambda         → "fun" "(" parameters? ")" block;
 */
 package parser
 
 import (
 	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
 
 	"github.com/WAY29/LoxGo/lexer"
 )
 
+// 语法分析内部通过panic(*ParseError)快速退出嵌套调用, 在declaration中恢复, 不会传出Parse
 type Parser struct {
 	tokens    []*lexer.Token
 	tokensLen int
 	current   int
-
-	blockStmt Stmt
-	whileStmt Stmt
+	errs      lexer.ErrorList
 }
 
 func NewParaser(tokens []*lexer.Token) *Parser {
@@ -64,26 +87,6 @@ func NewParaser(tokens []*lexer.Token) *Parser {
 		tokens:    tokens,
 		tokensLen: len(tokens),
 		current:   0,
-		blockStmt: nil,
-		whileStmt: nil,
-	}
-}
-
-func (p *Parser) newBlockStmtState(newStmt Stmt) func() {
-	oldStmt := p.blockStmt
-	p.blockStmt = newStmt
-
-	return func() {
-		p.blockStmt = oldStmt
-	}
-}
-
-func (p *Parser) newWhileStmtState(newStmt Stmt) func() {
-	oldStmt := p.whileStmt
-	p.whileStmt = newStmt
-
-	return func() {
-		p.whileStmt = oldStmt
 	}
 }
 
@@ -119,6 +122,14 @@ func (p *Parser) peek() *lexer.Token {
 	return p.tokens[p.current]
 }
 
+// 向后查看第n个token, 超出范围时返回EOF
+func (p *Parser) peekN(n int) *lexer.Token {
+	if p.current+n >= p.tokensLen {
+		return p.tokens[p.tokensLen-1]
+	}
+	return p.tokens[p.current+n]
+}
+
 func (p *Parser) previous() *lexer.Token {
 	return p.tokens[p.current-1]
 }
@@ -131,6 +142,21 @@ func (p *Parser) consume(tokenType lexer.TokenType, message string) *lexer.Token
 	panic(NewParseError(p.peek(), message))
 }
 
+// 设置节点从start到上一个token结束的区间
+func (p *Parser) finish(start lexer.Position, n spanner) {
+	n.setSpan(Span{Start: start, End: p.previous().GetEnd()})
+}
+
+func (p *Parser) spanExpr(start lexer.Position, expr Expr) Expr {
+	p.finish(start, expr.(spanner))
+	return expr
+}
+
+func (p *Parser) spanStmt(start lexer.Position, stmt Stmt) Stmt {
+	p.finish(start, stmt.(spanner))
+	return stmt
+}
+
 func (p *Parser) synchronize() {
 	p.advance()
 	for !p.isAtEnd() {
@@ -149,6 +175,12 @@ func (p *Parser) synchronize() {
 			fallthrough
 		case lexer.WHILE:
 			fallthrough
+		case lexer.FOR:
+			fallthrough
+		case lexer.TRY:
+			fallthrough
+		case lexer.THROW:
+			fallthrough
 		case lexer.PRINT:
 			fallthrough
 		case lexer.RETURN:
@@ -159,19 +191,52 @@ func (p *Parser) synchronize() {
 	}
 }
 
-func (p *Parser) Parse() (statements []Stmt) {
-	statements = make([]Stmt, 0)
+// 分析全部语句, 出错时返回包含所有语法错误的lexer.ErrorList
+func (p *Parser) Parse() ([]Stmt, error) {
+	statements := make([]Stmt, 0)
 	for !p.isAtEnd() {
 		statements = append(statements, p.declaration())
 	}
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+
+	return statements, nil
+}
+
+// 对源码进行词法与语法分析, 词法错误与语法错误一并报告
+func ParseSource(name string, r io.Reader) ([]Stmt, error) {
+	_, statements, err := ParseTokens(name, r)
+	return statements, err
+}
 
-	return statements
+// 与ParseSource相同, 同时返回带有注释与空行的token
+func ParseTokens(name string, r io.Reader) ([]*lexer.Token, []Stmt, error) {
+	l := lexer.NewNamedLexer(name, r)
+	l.ScanTokens()
+
+	errs := lexer.ErrorList(l.GetErrors())
+	statements, err := NewParaser(l.GetTokens()).Parse()
+	if list, ok := err.(lexer.ErrorList); ok {
+		errs = append(errs, list...)
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	errs.Sort()
+	if err = errs.Err(); err != nil {
+		return nil, nil, err
+	}
+	return l.GetTokens(), statements, nil
 }
 
 func (p *Parser) declaration() (stmt Stmt) {
 	defer func() {
 		if r := recover(); r != nil {
-			if _, ok := r.(ParseError); ok {
+			if err, ok := r.(*ParseError); ok {
+				// 记录错误后同步到下一条语句继续分析, 词法错误处的错误已经报告过
+				if err.token.GetType() != lexer.ILLEGAL {
+					p.errs = append(p.errs, err)
+				}
 				p.synchronize()
 				stmt = nil
 			} else {
@@ -180,10 +245,51 @@ func (p *Parser) declaration() (stmt Stmt) {
 		}
 	}()
 
+	if p.match(lexer.IMPORT) {
+		return p.importDeclaration()
+	} else if p.match(lexer.EXPORT) {
+		return p.exportDeclaration()
+	}
+
+	return p.plainDeclaration()
+}
+
+// 未指定名字时使用文件名(不含扩展名)作为模块名
+func (p *Parser) importDeclaration() Stmt {
+	keyword := p.previous()
+	path := p.consume(lexer.STRING, "Expect module path after 'import'.")
+	name := lexer.NewTokenFrom(path, lexer.IDENTIFIER, moduleName(path.GetLiteral().(string)))
+	// as不是关键字, 以免占用常见的变量名
+	if p.check(lexer.IDENTIFIER) && p.peek().GetValue() == "as" {
+		p.advance()
+		name = p.consume(lexer.IDENTIFIER, "Expect module name after 'as'.")
+	}
+	p.consume(lexer.SEMICOLON, "Expect ';' after import.")
+
+	return p.spanStmt(keyword.GetStart(), NewImport(keyword, path, name))
+}
+
+func moduleName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func (p *Parser) exportDeclaration() Stmt {
+	keyword := p.previous()
+	if !p.check(lexer.CLASS) && !p.check(lexer.FUN) && !p.check(lexer.VAR) {
+		panic(NewParseError(p.peek(), "Expect class, function or variable declaration after 'export'."))
+	}
+	declaration := p.plainDeclaration()
+
+	return p.spanStmt(keyword.GetStart(), NewExport(keyword, declaration))
+}
+
+func (p *Parser) plainDeclaration() Stmt {
 	if p.match(lexer.CLASS) {
 		return p.classDeclaration()
 	} else if p.match(lexer.FUN) {
-		return p.function("function", true)
+		start := p.previous().GetStart()
+		return p.spanStmt(start, p.function("function", true))
 	} else if p.match(lexer.VAR) {
 		return p.varDeclaration()
 	}
@@ -192,17 +298,32 @@ func (p *Parser) declaration() (stmt Stmt) {
 }
 
 func (p *Parser) classDeclaration() (stmt Stmt) {
+	var superclass *Variable = nil
+	start := p.previous().GetStart()
 	name := p.consume(lexer.IDENTIFIER, "Except class name.")
+	if p.match(lexer.LESS) {
+		p.consume(lexer.IDENTIFIER, "Expect superclass name.")
+		superclass = NewVariable(p.previous())
+		p.finish(p.previous().GetStart(), superclass)
+	}
+
 	p.consume(lexer.LEFT_BRACE, "Except '{' before class body.")
 
 	methods := make([]Stmt, 0)
+	classMethods := make([]Stmt, 0)
 	for !p.check(lexer.RIGHT_BRACE) && !p.isAtEnd() {
-		methods = append(methods, p.function("method", true))
+		// 静态方法
+		methodStart := p.peek().GetStart()
+		if p.match(lexer.CLASS) {
+			classMethods = append(classMethods, p.spanStmt(methodStart, p.function("class method", true)))
+		} else {
+			methods = append(methods, p.spanStmt(methodStart, p.function("method", true)))
+		}
 	}
 
 	p.consume(lexer.RIGHT_BRACE, "Except '}' after class body.")
 
-	return NewClass(name, methods)
+	return p.spanStmt(start, NewClass(name, superclass, methods, classMethods))
 }
 
 func (p *Parser) function(kind string, hasName bool) (stmt Stmt) {
@@ -214,14 +335,37 @@ func (p *Parser) function(kind string, hasName bool) (stmt Stmt) {
 	}
 
 	p.consume(lexer.LEFT_PAREN, fmt.Sprintf("Excepted '(' after fun %s.", funcName))
-	parameters := make([]*lexer.Token, 0)
+	var (
+		parameters = make([]*lexer.Token, 0)
+		defaults   = make([]Expr, 0)
+		rest       *lexer.Token
+		hasDefault bool
+	)
 	if !p.check(lexer.RIGHT_PAREN) {
 		for {
 			if len(parameters) >= 255 {
 				panic(NewParseError(p.peek(), "Can't have more than 255 parameters."))
 			}
+			// 剩余参数收集多余的实参, 只能是最后一个参数
+			if p.match(lexer.DOT_DOT_DOT) {
+				rest = p.consume(lexer.IDENTIFIER, "Expect rest parameter name after '...'.")
+				if p.check(lexer.COMMA) {
+					panic(NewParseError(p.peek(), "Rest parameter must be the last parameter."))
+				}
+				break
+			}
 			parameters = append(parameters, p.consume(lexer.IDENTIFIER, "Except parameter name."))
 
+			// 默认值在调用时求值, 有默认值的参数之后不能再有必需参数
+			var defaultValue Expr
+			if p.match(lexer.EQUAL) {
+				defaultValue = p.expression()
+				hasDefault = true
+			} else if hasDefault {
+				panic(NewParseError(p.previous(), "Parameter without default value can't follow one with default value."))
+			}
+			defaults = append(defaults, defaultValue)
+
 			if !p.match(lexer.COMMA) {
 				break
 			}
@@ -230,14 +374,9 @@ func (p *Parser) function(kind string, hasName bool) (stmt Stmt) {
 	}
 	p.consume(lexer.RIGHT_PAREN, "Expect ')' after parameters.")
 	p.consume(lexer.LEFT_BRACE, fmt.Sprintf("Excepted %s body.", kind))
-	body := p.block()
-	// 在类的构造函数末尾添加一个return;
-	if kind == "method" && funcName == "init" {
-		block := body.(*Block)
-		block.Statements = append(block.Statements, NewReturn(nil, nil))
-	}
+	body := p.spanStmt(p.previous().GetStart(), p.block())
 
-	return NewFunction(name, parameters, body)
+	return NewFunction(name, parameters, defaults, rest, body)
 }
 
 func (p *Parser) varDeclaration() Stmt {
@@ -246,6 +385,7 @@ func (p *Parser) varDeclaration() Stmt {
 		initializer  Expr
 		names        = make([]*lexer.Token, 0)
 		initializers = make([]Expr, 0)
+		start        = p.previous().GetStart()
 	)
 	for {
 		name = p.consume(lexer.IDENTIFIER, "Expect variable name.")
@@ -259,10 +399,11 @@ func (p *Parser) varDeclaration() Stmt {
 		}
 	}
 	p.consume(lexer.SEMICOLON, "Expect ';' after variable declaration.")
-	return NewVar(names, initializers)
+	return p.spanStmt(start, NewVar(names, initializers))
 }
 
 func (p *Parser) statement() Stmt {
+	start := p.peek().GetStart()
 	if p.match(lexer.FOR) {
 		return p.forStatement()
 	} else if p.match(lexer.BREAK) {
@@ -277,34 +418,78 @@ func (p *Parser) statement() Stmt {
 		return p.returnStatement()
 	} else if p.match(lexer.WHILE) {
 		return p.whileStatement()
+	} else if p.match(lexer.TRY) {
+		return p.tryStatement()
+	} else if p.match(lexer.THROW) {
+		return p.throwStatement()
 	} else if p.match(lexer.LEFT_BRACE) {
-		return p.block()
+		return p.spanStmt(start, p.block())
 	}
 
 	return p.expressionStatement()
 }
 
 func (p *Parser) continueStatement() Stmt {
+	keyword := p.previous()
 	p.consume(lexer.SEMICOLON, "Expect ';' after continue.")
 
-	return NewContinue(p.blockStmt, p.previous())
+	return p.spanStmt(keyword.GetStart(), NewContinue(keyword))
 }
 
 func (p *Parser) breakStatement() Stmt {
+	keyword := p.previous()
 	p.consume(lexer.SEMICOLON, "Expect ';' after break.")
 
-	return NewBreak(p.whileStmt, p.blockStmt, p.previous())
+	return p.spanStmt(keyword.GetStart(), NewBreak(keyword))
+}
+
+func (p *Parser) tryStatement() Stmt {
+	var (
+		keyword                = p.previous()
+		catchName              *lexer.Token
+		catchBody, finallyBody Stmt
+	)
+
+	p.consume(lexer.LEFT_BRACE, "Expect '{' after 'try'.")
+	body := p.spanStmt(p.previous().GetStart(), p.block())
+	if p.match(lexer.CATCH) {
+		if p.match(lexer.LEFT_PAREN) {
+			catchName = p.consume(lexer.IDENTIFIER, "Expect variable name after 'catch ('.")
+			p.consume(lexer.RIGHT_PAREN, "Expect ')' after catch variable.")
+		}
+		p.consume(lexer.LEFT_BRACE, "Expect '{' after catch.")
+		catchBody = p.spanStmt(p.previous().GetStart(), p.block())
+	}
+	if p.match(lexer.FINALLY) {
+		p.consume(lexer.LEFT_BRACE, "Expect '{' after 'finally'.")
+		finallyBody = p.spanStmt(p.previous().GetStart(), p.block())
+	}
+	if catchBody == nil && finallyBody == nil {
+		panic(NewParseError(p.peek(), "Expect 'catch' or 'finally' after try block."))
+	}
+
+	return p.spanStmt(keyword.GetStart(), NewTry(keyword, body, catchName, catchBody, finallyBody))
+}
+
+func (p *Parser) throwStatement() Stmt {
+	keyword := p.previous()
+	value := p.expression()
+	p.consume(lexer.SEMICOLON, "Expect ';' after thrown value.")
+
+	return p.spanStmt(keyword.GetStart(), NewThrow(keyword, value))
 }
 
 func (p *Parser) forStatement() Stmt {
 	var (
 		initializer, body    Stmt
 		condition, increment Expr
-		while                *While = NewWhile(nil, nil, false, nil)
+		start                = p.previous().GetStart()
 	)
-	defer p.newWhileStmtState(while)()
 
 	p.consume(lexer.LEFT_PAREN, "Expect '(' after 'for'.")
+	if p.check(lexer.VAR) && p.peekN(2).GetType() == lexer.IN {
+		return p.forInStatement(start)
+	}
 	if p.match(lexer.SEMICOLON) {
 		initializer = nil
 	} else if p.match(lexer.VAR) {
@@ -324,27 +509,35 @@ func (p *Parser) forStatement() Stmt {
 	p.consume(lexer.RIGHT_PAREN, "Expect ')' after for clauses.")
 
 	body = p.statement()
-	if increment != nil {
-		body = NewBlock([]Stmt{body, NewExpression(increment)}, false, nil)
-	}
 
 	if condition == nil {
-		condition = NewLiteral(true)
+		condition = p.spanExpr(start, NewLiteral(true))
 	}
-	while.Condition = condition
-	while.Body = body
-	body = while
+	// 自增表达式由while在每次循环(包括continue)后执行
+	body = p.spanStmt(start, NewWhile(condition, body, increment))
 
 	if initializer != nil {
-		body = NewBlock([]Stmt{initializer, body}, false, nil)
+		body = p.spanStmt(start, NewBlock([]Stmt{initializer, body}))
 	}
 
 	return body
 }
 
+func (p *Parser) forInStatement(start lexer.Position) Stmt {
+	p.consume(lexer.VAR, "Expect 'var' in for-in loop.")
+	name := p.consume(lexer.IDENTIFIER, "Expect variable name.")
+	in := p.consume(lexer.IN, "Expect 'in' after variable name.")
+	iterable := p.expression()
+	p.consume(lexer.RIGHT_PAREN, "Expect ')' after for-in clauses.")
+	body := p.statement()
+
+	return p.spanStmt(start, NewForIn(name, in, iterable, body))
+}
+
 func (p *Parser) ifStatement() Stmt {
 	var (
 		thenBranch, elseBranch Stmt
+		start                  = p.previous().GetStart()
 	)
 	p.consume(lexer.LEFT_PAREN, "Expect '(' after 'if'.")
 	condition := p.expression()
@@ -355,13 +548,14 @@ func (p *Parser) ifStatement() Stmt {
 		elseBranch = p.statement()
 	}
 
-	return NewIf(condition, thenBranch, elseBranch)
+	return p.spanStmt(start, NewIf(condition, thenBranch, elseBranch))
 }
 
 func (p *Parser) printStatement() Stmt {
+	start := p.previous().GetStart()
 	expr := p.expression()
 	p.consume(lexer.SEMICOLON, "Expect ';' after value.")
-	return NewPrint(expr)
+	return p.spanStmt(start, NewPrint(expr))
 }
 
 func (p *Parser) returnStatement() Stmt {
@@ -371,55 +565,68 @@ func (p *Parser) returnStatement() Stmt {
 		value = p.expression()
 	}
 	p.consume(lexer.SEMICOLON, "Expect ';' after return value.")
-	return NewReturn(keyword, value)
+	return p.spanStmt(keyword.GetStart(), NewReturn(keyword, value))
 }
 
 func (p *Parser) whileStatement() Stmt {
-	var while *While = NewWhile(nil, nil, false, nil)
-	defer p.newWhileStmtState(while)()
-
+	start := p.previous().GetStart()
 	p.consume(lexer.LEFT_PAREN, "Expect '(' after 'while'.")
-	while.Condition = p.expression()
+	condition := p.expression()
 	p.consume(lexer.RIGHT_PAREN, "Expect ')' after 'while'.")
-	while.Body = p.statement()
-	return while
+	body := p.statement()
+	return p.spanStmt(start, NewWhile(condition, body, nil))
 }
 
 func (p *Parser) expressionStatement() Stmt {
+	start := p.peek().GetStart()
 	expr := p.expression()
 	p.consume(lexer.SEMICOLON, "Expect ';' after value.")
-	return NewExpression(expr)
+	return p.spanStmt(start, NewExpression(expr))
 }
 
 func (p *Parser) block() Stmt {
-	block := NewBlock(nil, false, nil)
 	stmts := make([]Stmt, 0)
-	defer p.newBlockStmtState(block)()
 
 	for !p.isAtEnd() && !p.check(lexer.RIGHT_BRACE) {
 		stmts = append(stmts, p.declaration())
 	}
 	p.consume(lexer.RIGHT_BRACE, "Expect '}' after block.")
 
-	block.Statements = stmts
-	return block
+	return NewBlock(stmts)
 }
 
 func (p *Parser) expression() Expr {
 	return p.assignment()
 }
 
+// 复合赋值运算符对应的二元运算符
+var compoundOperators = map[lexer.TokenType]lexer.TokenType{
+	lexer.PLUS_EQUAL:    lexer.PLUS,
+	lexer.MINUS_EQUAL:   lexer.MINUS,
+	lexer.STAR_EQUAL:    lexer.STAR,
+	lexer.SLASH_EQUAL:   lexer.SLASH,
+	lexer.PERCENT_EQUAL: lexer.PERCENT,
+}
+
 func (p *Parser) assignment() Expr {
 	expr := p.ternary()
 
-	if p.match(lexer.EQUAL) {
+	if p.match(lexer.EQUAL, lexer.PLUS_EQUAL, lexer.MINUS_EQUAL, lexer.STAR_EQUAL, lexer.SLASH_EQUAL, lexer.PERCENT_EQUAL) {
 		equals := p.previous()
 		value := p.assignment()
+		// 复合赋值 a += b 记录二元运算符, 赋值目标只求值一次
+		var operator *lexer.Token
+		if binary, ok := compoundOperators[equals.GetType()]; ok {
+			value := equals.GetValue()
+			operator = lexer.NewTokenFrom(equals, binary, value[:len(value)-1])
+		}
 		if variable, ok := expr.(*Variable); ok {
 			name := variable.Name
-			return NewAssign(name, value)
+			return p.spanExpr(expr.Span().Start, NewAssign(name, value, operator))
 		} else if get, ok := expr.(*Get); ok {
-			return NewSet(get.Instance, get.Name, value)
+			return p.spanExpr(expr.Span().Start, NewSet(get.Instance, get.Name, value, operator))
+		} else if index, ok := expr.(*Index); ok {
+			return p.spanExpr(expr.Span().Start, NewIndexSet(index.Object, index.Bracket, index.Index, value, operator))
 		}
 
 		panic(NewParseError(equals, "Invalid assignment target."))
@@ -435,7 +642,7 @@ func (p *Parser) ternary() Expr {
 		thenExpr := p.ternary()
 		p.consume(lexer.COLON, "Expect ':' after ternary '?'.")
 		elseExpr := p.ternary()
-		expr = NewTernary(expr, thenExpr, elseExpr)
+		expr = p.spanExpr(expr.Span().Start, NewTernary(expr, thenExpr, elseExpr))
 	}
 
 	return expr
@@ -451,7 +658,7 @@ func (p *Parser) or() Expr {
 	for p.match(lexer.OR) {
 		operator = p.previous()
 		right = p.and()
-		expr = NewLogical(expr, operator, right)
+		expr = p.spanExpr(expr.Span().Start, NewLogical(expr, operator, right))
 	}
 
 	return expr
@@ -467,7 +674,7 @@ func (p *Parser) and() Expr {
 	for p.match(lexer.AND) {
 		operator = p.previous()
 		right = p.equality()
-		expr = NewLogical(expr, operator, right)
+		expr = p.spanExpr(expr.Span().Start, NewLogical(expr, operator, right))
 	}
 
 	return expr
@@ -479,17 +686,45 @@ func (p *Parser) equality() Expr {
 	for p.match(lexer.EQUAL_EQUAL, lexer.BANG_EQUAL) {
 		operator := p.previous()
 		right := p.comparison()
-		expr = NewBinary(expr, operator, right)
+		expr = p.spanExpr(expr.Span().Start, NewBinary(expr, operator, right))
 	}
 	return expr
 }
 
 func (p *Parser) comparison() Expr {
-	expr := p.term()
+	expr := p.bitOr()
 	for p.match(lexer.GREATER, lexer.GREATER_EQUAL, lexer.LESS, lexer.LESS_EQUAL) {
 		operator := p.previous()
-		right := p.term()
-		expr = NewBinary(expr, operator, right)
+		right := p.bitOr()
+		expr = p.spanExpr(expr.Span().Start, NewBinary(expr, operator, right))
+	}
+	return expr
+}
+
+// 位运算的优先级高于比较运算, a & 1 == 0 等价于 (a & 1) == 0
+func (p *Parser) bitOr() Expr {
+	return p.leftBinary(p.bitXor, lexer.PIPE)
+}
+
+func (p *Parser) bitXor() Expr {
+	return p.leftBinary(p.bitAnd, lexer.CARET)
+}
+
+func (p *Parser) bitAnd() Expr {
+	return p.leftBinary(p.shift, lexer.AMPERSAND)
+}
+
+func (p *Parser) shift() Expr {
+	return p.leftBinary(p.term, lexer.LESS_LESS, lexer.GREATER_GREATER)
+}
+
+// 解析左结合的二元运算
+func (p *Parser) leftBinary(operand func() Expr, types ...lexer.TokenType) Expr {
+	expr := operand()
+	for p.match(types...) {
+		operator := p.previous()
+		right := operand()
+		expr = p.spanExpr(expr.Span().Start, NewBinary(expr, operator, right))
 	}
 	return expr
 }
@@ -500,30 +735,61 @@ func (p *Parser) term() Expr {
 	for p.match(lexer.MINUS, lexer.PLUS) {
 		operator := p.previous()
 		right := p.factor()
-		expr = NewBinary(expr, operator, right)
+		expr = p.spanExpr(expr.Span().Start, NewBinary(expr, operator, right))
 	}
 	return expr
 }
 
 func (p *Parser) factor() Expr {
 	expr := p.unary()
-	for p.match(lexer.SLASH, lexer.STAR) {
+	for p.match(lexer.SLASH, lexer.STAR, lexer.PERCENT) {
 		operator := p.previous()
 		right := p.unary()
-		expr = NewBinary(expr, operator, right)
+		expr = p.spanExpr(expr.Span().Start, NewBinary(expr, operator, right))
 	}
 	return expr
 }
 
 func (p *Parser) unary() Expr {
-	if p.match(lexer.BANG, lexer.MINUS, lexer.PLUSPLUS, lexer.MINUSMINUS) {
-		return NewUnary(p.previous(), p.unary(), true)
-	} else {
-		if p.match(lexer.PLUSPLUS, lexer.MINUSMINUS) {
-			return NewUnary(p.previous(), p.unary(), false)
-		}
-		return p.call()
+	if p.match(lexer.BANG, lexer.MINUS, lexer.TILDE, lexer.PLUSPLUS, lexer.MINUSMINUS) {
+		operator := p.previous()
+		right := p.unary()
+		checkIncrementTarget(operator, right)
+		return p.spanExpr(operator.GetStart(), NewUnary(operator, right, true))
+	}
+	return p.exponent()
+}
+
+// 自增自减的目标只能是变量, 属性或下标
+func checkIncrementTarget(operator *lexer.Token, target Expr) {
+	if operator.GetType() != lexer.PLUSPLUS && operator.GetType() != lexer.MINUSMINUS {
+		return
+	}
+	switch target.(type) {
+	case *Variable, *Get, *Index:
+		return
 	}
+	panic(NewParseError(operator, "Invalid increment target."))
+}
+
+// 乘方是右结合的, 且优先级高于一元运算: -2 ** 2 == -4, 2 ** 3 ** 2 == 512
+func (p *Parser) exponent() Expr {
+	expr := p.postfix()
+	if p.match(lexer.STAR_STAR) {
+		operator := p.previous()
+		right := p.unary()
+		expr = p.spanExpr(expr.Span().Start, NewBinary(expr, operator, right))
+	}
+	return expr
+}
+
+func (p *Parser) postfix() Expr {
+	expr := p.call()
+	if p.match(lexer.PLUSPLUS, lexer.MINUSMINUS) {
+		checkIncrementTarget(p.previous(), expr)
+		return p.spanExpr(expr.Span().Start, NewUnary(p.previous(), expr, false))
+	}
+	return expr
 }
 
 func (p *Parser) call() Expr {
@@ -533,7 +799,12 @@ func (p *Parser) call() Expr {
 			expr = p.finishCall(expr)
 		} else if p.match(lexer.DOT) {
 			name := p.consume(lexer.IDENTIFIER, "Expect property name after '.'.")
-			expr = NewGet(expr, name)
+			expr = p.spanExpr(expr.Span().Start, NewGet(expr, name))
+		} else if p.match(lexer.LEFT_BRACKET) {
+			bracket := p.previous()
+			index := p.expression()
+			p.consume(lexer.RIGHT_BRACKET, "Expect ']' after index.")
+			expr = p.spanExpr(expr.Span().Start, NewIndex(expr, bracket, index))
 		} else {
 			break
 		}
@@ -548,56 +819,109 @@ func (p *Parser) finishCall(callee Expr) Expr {
 			if len(arguments) >= 255 {
 				panic(NewParseError(p.peek(), "Function can't have more than 255 arguments."))
 			}
-			arguments = append(arguments, p.expression())
+			arguments = append(arguments, p.spreadOrExpression())
 			if !p.match(lexer.COMMA) {
 				break
 			}
 		}
 	}
 	paren := p.consume(lexer.RIGHT_PAREN, "Expect ')' after arguments.")
-	return NewCall(callee, paren, arguments, nil)
+	return p.spanExpr(callee.Span().Start, NewCall(callee, paren, arguments))
+}
+
+// 实参与数组元素可以使用 ...arr 展开数组
+func (p *Parser) spreadOrExpression() Expr {
+	if p.match(lexer.DOT_DOT_DOT) {
+		token := p.previous()
+		return p.spanExpr(token.GetStart(), NewSpread(token, p.expression()))
+	}
+	return p.expression()
 }
 
 func (p *Parser) primary() Expr {
+	start := p.peek().GetStart()
 	if p.match(lexer.FALSE) {
-		return NewLiteral(false)
+		return p.spanExpr(start, NewLiteral(false))
 	} else if p.match(lexer.TRUE) {
-		return NewLiteral(true)
+		return p.spanExpr(start, NewLiteral(true))
 	} else if p.match(lexer.NIL) {
-		return NewLiteral(nil)
+		return p.spanExpr(start, NewLiteral(nil))
 	} else if p.match(lexer.THIS) {
-		return NewThis(p.previous())
+		return p.spanExpr(start, NewThis(p.previous()))
+	} else if p.match(lexer.SUPER) {
+		keyword := p.previous()
+		p.consume(lexer.DOT, "Expect '.' after 'super'.")
+		method := p.consume(lexer.IDENTIFIER, "Expect superclass method name.")
+		return p.spanExpr(start, NewSuper(keyword, method))
 	} else if p.match(lexer.NUMBER, lexer.STRING) {
-		return NewLiteral(p.previous().GetLiteral())
+		return p.spanExpr(start, NewLiteral(p.previous().GetLiteral()))
+	} else if p.match(lexer.INTERPOLATION) {
+		return p.interpolation()
 	} else if p.match(lexer.IDENTIFIER) {
-		token := p.previous()
-		if p.match(lexer.LEFT_BRACKET) {
-			index := p.expression()
-			p.consume(lexer.RIGHT_BRACKET, "Expect ']' after index.")
-			return NewIndex(token, index)
-		} else {
-			return NewVariable(token)
-		}
+		return p.spanExpr(start, NewVariable(p.previous()))
 	} else if p.match(lexer.LEFT_PAREN) {
 		expr := p.expression()
 		p.consume(lexer.RIGHT_PAREN, "Expect ')' after expression.")
-		return NewGrouping(expr)
+		return p.spanExpr(start, NewGrouping(expr))
 	} else if p.match(lexer.LEFT_BRACKET) {
 		elements := make([]Expr, 0)
 		if !p.check(lexer.RIGHT_BRACKET) {
 			for {
-				elements = append(elements, p.expression())
+				elements = append(elements, p.spreadOrExpression())
 				if !p.match(lexer.COMMA) {
 					break
 				}
 			}
 		}
 		p.consume(lexer.RIGHT_BRACKET, "Except ']' after array.")
-		return NewArray(p.previous(), elements)
+		return p.spanExpr(start, NewArray(p.previous(), elements))
+	} else if p.match(lexer.LEFT_BRACE) {
+		// 语句开头的'{'总是解析为代码块, 只有在表达式中才会解析为字典
+		brace := p.previous()
+		keys, values := make([]Expr, 0), make([]Expr, 0)
+		if !p.check(lexer.RIGHT_BRACE) {
+			for {
+				keys = append(keys, p.expression())
+				p.consume(lexer.COLON, "Expect ':' after map key.")
+				values = append(values, p.expression())
+				if !p.match(lexer.COMMA) {
+					break
+				}
+			}
+		}
+		p.consume(lexer.RIGHT_BRACE, "Expect '}' after map.")
+		return p.spanExpr(start, NewMap(brace, keys, values))
 	} else if p.match(lexer.FUN) {
-		function := p.function("function", false)
-		return NewLambda(p.previous(), function)
+		function := p.spanStmt(start, p.function("function", false))
+		return p.spanExpr(start, NewLambda(p.previous(), function))
 	}
 
 	panic(NewParseError(p.peek(), "Except expression."))
 }
+
+// 插值字符串解析为字符串拼接, "a${b}c" 等价于 "a" + string(b) + "c"
+func (p *Parser) interpolation() Expr {
+	start := p.previous().GetStart()
+	expr := p.spanExpr(start, NewLiteral(p.previous().GetLiteral()))
+	for {
+		plus := lexer.NewTokenFrom(p.previous(), lexer.PLUS, "+")
+		valueStart := p.peek().GetStart()
+		value := p.spanExpr(valueStart, NewStringify(p.expression()))
+		expr = p.spanExpr(start, NewBinary(expr, plus, value))
+
+		p.consume(lexer.RIGHT_BRACE, "Expect '}' after interpolation expression.")
+		// '}'之后总是紧跟字符串的剩余部分, 除非字符串本身有词法错误
+		if !p.check(lexer.INTERPOLATION) && !p.check(lexer.STRING) {
+			panic(NewParseError(p.peek(), "Unterminated string interpolation."))
+		}
+		segment := p.advance()
+		if segment.GetLiteral() != "" {
+			literal := p.spanExpr(segment.GetStart(), NewLiteral(segment.GetLiteral()))
+			plus = lexer.NewTokenFrom(segment, lexer.PLUS, "+")
+			expr = p.spanExpr(start, NewBinary(expr, plus, literal))
+		}
+		if segment.GetType() == lexer.STRING {
+			return expr
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/WAY29/LoxGo/lexer"
+)
+
+func TestParseReportsAllErrors(t *testing.T) {
+	source := "var a = ;\nprint 1\nvar b = 2;\nfun f( { }\nprint 5++;\nvar c = @;\nvar d = 1 # 2;\n"
+	l := lexer.NewLexer(strings.NewReader(source))
+	l.ScanTokens()
+
+	// 词法错误由词法分析器报告, 语法分析不应再报告
+	if got := len(l.GetErrors()); got != 2 {
+		t.Fatalf("expected 2 lexer errors, but got %d", got)
+	}
+
+	_, err := NewParaser(l.GetTokens()).Parse()
+	errs, ok := err.(lexer.ErrorList)
+	if !ok {
+		t.Fatalf("expected lexer.ErrorList, but got %T", err)
+	}
+
+	lines := []int{1, 3, 4, 5}
+	if len(errs) != len(lines) {
+		t.Fatalf("expected %d errors, but got %d:\n%s", len(lines), len(errs), err)
+	}
+	for n, line := range lines {
+		if got := errs[n].(*ParseError).GetToken().GetLine(); got != line {
+			t.Errorf("error %d: expected line %d, but got %d", n, line, got)
+		}
+	}
+}
@@ -1,54 +1,351 @@
-// package parser
-
-// import (
-// 	"bytes"
-// 	"fmt"
-// )
-
-// type Printer struct{} // impl ExprVisitor
-
-// func NewPrinter() *Printer {
-// 	return new(Printer)
-// }
-
-// func (p *Printer) parenthesize(name string, exprs ...Expr) string {
-// 	var buffer bytes.Buffer
-
-// 	buffer.WriteString("(" + name)
-// 	for _, expr := range exprs {
-// 		buffer.WriteString(" ")
-// 		output, _ := expr.Accept(p)
-// 		buffer.WriteString(output.(string))
-// 	}
-// 	buffer.WriteString(")")
-
-// 	return buffer.String()
-// }
-
-// func (p *Printer) Print(expr Expr) string {
-// 	if expr == nil {
-// 		return ""
-// 	}
-// 	output, _ := expr.Accept(p)
-// 	return output.(string)
-// }
-
-// func (p *Printer) VisitUnaryExpr(expr *Unary) (interface{}, error) {
-// 	return p.parenthesize(expr.Operator.GetValue(), expr.Right), nil
-// }
-
-// func (p *Printer) VisitBinaryExpr(expr *Binary) (interface{}, error) {
-// 	return p.parenthesize(expr.Operator.GetValue(), expr.Left, expr.Right), nil
-// }
-
-// func (p *Printer) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
-// 	return p.parenthesize("group", expr.Expression), nil
-// }
-
-// func (p *Printer) VisitLiteralExpr(expr *Literal) (interface{}, error) {
-// 	if expr.Value == nil {
-// 		return "nil", nil
-// 	}
-// 	return fmt.Sprintf("%v", expr.Value), nil
-// }
 package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/WAY29/LoxGo/decimal"
+	"github.com/WAY29/LoxGo/lexer"
+)
+
+// 以S表达式形式输出语法树, 用于调试语法分析器与比较语法树
+type Printer struct{} // impl ExprVisitor, StmtVisitor
+
+func NewPrinter() *Printer {
+	return new(Printer)
+}
+
+func (p *Printer) Print(expr Expr) string {
+	if expr == nil {
+		return "nil"
+	}
+	output, _ := expr.Accept(p)
+	return output.(string)
+}
+
+func (p *Printer) PrintStmt(stmt Stmt) string {
+	if stmt == nil {
+		return "nil"
+	}
+	output, _ := stmt.Accept(p)
+	return output.(string)
+}
+
+// 每条语句输出一行
+func (p *Printer) PrintProgram(statements []Stmt) string {
+	var builder strings.Builder
+	for _, stmt := range statements {
+		builder.WriteString(p.PrintStmt(stmt))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+func (p *Printer) parenthesize(name string, parts ...interface{}) string {
+	var builder strings.Builder
+
+	builder.WriteString("(" + name)
+	for _, part := range parts {
+		builder.WriteString(" ")
+		switch part := part.(type) {
+		case Expr:
+			builder.WriteString(p.Print(part))
+		case Stmt:
+			builder.WriteString(p.PrintStmt(part))
+		case []Expr:
+			builder.WriteString(p.list(part))
+		case []Stmt:
+			for n, stmt := range part {
+				if n > 0 {
+					builder.WriteString(" ")
+				}
+				builder.WriteString(p.PrintStmt(stmt))
+			}
+		case *lexer.Token:
+			builder.WriteString(part.GetValue())
+		default:
+			builder.WriteString(fmt.Sprint(part))
+		}
+	}
+	builder.WriteString(")")
+
+	return builder.String()
+}
+
+func (p *Printer) list(exprs []Expr) string {
+	parts := make([]string, len(exprs))
+	for n, expr := range exprs {
+		parts[n] = p.Print(expr)
+	}
+	return strings.Join(parts, " ")
+}
+
+// 复合赋值输出为 +=, 普通赋值输出为 =
+func assignOperator(operator *lexer.Token) string {
+	if operator == nil {
+		return "="
+	}
+	return operator.GetValue() + "="
+}
+
+// 输出能够区分类型的字面量, 浮点数总是带有小数点或指数
+func formatLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return quoteString(v)
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	case *big.Int:
+		return v.String() + "n"
+	case *decimal.Decimal:
+		return v.String() + "d"
+	}
+	return fmt.Sprint(value)
+}
+
+// 输出能被词法分析器重新读取的字符串字面量
+func quoteString(s string) string {
+	return `"` + escapeString(s) + `"`
+}
+
+func escapeString(s string) string {
+	var builder strings.Builder
+	for _, r := range s {
+		switch r {
+		case '"', '\\', '$':
+			builder.WriteRune('\\')
+			builder.WriteRune(r)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\t':
+			builder.WriteString(`\t`)
+		case '\r':
+			builder.WriteString(`\r`)
+		case 0:
+			builder.WriteString(`\0`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&builder, `\x%02x`, r)
+			} else {
+				builder.WriteRune(r)
+			}
+		}
+	}
+	return builder.String()
+}
+
+func (p *Printer) function(kind string, stmt *Function) string {
+	params := make([]string, 0, len(stmt.Params)+1)
+	for n, param := range stmt.Params {
+		if stmt.Defaults[n] != nil {
+			params = append(params, p.parenthesize("=", param, stmt.Defaults[n]))
+		} else {
+			params = append(params, param.GetValue())
+		}
+	}
+	if stmt.Rest != nil {
+		params = append(params, p.parenthesize("...", stmt.Rest))
+	}
+	parts := []interface{}{"(" + strings.Join(params, " ") + ")", stmt.Body}
+	if stmt.Name != nil {
+		parts = append([]interface{}{stmt.Name}, parts...)
+	}
+	return p.parenthesize(kind, parts...)
+}
+
+func (p *Printer) VisitTernaryExpr(expr *Ternary) (interface{}, error) {
+	return p.parenthesize("?:", expr.Condition, expr.ThenExpr, expr.ElseExpr), nil
+}
+
+func (p *Printer) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return p.parenthesize(assignOperator(expr.Operator), expr.Name, expr.Value), nil
+}
+
+func (p *Printer) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	return p.parenthesize(expr.Operator.GetValue(), expr.Left, expr.Right), nil
+}
+
+func (p *Printer) VisitCallExpr(expr *Call) (interface{}, error) {
+	return p.parenthesize("call", expr.Callee, expr.Arguments), nil
+}
+
+func (p *Printer) VisitGetExpr(expr *Get) (interface{}, error) {
+	return p.parenthesize(".", expr.Instance, expr.Name), nil
+}
+
+func (p *Printer) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	return p.parenthesize("group", expr.Expression), nil
+}
+
+func (p *Printer) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	return formatLiteral(expr.Value), nil
+}
+
+func (p *Printer) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	return p.parenthesize(expr.Operator.GetValue(), expr.Left, expr.Right), nil
+}
+
+func (p *Printer) VisitSetExpr(expr *Set) (interface{}, error) {
+	target := p.parenthesize(".", expr.Instance, expr.Name)
+	return p.parenthesize(assignOperator(expr.Operator), target, expr.Value), nil
+}
+
+func (p *Printer) VisitThisExpr(expr *This) (interface{}, error) {
+	return "this", nil
+}
+
+func (p *Printer) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	if !expr.Prefix {
+		return p.parenthesize("postfix", expr.Operator, expr.Right), nil
+	}
+	return p.parenthesize(expr.Operator.GetValue(), expr.Right), nil
+}
+
+func (p *Printer) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return expr.Name.GetValue(), nil
+}
+
+func (p *Printer) VisitArrayExpr(expr *Array) (interface{}, error) {
+	return p.parenthesize("array", expr.Elements), nil
+}
+
+func (p *Printer) VisitIndexExpr(expr *Index) (interface{}, error) {
+	return p.parenthesize("index", expr.Object, expr.Index), nil
+}
+
+func (p *Printer) VisitIndexSetExpr(expr *IndexSet) (interface{}, error) {
+	target := p.parenthesize("index", expr.Object, expr.Index)
+	return p.parenthesize(assignOperator(expr.Operator), target, expr.Value), nil
+}
+
+func (p *Printer) VisitMapExpr(expr *Map) (interface{}, error) {
+	parts := make([]interface{}, len(expr.Keys))
+	for n := range expr.Keys {
+		parts[n] = p.parenthesize(p.Print(expr.Keys[n]), expr.Values[n])
+	}
+	return p.parenthesize("map", parts...), nil
+}
+
+func (p *Printer) VisitLambdaExpr(expr *Lambda) (interface{}, error) {
+	return p.function("lambda", expr.Function.(*Function)), nil
+}
+
+func (p *Printer) VisitSuperExpr(expr *Super) (interface{}, error) {
+	return p.parenthesize("super", expr.Method), nil
+}
+
+func (p *Printer) VisitStringifyExpr(expr *Stringify) (interface{}, error) {
+	return p.parenthesize("str", expr.Value), nil
+}
+
+func (p *Printer) VisitSpreadExpr(expr *Spread) (interface{}, error) {
+	return p.parenthesize("...", expr.Value), nil
+}
+
+func (p *Printer) VisitBlockStmt(stmt *Block) (interface{}, error) {
+	return p.parenthesize("block", stmt.Statements), nil
+}
+
+func (p *Printer) VisitClassStmt(stmt *Class) (interface{}, error) {
+	parts := []interface{}{stmt.Name}
+	if stmt.Superclass != nil {
+		parts = append(parts, p.parenthesize("<", stmt.Superclass))
+	}
+	parts = append(parts, stmt.Methods)
+	if len(stmt.ClassMethods) > 0 {
+		parts = append(parts, p.parenthesize("class", stmt.ClassMethods))
+	}
+	return p.parenthesize("class", parts...), nil
+}
+
+func (p *Printer) VisitExpressionStmt(stmt *Expression) (interface{}, error) {
+	return p.parenthesize("expr", stmt.Expr), nil
+}
+
+func (p *Printer) VisitFunctionStmt(stmt *Function) (interface{}, error) {
+	return p.function("fun", stmt), nil
+}
+
+func (p *Printer) VisitIfStmt(stmt *If) (interface{}, error) {
+	if stmt.ElseBranch == nil {
+		return p.parenthesize("if", stmt.Condition, stmt.ThenBranch), nil
+	}
+	return p.parenthesize("if", stmt.Condition, stmt.ThenBranch, stmt.ElseBranch), nil
+}
+
+func (p *Printer) VisitPrintStmt(stmt *Print) (interface{}, error) {
+	return p.parenthesize("print", stmt.Expr), nil
+}
+
+func (p *Printer) VisitReturnStmt(stmt *Return) (interface{}, error) {
+	if stmt.Value == nil {
+		return "(return)", nil
+	}
+	return p.parenthesize("return", stmt.Value), nil
+}
+
+func (p *Printer) VisitVarStmt(stmt *Var) (interface{}, error) {
+	parts := make([]interface{}, len(stmt.Names))
+	for n, name := range stmt.Names {
+		if stmt.Initializers[n] == nil {
+			parts[n] = "(" + name.GetValue() + ")"
+		} else {
+			parts[n] = p.parenthesize(name.GetValue(), stmt.Initializers[n])
+		}
+	}
+	return p.parenthesize("var", parts...), nil
+}
+
+func (p *Printer) VisitWhileStmt(stmt *While) (interface{}, error) {
+	if stmt.Increment == nil {
+		return p.parenthesize("while", stmt.Condition, stmt.Body), nil
+	}
+	return p.parenthesize("while", stmt.Condition, stmt.Body, stmt.Increment), nil
+}
+
+func (p *Printer) VisitForInStmt(stmt *ForIn) (interface{}, error) {
+	return p.parenthesize("for-in", stmt.Name, stmt.Iterable, stmt.Body), nil
+}
+
+func (p *Printer) VisitBreakStmt(stmt *Break) (interface{}, error) {
+	return "(break)", nil
+}
+
+func (p *Printer) VisitContinueStmt(stmt *Continue) (interface{}, error) {
+	return "(continue)", nil
+}
+
+func (p *Printer) VisitTryStmt(stmt *Try) (interface{}, error) {
+	parts := []interface{}{stmt.Body}
+	if stmt.CatchBody != nil {
+		if stmt.CatchName != nil {
+			parts = append(parts, p.parenthesize("catch", stmt.CatchName, stmt.CatchBody))
+		} else {
+			parts = append(parts, p.parenthesize("catch", stmt.CatchBody))
+		}
+	}
+	if stmt.FinallyBody != nil {
+		parts = append(parts, p.parenthesize("finally", stmt.FinallyBody))
+	}
+	return p.parenthesize("try", parts...), nil
+}
+
+func (p *Printer) VisitThrowStmt(stmt *Throw) (interface{}, error) {
+	return p.parenthesize("throw", stmt.Value), nil
+}
+
+func (p *Printer) VisitImportStmt(stmt *Import) (interface{}, error) {
+	return p.parenthesize("import", quoteString(stmt.Path.GetLiteral().(string)), stmt.Name), nil
+}
+
+func (p *Printer) VisitExportStmt(stmt *Export) (interface{}, error) {
+	return p.parenthesize("export", stmt.Declaration), nil
+}
@@ -1,35 +1,146 @@
-// package parser
-
-// import (
-// 	"testing"
-
-// 	"github.com/WAY29/LoxGo/lexer"
-// )
-
-// func TestPrinter(t *testing.T) {
-// 	printer := new(Printer)
-
-// 	testCases := map[string]Expr{
-// 		"(+ 1 2)": NewBinary(
-// 			NewLiteral(1),
-// 			lexer.NewToken(lexer.PLUS, "+", nil, 1),
-// 			NewLiteral(2),
-// 		),
-// 		"(* (- 123) (group 45.67))": NewBinary(
-// 			NewUnary(
-// 				lexer.NewToken(lexer.MINUS, "-", nil, 1),
-// 				NewLiteral(123),
-// 			),
-// 			lexer.NewToken(lexer.STAR, "*", nil, 1),
-// 			NewGrouping(NewLiteral(45.67)),
-// 		),
-// 	}
-
-// 	for expected, expr := range testCases {
-// 		if result := printer.Print(expr); result != expected {
-// 			t.Fatalf("expected %s, but got %s", expected, result)
-// 		}
-// 	}
-
-// }
 package parser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/WAY29/LoxGo/lexer"
+)
+
+func parseSource(t *testing.T, name, source string) []Stmt {
+	t.Helper()
+	l := lexer.NewLexer(strings.NewReader(source))
+	l.ScanTokens()
+	if err := l.GetError(); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	statements, err := NewParaser(l.GetTokens()).Parse()
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", name, err, source)
+	}
+	return statements
+}
+
+func TestPrinter(t *testing.T) {
+	printer := NewPrinter()
+
+	testCases := map[string]Expr{
+		"(+ 1 2)": NewBinary(
+			NewLiteral(1),
+			lexer.NewToken(lexer.PLUS, "+", nil, 1),
+			NewLiteral(2),
+		),
+		"(* (- 123) (group 45.67))": NewBinary(
+			NewUnary(
+				lexer.NewToken(lexer.MINUS, "-", nil, 1),
+				NewLiteral(123),
+				true,
+			),
+			lexer.NewToken(lexer.STAR, "*", nil, 1),
+			NewGrouping(NewLiteral(45.67)),
+		),
+		`(call f 1.0 "a\n")`: NewCall(
+			NewVariable(lexer.NewToken(lexer.IDENTIFIER, "f", nil, 1)),
+			lexer.NewToken(lexer.RIGHT_PAREN, ")", nil, 1),
+			[]Expr{NewLiteral(1.0), NewLiteral("a\n")},
+		),
+	}
+
+	for expected, expr := range testCases {
+		if result := printer.Print(expr); result != expected {
+			t.Errorf("expected %s, but got %s", expected, result)
+		}
+	}
+}
+
+func TestSourcePrinterParentheses(t *testing.T) {
+	token := func(_type lexer.TokenType, value string) *lexer.Token {
+		return lexer.NewToken(_type, value, nil, 1)
+	}
+	printer := NewSourcePrinter()
+
+	testCases := map[string]Expr{
+		"(1 + 2) * 3": NewBinary(
+			NewBinary(NewLiteral(1), token(lexer.PLUS, "+"), NewLiteral(2)),
+			token(lexer.STAR, "*"),
+			NewLiteral(3),
+		),
+		"1 - (2 - 3)": NewBinary(
+			NewLiteral(1),
+			token(lexer.MINUS, "-"),
+			NewBinary(NewLiteral(2), token(lexer.MINUS, "-"), NewLiteral(3)),
+		),
+		"(2 ** 3) ** 2": NewBinary(
+			NewBinary(NewLiteral(2), token(lexer.STAR_STAR, "**"), NewLiteral(3)),
+			token(lexer.STAR_STAR, "**"),
+			NewLiteral(2),
+		),
+		"- -1":   NewUnary(token(lexer.MINUS, "-"), NewUnary(token(lexer.MINUS, "-"), NewLiteral(1), true), true),
+		`"${a}"`: NewStringify(NewVariable(token(lexer.IDENTIFIER, "a"))),
+		"(a = 1).b": NewGet(
+			NewAssign(token(lexer.IDENTIFIER, "a"), NewLiteral(1), nil),
+			token(lexer.IDENTIFIER, "b"),
+		),
+	}
+
+	for expected, expr := range testCases {
+		if result := printer.Print(expr); result != expected {
+			t.Errorf("expected %s, but got %s", expected, result)
+		}
+	}
+}
+
+// 解析 -> 输出源码 -> 再次解析, 两次得到的语法树应当相同, 再次输出的源码也应当相同
+func checkRoundTrip(t *testing.T, name, source string) {
+	t.Helper()
+	statements := parseSource(t, name, source)
+	expected := NewPrinter().PrintProgram(statements)
+
+	printed := NewSourcePrinter().PrintProgram(statements)
+	reparsed := parseSource(t, name+" (printed)", printed)
+	if got := NewPrinter().PrintProgram(reparsed); got != expected {
+		t.Errorf("%s: syntax tree changed after printing\nprinted:\n%s\nexpected:\n%s\ngot:\n%s", name, printed, expected, got)
+		return
+	}
+	if again := NewSourcePrinter().PrintProgram(reparsed); again != printed {
+		t.Errorf("%s: printing is not stable\nfirst:\n%s\nsecond:\n%s", name, printed, again)
+	}
+}
+
+func TestSourcePrinterRoundTrip(t *testing.T) {
+	testCases := []string{
+		`var a = 1, b, c = "x\t\"y\" \$";`,
+		`print -(-1) - -a ** 2 ** -b;`,
+		`print (1 + 2) * 3 % 4 / 5 << 1 >> 2 & 3 | 4 ^ ~5;`,
+		`print a and b or !c ? (d = 1) : e ? f : g;`,
+		`a.b[c](1, ...d).e = f[0] += 2; x++; --y; z.w -= 1;`,
+		`print "a${b + "c${d}"}e${f}" + "${g}";`,
+		`print [1, 1.0, 1e30, 0.5, 10n, 2.50d, 9223372036854775808, nil, true, {"k": [], 1: {}}];`,
+		`fun f(a, b = a * 2, ...rest) { return; } print fun (x) { return x; }(1);`,
+		`for (var i = 0; i < 10; i = i + 1) print i; for (;;) break; for (i = 0; i < 1;) {} for (; i; i--) continue;`,
+		`for (var x in [1, 2]) { if (x) print x; else if (!x) { print 0; } else print 1; }`,
+		`if (a) { if (b) print 1; } else print 2; while (true) { }`,
+		`try { throw "e"; } catch (e) { print e; } finally { print 1; } try {} catch {}`,
+		`class A < B { class make() { return A(); } init(x = 0) { super.init(); this.x = x; } }`,
+		`import "lib/util.lox"; import "shapes" as s; export var v = 1; export fun g() {} export class C {}`,
+		`({"a": 1}); (fun () {})(); funny(1);`,
+	}
+	for n, source := range testCases {
+		checkRoundTrip(t, "case "+string(rune('A'+n)), source)
+	}
+
+	files, err := filepath.Glob("../tests/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	more, _ := filepath.Glob("../tests/modules/*.lox")
+	for _, file := range append(files, more...) {
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRoundTrip(t, file, string(source))
+	}
+}
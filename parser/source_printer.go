@@ -0,0 +1,617 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/WAY29/LoxGo/lexer"
+)
+
+// 运算符优先级, 数值越大结合越紧密, 与语法规则的层次一一对应
+const (
+	precNone = iota
+	precAssignment
+	precTernary
+	precOr
+	precAnd
+	precEquality
+	precComparison
+	precBitOr
+	precBitXor
+	precBitAnd
+	precShift
+	precTerm
+	precFactor
+	precUnary
+	precExponent
+	precPostfix
+	precCall
+	precPrimary
+)
+
+var binaryPrecedence = map[lexer.TokenType]int{
+	lexer.OR:              precOr,
+	lexer.AND:             precAnd,
+	lexer.EQUAL_EQUAL:     precEquality,
+	lexer.BANG_EQUAL:      precEquality,
+	lexer.GREATER:         precComparison,
+	lexer.GREATER_EQUAL:   precComparison,
+	lexer.LESS:            precComparison,
+	lexer.LESS_EQUAL:      precComparison,
+	lexer.PIPE:            precBitOr,
+	lexer.CARET:           precBitXor,
+	lexer.AMPERSAND:       precBitAnd,
+	lexer.LESS_LESS:       precShift,
+	lexer.GREATER_GREATER: precShift,
+	lexer.PLUS:            precTerm,
+	lexer.MINUS:           precTerm,
+	lexer.STAR:            precFactor,
+	lexer.SLASH:           precFactor,
+	lexer.PERCENT:         precFactor,
+	lexer.STAR_STAR:       precExponent,
+}
+
+const indentUnit = "    "
+
+// 将语法树输出为能被重新解析的Lox源码, 语法树中缺少必要的括号时自动补充
+type SourcePrinter struct { // impl ExprVisitor, StmtVisitor
+	indent   int
+	comments []comment            // 尚未输出的注释与空行, 按位置排列
+	literals map[int]*lexer.Token // 数字与字符串字面量的起始位置 -> token, 用于保留字面量原来的写法
+}
+
+// 带有位置的注释或空行
+type comment struct {
+	lexer.Trivia
+	after int // 前一个token的结束位置, 用于判断是否为行尾注释
+}
+
+// 输出的一行或多行, blank为true时与前面的内容之间空一行
+type entry struct {
+	text  string
+	blank bool
+}
+
+func NewSourcePrinter() *SourcePrinter {
+	return new(SourcePrinter)
+}
+
+func (p *SourcePrinter) Print(expr Expr) string {
+	output, _ := expr.Accept(p)
+	return output.(string)
+}
+
+// 输出语句, 语句的第一行不缩进, 之后的行按当前层次缩进
+func (p *SourcePrinter) PrintStmt(stmt Stmt) string {
+	output, _ := stmt.Accept(p)
+	return output.(string)
+}
+
+// 同时输出tokens中的注释与空行, 语句之间最多保留一个空行, 表达式内部的注释移到所在语句之前;
+// 数字与字符串字面量按tokens中原来的写法输出
+func (p *SourcePrinter) WithComments(tokens []*lexer.Token) *SourcePrinter {
+	p.comments = p.comments[:0]
+	p.literals = make(map[int]*lexer.Token)
+	after := 0
+	for _, token := range tokens {
+		for _, trivia := range token.GetTrivia() {
+			p.comments = append(p.comments, comment{Trivia: trivia, after: after})
+		}
+		after = token.GetEnd().Offset
+		if token.GetType() == lexer.NUMBER || token.GetType() == lexer.STRING {
+			p.literals[token.GetStart().Offset] = token
+		}
+	}
+	return p
+}
+
+// 每条顶层语句输出一行, 函数与类的声明前后空一行
+func (p *SourcePrinter) PrintProgram(statements []Stmt) string {
+	var builder strings.Builder
+	for n, entry := range p.entries(statements, -1, p.PrintStmt) {
+		if n > 0 && entry.blank {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(entry.text)
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// 取出位于offset之前的注释与空行, offset为负数时取出全部
+func (p *SourcePrinter) takeComments(offset int) []comment {
+	n := 0
+	for n < len(p.comments) && (offset < 0 || p.comments[n].Start.Offset < offset) {
+		n++
+	}
+	taken := p.comments[:n]
+	p.comments = p.comments[n:]
+	return taken
+}
+
+func (p *SourcePrinter) peekComment() *comment {
+	if len(p.comments) == 0 || !p.comments[0].IsComment() {
+		return nil
+	}
+	return &p.comments[0]
+}
+
+// 输出一组语句及其前后的注释, end为所在代码块的结束位置
+func (p *SourcePrinter) entries(statements []Stmt, end int, print func(Stmt) string) []entry {
+	entries := make([]entry, 0, len(statements))
+	blank := false
+	addComments := func(comments []comment) {
+		for _, c := range comments {
+			if !c.IsComment() {
+				blank = true
+				continue
+			}
+			entries = append(entries, entry{text: c.Text, blank: blank})
+			blank = false
+		}
+	}
+	for n, stmt := range statements {
+		if n > 0 && (isDeclarationBlock(stmt) || isDeclarationBlock(statements[n-1])) {
+			blank = true
+		}
+		span := stmt.Span()
+		addComments(p.takeComments(span.Start.Offset))
+		text := print(stmt)
+		// 表达式内部的注释无法保持原来的位置, 放在语句之前
+		inner := p.takeComments(span.End.Offset)
+		for _, c := range inner {
+			if c.IsComment() {
+				entries = append(entries, entry{text: c.Text, blank: blank})
+				blank = false
+			}
+		}
+		entries = append(entries, entry{text: text, blank: blank})
+		blank = false
+		// 语句之后同一行的注释
+		if c := p.peekComment(); c != nil && c.after == span.End.Offset && c.Start.Line == span.End.Line && span.End.Offset > 0 {
+			entries[len(entries)-1].text += " " + c.Text
+			p.comments = p.comments[1:]
+		}
+	}
+	addComments(p.takeComments(end))
+	return entries
+}
+func isDeclarationBlock(stmt Stmt) bool {
+	if export, ok := stmt.(*Export); ok {
+		stmt = export.Declaration
+	}
+	switch stmt.(type) {
+	case *Function, *Class:
+		return true
+	}
+	return false
+}
+
+func (p *SourcePrinter) newline() string {
+	return "\n" + strings.Repeat(indentUnit, p.indent)
+}
+
+// 子表达式的优先级低于要求时加上括号
+func (p *SourcePrinter) expr(expr Expr, minPrec int) string {
+	output := p.Print(expr)
+	if precedence(expr) < minPrec {
+		return "(" + output + ")"
+	}
+	return output
+}
+
+func precedence(expr Expr) int {
+	switch expr := expr.(type) {
+	case *Assign, *Set, *IndexSet:
+		return precAssignment
+	case *Ternary:
+		return precTernary
+	case *Binary:
+		if _, ok := interpolationParts(expr); ok {
+			return precPrimary
+		}
+		return binaryPrecedence[expr.Operator.GetType()]
+	case *Logical:
+		return binaryPrecedence[expr.Operator.GetType()]
+	case *Unary:
+		if expr.Prefix {
+			return precUnary
+		}
+		return precPostfix
+	case *Call, *Get, *Index:
+		return precCall
+	case *Spread:
+		return precAssignment
+	}
+	return precPrimary
+}
+
+// 字符串插值被解析为字面量与Stringify相加, 按源码中的顺序取出各次相加
+func interpolationParts(expr *Binary) ([]*Binary, bool) {
+	parts := make([]*Binary, 0)
+	var current Expr = expr
+	for {
+		binary, ok := current.(*Binary)
+		if !ok || !isInterpolationPlus(binary.Operator) {
+			break
+		}
+		parts = append(parts, binary)
+		current = binary.Left
+	}
+	if _, ok := current.(*Literal); !ok || len(parts) == 0 {
+		return nil, false
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return parts, true
+}
+
+// 插值中的+由解析器生成, 位置为所在字符串片段的token, 源码中写出的+不属于插值
+func isInterpolationPlus(operator *lexer.Token) bool {
+	return operator.GetType() == lexer.PLUS && operator.GetSource() != nil && tokenText(operator) != "+"
+}
+
+// token在源码中的原文
+func tokenText(token *lexer.Token) string {
+	return string(token.GetSource().Text[token.GetStart().Offset:token.GetEnd().Offset])
+}
+
+// 按源码中的原文输出各个字符串片段, 片段之间为插值表达式
+func (p *SourcePrinter) interpolation(parts []*Binary) string {
+	var builder strings.Builder
+	opening := tokenText(parts[0].Operator)
+	last := -1 // 插值表达式与之前的字符串片段使用同一个片段的位置, 每个片段只输出一次
+	for _, part := range parts {
+		if offset := part.Operator.GetStart().Offset; offset != last {
+			builder.WriteString(tokenText(part.Operator))
+			last = offset
+		}
+		if stringify, ok := part.Right.(*Stringify); ok {
+			builder.WriteString(p.expr(stringify.Value, precNone) + "}")
+		}
+	}
+	// 最后的字符串片段为空时没有对应的节点, 补上结束的引号
+	if _, ok := parts[len(parts)-1].Right.(*Stringify); ok {
+		if strings.HasPrefix(opening, `"""`) {
+			builder.WriteString(`"""`)
+		} else {
+			builder.WriteString(`"`)
+		}
+	}
+	return builder.String()
+}
+
+func (p *SourcePrinter) list(exprs []Expr) string {
+	parts := make([]string, len(exprs))
+	for n, expr := range exprs {
+		parts[n] = p.expr(expr, precAssignment)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (p *SourcePrinter) block(stmt *Block) string {
+	p.indent++
+	entries := p.entries(stmt.Statements, stmt.Span().End.Offset, p.PrintStmt)
+	p.indent--
+	return p.braces(entries)
+}
+
+// 用大括号包围各行, 第一行之前不空行
+func (p *SourcePrinter) braces(entries []entry) string {
+	if len(entries) == 0 {
+		return "{}"
+	}
+	var builder strings.Builder
+	builder.WriteString("{")
+	p.indent++
+	for n, entry := range entries {
+		if n > 0 && entry.blank {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(p.newline())
+		builder.WriteString(entry.text)
+	}
+	p.indent--
+	builder.WriteString(p.newline())
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// 循环体与分支: 代码块跟在同一行, 其他语句缩进到下一行
+func (p *SourcePrinter) body(stmt Stmt) string {
+	if _, ok := stmt.(*Block); ok {
+		return " " + p.PrintStmt(stmt)
+	}
+	p.indent++
+	defer func() { p.indent-- }()
+	var builder strings.Builder
+	for _, entry := range p.entries([]Stmt{stmt}, 0, p.PrintStmt) {
+		builder.WriteString(p.newline())
+		builder.WriteString(entry.text)
+	}
+	return builder.String()
+}
+
+func (p *SourcePrinter) function(stmt *Function) string {
+	params := make([]string, 0, len(stmt.Params)+1)
+	for n, param := range stmt.Params {
+		if stmt.Defaults[n] != nil {
+			params = append(params, param.GetValue()+" = "+p.expr(stmt.Defaults[n], precAssignment))
+		} else {
+			params = append(params, param.GetValue())
+		}
+	}
+	if stmt.Rest != nil {
+		params = append(params, "..."+stmt.Rest.GetValue())
+	}
+	name := ""
+	if stmt.Name != nil {
+		name = stmt.Name.GetValue()
+	}
+	return name + "(" + strings.Join(params, ", ") + ") " + p.PrintStmt(stmt.Body)
+}
+
+func (p *SourcePrinter) assignment(target string, operator *lexer.Token, value Expr) string {
+	return target + " " + assignOperator(operator) + " " + p.expr(value, precAssignment)
+}
+
+func (p *SourcePrinter) VisitTernaryExpr(expr *Ternary) (interface{}, error) {
+	return p.expr(expr.Condition, precOr) + " ? " + p.expr(expr.ThenExpr, precTernary) + " : " + p.expr(expr.ElseExpr, precTernary), nil
+}
+
+func (p *SourcePrinter) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return p.assignment(expr.Name.GetValue(), expr.Operator, expr.Value), nil
+}
+
+func (p *SourcePrinter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	if parts, ok := interpolationParts(expr); ok {
+		return p.interpolation(parts), nil
+	}
+	prec := binaryPrecedence[expr.Operator.GetType()]
+	// 乘方是右结合的, 左侧为后缀表达式, 右侧为一元表达式
+	if prec == precExponent {
+		return p.expr(expr.Left, precPostfix) + " ** " + p.expr(expr.Right, precUnary), nil
+	}
+	return p.expr(expr.Left, prec) + " " + expr.Operator.GetValue() + " " + p.expr(expr.Right, prec+1), nil
+}
+
+func (p *SourcePrinter) VisitCallExpr(expr *Call) (interface{}, error) {
+	return p.expr(expr.Callee, precCall) + "(" + p.list(expr.Arguments) + ")", nil
+}
+
+func (p *SourcePrinter) VisitGetExpr(expr *Get) (interface{}, error) {
+	return p.expr(expr.Instance, precCall) + "." + expr.Name.GetValue(), nil
+}
+
+func (p *SourcePrinter) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	return "(" + p.expr(expr.Expression, precNone) + ")", nil
+}
+
+func (p *SourcePrinter) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	span := expr.Span()
+	if token, ok := p.literals[span.Start.Offset]; ok && token.GetEnd().Offset == span.End.Offset {
+		return tokenText(token), nil
+	}
+	return formatLiteral(expr.Value), nil
+}
+
+func (p *SourcePrinter) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	prec := binaryPrecedence[expr.Operator.GetType()]
+	return p.expr(expr.Left, prec) + " " + expr.Operator.GetValue() + " " + p.expr(expr.Right, prec+1), nil
+}
+
+func (p *SourcePrinter) VisitSetExpr(expr *Set) (interface{}, error) {
+	target := p.expr(expr.Instance, precCall) + "." + expr.Name.GetValue()
+	return p.assignment(target, expr.Operator, expr.Value), nil
+}
+
+func (p *SourcePrinter) VisitThisExpr(expr *This) (interface{}, error) {
+	return "this", nil
+}
+
+func (p *SourcePrinter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	if !expr.Prefix {
+		return p.expr(expr.Right, precCall) + expr.Operator.GetValue(), nil
+	}
+	operand := p.expr(expr.Right, precUnary)
+	// 避免 - -a 被读作 --a
+	if operator := expr.Operator.GetValue(); strings.HasPrefix(operand, operator[:1]) && (operator[0] == '-' || operator[0] == '+') {
+		return operator + " " + operand, nil
+	}
+	return expr.Operator.GetValue() + operand, nil
+}
+
+func (p *SourcePrinter) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return expr.Name.GetValue(), nil
+}
+
+func (p *SourcePrinter) VisitArrayExpr(expr *Array) (interface{}, error) {
+	return "[" + p.list(expr.Elements) + "]", nil
+}
+
+func (p *SourcePrinter) VisitIndexExpr(expr *Index) (interface{}, error) {
+	return p.expr(expr.Object, precCall) + "[" + p.expr(expr.Index, precNone) + "]", nil
+}
+
+func (p *SourcePrinter) VisitIndexSetExpr(expr *IndexSet) (interface{}, error) {
+	target := p.expr(expr.Object, precCall) + "[" + p.expr(expr.Index, precNone) + "]"
+	return p.assignment(target, expr.Operator, expr.Value), nil
+}
+
+func (p *SourcePrinter) VisitMapExpr(expr *Map) (interface{}, error) {
+	parts := make([]string, len(expr.Keys))
+	for n := range expr.Keys {
+		parts[n] = p.expr(expr.Keys[n], precAssignment) + ": " + p.expr(expr.Values[n], precAssignment)
+	}
+	return "{" + strings.Join(parts, ", ") + "}", nil
+}
+
+func (p *SourcePrinter) VisitLambdaExpr(expr *Lambda) (interface{}, error) {
+	return "fun " + p.function(expr.Function.(*Function)), nil
+}
+
+func (p *SourcePrinter) VisitSuperExpr(expr *Super) (interface{}, error) {
+	return "super." + expr.Method.GetValue(), nil
+}
+
+func (p *SourcePrinter) VisitStringifyExpr(expr *Stringify) (interface{}, error) {
+	return `"${` + p.expr(expr.Value, precNone) + `}"`, nil
+}
+
+func (p *SourcePrinter) VisitSpreadExpr(expr *Spread) (interface{}, error) {
+	return "..." + p.expr(expr.Value, precAssignment), nil
+}
+
+func (p *SourcePrinter) VisitBlockStmt(stmt *Block) (interface{}, error) {
+	// for循环被解析为包含初始化语句与while的代码块, 还原为for循环
+	if len(stmt.Statements) == 2 {
+		if loop, ok := stmt.Statements[1].(*While); ok && loop.Increment != nil {
+			switch stmt.Statements[0].(type) {
+			case *Var, *Expression:
+				return p.forLoop(p.PrintStmt(stmt.Statements[0]), loop), nil
+			}
+		}
+	}
+	return p.block(stmt), nil
+}
+
+func (p *SourcePrinter) forLoop(initializer string, loop *While) string {
+	return "for (" + initializer + " " + p.expr(loop.Condition, precNone) + "; " + p.expr(loop.Increment, precNone) + ")" + p.body(loop.Body)
+}
+
+func (p *SourcePrinter) VisitClassStmt(stmt *Class) (interface{}, error) {
+	var builder strings.Builder
+	builder.WriteString("class " + stmt.Name.GetValue())
+	if stmt.Superclass != nil {
+		builder.WriteString(" < " + stmt.Superclass.Name.GetValue())
+	}
+	// 按源码中的顺序输出方法, 没有位置信息时类方法在前
+	classMethods := make(map[Stmt]bool, len(stmt.ClassMethods))
+	methods := make([]Stmt, 0, len(stmt.ClassMethods)+len(stmt.Methods))
+	for _, method := range stmt.ClassMethods {
+		classMethods[method] = true
+		methods = append(methods, method)
+	}
+	methods = append(methods, stmt.Methods...)
+	sort.SliceStable(methods, func(i, j int) bool {
+		return methods[i].Span().Start.Offset < methods[j].Span().Start.Offset
+	})
+	p.indent++
+	entries := p.entries(methods, stmt.Span().End.Offset, func(method Stmt) string {
+		if classMethods[method] {
+			return "class " + p.function(method.(*Function))
+		}
+		return p.function(method.(*Function))
+	})
+	p.indent--
+	builder.WriteString(" " + p.braces(entries))
+	return builder.String(), nil
+}
+
+func (p *SourcePrinter) VisitExpressionStmt(stmt *Expression) (interface{}, error) {
+	output := p.expr(stmt.Expr, precNone)
+	// 语句开头的'{'与fun会被解析为代码块与函数声明
+	if strings.HasPrefix(output, "{") || strings.HasPrefix(output, "fun ") {
+		output = "(" + output + ")"
+	}
+	return output + ";", nil
+}
+
+func (p *SourcePrinter) VisitFunctionStmt(stmt *Function) (interface{}, error) {
+	return "fun " + p.function(stmt), nil
+}
+
+func (p *SourcePrinter) VisitIfStmt(stmt *If) (interface{}, error) {
+	thenBranch := stmt.ThenBranch
+	// 没有else的if作为then分支时需要代码块, 否则else会属于内层的if
+	if inner, ok := thenBranch.(*If); ok && inner.ElseBranch == nil && stmt.ElseBranch != nil {
+		thenBranch = NewBlock([]Stmt{inner})
+	}
+	output := "if (" + p.expr(stmt.Condition, precNone) + ")" + p.body(thenBranch)
+	if stmt.ElseBranch == nil {
+		return output, nil
+	}
+	if _, ok := thenBranch.(*Block); ok {
+		output += " else"
+	} else {
+		output += p.newline() + "else"
+	}
+	if _, ok := stmt.ElseBranch.(*If); ok {
+		return output + " " + p.PrintStmt(stmt.ElseBranch), nil
+	}
+	return output + p.body(stmt.ElseBranch), nil
+}
+
+func (p *SourcePrinter) VisitPrintStmt(stmt *Print) (interface{}, error) {
+	return "print " + p.expr(stmt.Expr, precNone) + ";", nil
+}
+
+func (p *SourcePrinter) VisitReturnStmt(stmt *Return) (interface{}, error) {
+	if stmt.Value == nil {
+		return "return;", nil
+	}
+	return "return " + p.expr(stmt.Value, precNone) + ";", nil
+}
+
+func (p *SourcePrinter) VisitVarStmt(stmt *Var) (interface{}, error) {
+	parts := make([]string, len(stmt.Names))
+	for n, name := range stmt.Names {
+		parts[n] = name.GetValue()
+		if stmt.Initializers[n] != nil {
+			parts[n] += " = " + p.expr(stmt.Initializers[n], precAssignment)
+		}
+	}
+	return "var " + strings.Join(parts, ", ") + ";", nil
+}
+
+func (p *SourcePrinter) VisitWhileStmt(stmt *While) (interface{}, error) {
+	if stmt.Increment != nil {
+		return p.forLoop(";", stmt), nil
+	}
+	return "while (" + p.expr(stmt.Condition, precNone) + ")" + p.body(stmt.Body), nil
+}
+
+func (p *SourcePrinter) VisitForInStmt(stmt *ForIn) (interface{}, error) {
+	return "for (var " + stmt.Name.GetValue() + " in " + p.expr(stmt.Iterable, precNone) + ")" + p.body(stmt.Body), nil
+}
+
+func (p *SourcePrinter) VisitBreakStmt(stmt *Break) (interface{}, error) {
+	return "break;", nil
+}
+
+func (p *SourcePrinter) VisitContinueStmt(stmt *Continue) (interface{}, error) {
+	return "continue;", nil
+}
+
+func (p *SourcePrinter) VisitTryStmt(stmt *Try) (interface{}, error) {
+	output := "try " + p.PrintStmt(stmt.Body)
+	if stmt.CatchBody != nil {
+		output += " catch "
+		if stmt.CatchName != nil {
+			output += "(" + stmt.CatchName.GetValue() + ") "
+		}
+		output += p.PrintStmt(stmt.CatchBody)
+	}
+	if stmt.FinallyBody != nil {
+		output += " finally " + p.PrintStmt(stmt.FinallyBody)
+	}
+	return output, nil
+}
+
+func (p *SourcePrinter) VisitThrowStmt(stmt *Throw) (interface{}, error) {
+	return "throw " + p.expr(stmt.Value, precNone) + ";", nil
+}
+
+func (p *SourcePrinter) VisitImportStmt(stmt *Import) (interface{}, error) {
+	path := stmt.Path.GetLiteral().(string)
+	output := "import " + quoteString(path)
+	if name := stmt.Name.GetValue(); name != moduleName(path) {
+		output += " as " + name
+	}
+	return output + ";", nil
+}
+
+func (p *SourcePrinter) VisitExportStmt(stmt *Export) (interface{}, error) {
+	return "export " + p.PrintStmt(stmt.Declaration), nil
+}
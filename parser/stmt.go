@@ -6,34 +6,38 @@ import "github.com/WAY29/LoxGo/lexer"
 
 type Stmt interface {
 	Accept(v StmtVisitor) (interface{}, error)
+	Span() Span
 }
 
 type Block struct {
+	node
 	Statements []Stmt
-	Stop       bool
-	Parent     *Block
 }
 
-func NewBlock(statements []Stmt, stop bool, parent *Block) *Block {
-	return &Block{Statements: statements, Stop: stop, Parent: parent}
+func NewBlock(statements []Stmt) *Block {
+	return &Block{Statements: statements}
 }
 func (n *Block) Accept(v StmtVisitor) (interface{}, error) {
 	return v.VisitBlockStmt(n)
 }
 
 type Class struct {
-	Name    *lexer.Token
-	Methods []Stmt
+	node
+	Name         *lexer.Token
+	Superclass   *Variable
+	Methods      []Stmt
+	ClassMethods []Stmt
 }
 
-func NewClass(name *lexer.Token, methods []Stmt) *Class {
-	return &Class{Name: name, Methods: methods}
+func NewClass(name *lexer.Token, superclass *Variable, methods []Stmt, classmethods []Stmt) *Class {
+	return &Class{Name: name, Superclass: superclass, Methods: methods, ClassMethods: classmethods}
 }
 func (n *Class) Accept(v StmtVisitor) (interface{}, error) {
 	return v.VisitClassStmt(n)
 }
 
 type Expression struct {
+	node
 	Expr Expr
 }
 
@@ -45,19 +49,23 @@ func (n *Expression) Accept(v StmtVisitor) (interface{}, error) {
 }
 
 type Function struct {
-	Name   *lexer.Token
-	Params []*lexer.Token
-	Body   Stmt
+	node
+	Name     *lexer.Token
+	Params   []*lexer.Token
+	Defaults []Expr
+	Rest     *lexer.Token
+	Body     Stmt
 }
 
-func NewFunction(name *lexer.Token, params []*lexer.Token, body Stmt) *Function {
-	return &Function{Name: name, Params: params, Body: body}
+func NewFunction(name *lexer.Token, params []*lexer.Token, defaults []Expr, rest *lexer.Token, body Stmt) *Function {
+	return &Function{Name: name, Params: params, Defaults: defaults, Rest: rest, Body: body}
 }
 func (n *Function) Accept(v StmtVisitor) (interface{}, error) {
 	return v.VisitFunctionStmt(n)
 }
 
 type If struct {
+	node
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
@@ -71,6 +79,7 @@ func (n *If) Accept(v StmtVisitor) (interface{}, error) {
 }
 
 type Print struct {
+	node
 	Expr Expr
 }
 
@@ -82,6 +91,7 @@ func (n *Print) Accept(v StmtVisitor) (interface{}, error) {
 }
 
 type Return struct {
+	node
 	Keyword *lexer.Token
 	Value   Expr
 }
@@ -94,6 +104,7 @@ func (n *Return) Accept(v StmtVisitor) (interface{}, error) {
 }
 
 type Var struct {
+	node
 	Names        []*lexer.Token
 	Initializers []Expr
 }
@@ -106,40 +117,110 @@ func (n *Var) Accept(v StmtVisitor) (interface{}, error) {
 }
 
 type While struct {
+	node
 	Condition Expr
 	Body      Stmt
-	Stop      bool
-	Parent    *While
+	Increment Expr
 }
 
-func NewWhile(condition Expr, body Stmt, stop bool, parent *While) *While {
-	return &While{Condition: condition, Body: body, Stop: stop, Parent: parent}
+func NewWhile(condition Expr, body Stmt, increment Expr) *While {
+	return &While{Condition: condition, Body: body, Increment: increment}
 }
 func (n *While) Accept(v StmtVisitor) (interface{}, error) {
 	return v.VisitWhileStmt(n)
 }
 
+type ForIn struct {
+	node
+	Name     *lexer.Token
+	In       *lexer.Token
+	Iterable Expr
+	Body     Stmt
+}
+
+func NewForIn(name *lexer.Token, in *lexer.Token, iterable Expr, body Stmt) *ForIn {
+	return &ForIn{Name: name, In: in, Iterable: iterable, Body: body}
+}
+func (n *ForIn) Accept(v StmtVisitor) (interface{}, error) {
+	return v.VisitForInStmt(n)
+}
+
 type Break struct {
-	Parent Stmt
-	Block  Stmt
-	Token  *lexer.Token
+	node
+	Keyword *lexer.Token
 }
 
-func NewBreak(parent Stmt, block Stmt, token *lexer.Token) *Break {
-	return &Break{Parent: parent, Block: block, Token: token}
+func NewBreak(keyword *lexer.Token) *Break {
+	return &Break{Keyword: keyword}
 }
 func (n *Break) Accept(v StmtVisitor) (interface{}, error) {
 	return v.VisitBreakStmt(n)
 }
 
 type Continue struct {
-	Block Stmt
-	Token *lexer.Token
+	node
+	Keyword *lexer.Token
 }
 
-func NewContinue(block Stmt, token *lexer.Token) *Continue {
-	return &Continue{Block: block, Token: token}
+func NewContinue(keyword *lexer.Token) *Continue {
+	return &Continue{Keyword: keyword}
 }
 func (n *Continue) Accept(v StmtVisitor) (interface{}, error) {
 	return v.VisitContinueStmt(n)
 }
+
+type Try struct {
+	node
+	Keyword     *lexer.Token
+	Body        Stmt
+	CatchName   *lexer.Token
+	CatchBody   Stmt
+	FinallyBody Stmt
+}
+
+func NewTry(keyword *lexer.Token, body Stmt, catchname *lexer.Token, catchbody Stmt, finallybody Stmt) *Try {
+	return &Try{Keyword: keyword, Body: body, CatchName: catchname, CatchBody: catchbody, FinallyBody: finallybody}
+}
+func (n *Try) Accept(v StmtVisitor) (interface{}, error) {
+	return v.VisitTryStmt(n)
+}
+
+type Throw struct {
+	node
+	Keyword *lexer.Token
+	Value   Expr
+}
+
+func NewThrow(keyword *lexer.Token, value Expr) *Throw {
+	return &Throw{Keyword: keyword, Value: value}
+}
+func (n *Throw) Accept(v StmtVisitor) (interface{}, error) {
+	return v.VisitThrowStmt(n)
+}
+
+type Import struct {
+	node
+	Keyword *lexer.Token
+	Path    *lexer.Token
+	Name    *lexer.Token
+}
+
+func NewImport(keyword *lexer.Token, path *lexer.Token, name *lexer.Token) *Import {
+	return &Import{Keyword: keyword, Path: path, Name: name}
+}
+func (n *Import) Accept(v StmtVisitor) (interface{}, error) {
+	return v.VisitImportStmt(n)
+}
+
+type Export struct {
+	node
+	Keyword     *lexer.Token
+	Declaration Stmt
+}
+
+func NewExport(keyword *lexer.Token, declaration Stmt) *Export {
+	return &Export{Keyword: keyword, Declaration: declaration}
+}
+func (n *Export) Accept(v StmtVisitor) (interface{}, error) {
+	return v.VisitExportStmt(n)
+}
@@ -12,6 +12,11 @@ type StmtVisitor interface {
 	VisitReturnStmt(stmt *Return) (interface{}, error)
 	VisitVarStmt(stmt *Var) (interface{}, error)
 	VisitWhileStmt(stmt *While) (interface{}, error)
+	VisitForInStmt(stmt *ForIn) (interface{}, error)
 	VisitBreakStmt(stmt *Break) (interface{}, error)
 	VisitContinueStmt(stmt *Continue) (interface{}, error)
+	VisitTryStmt(stmt *Try) (interface{}, error)
+	VisitThrowStmt(stmt *Throw) (interface{}, error)
+	VisitImportStmt(stmt *Import) (interface{}, error)
+	VisitExportStmt(stmt *Export) (interface{}, error)
 }
@@ -24,35 +24,45 @@ func main() {
 	}
 
 	defineAst(out, "Expr", []string{
-		"Ternary  : Condition Expr, ThenExpr Expr, ElseExpr Expr",
-		"Assign   : Name *lexer.Token, Value Expr",
-		"Binary   : Left Expr, Operator *lexer.Token, Right Expr",
-		"Call     : Callee Expr, Paren *lexer.Token, Arguments []Expr, ReturnValue interface{}",
-		"Get      : Instance Expr, Name *lexer.Token",
-		"Grouping : Expression Expr",
-		"Literal  : Value interface{}",
-		"Logical  : Left Expr, Operator *lexer.Token, Right Expr",
-		"Set      : Instance Expr, Name *lexer.Token, Value Expr",
-		"This     : Keyword *lexer.Token",
-		"Unary    : Operator *lexer.Token, Right Expr, Prefix bool",
-		"Variable : Name *lexer.Token",
-		"Array    : Token *lexer.Token, Elements []Expr",
-		"Index    : Name *lexer.Token, Index Expr",
-		"Lambda   : Token *lexer.Token, Function Stmt",
+		"Ternary   : Condition Expr, ThenExpr Expr, ElseExpr Expr",
+		"Assign    : Name *lexer.Token, Value Expr, Operator *lexer.Token",
+		"Binary    : Left Expr, Operator *lexer.Token, Right Expr",
+		"Call      : Callee Expr, Paren *lexer.Token, Arguments []Expr",
+		"Get       : Instance Expr, Name *lexer.Token",
+		"Grouping  : Expression Expr",
+		"Literal   : Value interface{}",
+		"Logical   : Left Expr, Operator *lexer.Token, Right Expr",
+		"Set       : Instance Expr, Name *lexer.Token, Value Expr, Operator *lexer.Token",
+		"This      : Keyword *lexer.Token",
+		"Unary     : Operator *lexer.Token, Right Expr, Prefix bool",
+		"Variable  : Name *lexer.Token",
+		"Array     : Token *lexer.Token, Elements []Expr",
+		"Index     : Object Expr, Bracket *lexer.Token, Index Expr",
+		"IndexSet  : Object Expr, Bracket *lexer.Token, Index Expr, Value Expr, Operator *lexer.Token",
+		"Map       : Brace *lexer.Token, Keys []Expr, Values []Expr",
+		"Lambda    : Token *lexer.Token, Function Stmt",
+		"Super     : Keyword *lexer.Token, Method *lexer.Token",
+		"Stringify : Value Expr",
+		"Spread    : Token *lexer.Token, Value Expr",
 	})
 
 	defineAst(out, "Stmt", []string{
-		"Block      : Statements []Stmt, Stop bool, Parent *Block",
-		"Class      : Name *lexer.Token, Methods []Stmt",
+		"Block      : Statements []Stmt",
+		"Class      : Name *lexer.Token, Superclass *Variable, Methods []Stmt, ClassMethods []Stmt",
 		"Expression : Expr Expr",
-		"Function   : Name *lexer.Token, Params []*lexer.Token, Body Stmt",
+		"Function   : Name *lexer.Token, Params []*lexer.Token, Defaults []Expr, Rest *lexer.Token, Body Stmt",
 		"If         : Condition Expr, ThenBranch Stmt, ElseBranch Stmt",
 		"Print      : Expr Expr",
 		"Return     : Keyword *lexer.Token, Value Expr",
 		"Var        : Names []*lexer.Token, Initializers []Expr",
-		"While      : Condition Expr, Body Stmt, Stop bool, Parent *While",
-		"Break      : Parent Stmt, Block Stmt, Token *lexer.Token",
-		"Continue   : Block Stmt, Token *lexer.Token",
+		"While      : Condition Expr, Body Stmt, Increment Expr",
+		"ForIn      : Name *lexer.Token, In *lexer.Token, Iterable Expr, Body Stmt",
+		"Break      : Keyword *lexer.Token",
+		"Continue   : Keyword *lexer.Token",
+		"Try        : Keyword *lexer.Token, Body Stmt, CatchName *lexer.Token, CatchBody Stmt, FinallyBody Stmt",
+		"Throw      : Keyword *lexer.Token, Value Expr",
+		"Import     : Keyword *lexer.Token, Path *lexer.Token, Name *lexer.Token",
+		"Export     : Keyword *lexer.Token, Declaration Stmt",
 	})
 }
 
@@ -109,6 +119,7 @@ func defineBase(base string, types []string) string {
 	src += fmt.Sprintf("type %s interface {", base)
 	src += fmt.Sprintln("")
 	src += fmt.Sprintf("Accept(v %sVisitor) (interface{}, error)\n", base)
+	src += fmt.Sprintln("Span() Span")
 	src += fmt.Sprintln("}")
 
 	return src
@@ -122,6 +133,7 @@ func defineType(base, cls, fld string) string {
 	src += fmt.Sprintln("")
 
 	// fields
+	src += fmt.Sprintln("node")
 	fs := strings.Split(fld, ",")
 	for _, f := range fs {
 		src += fmt.Sprintln(f)
@@ -0,0 +1,74 @@
+package vm
+
+import (
+	"sort"
+
+	"github.com/WAY29/LoxGo/lexer"
+	"github.com/WAY29/LoxGo/parser"
+)
+
+// 位置表以游程方式存储: 每一项记录从offset开始的指令对应的token, 用于错误定位
+type tokenStart struct {
+	offset int
+	token  *lexer.Token
+}
+
+type Chunk struct {
+	Code      []byte
+	Constants []interface{}
+
+	tokens    []tokenStart
+	calls     map[int]*parser.Call // 调用指令最后一个字节的位置 -> 调用表达式, 用于生成调用栈
+	constants map[interface{}]int
+}
+
+func NewChunk() *Chunk {
+	return &Chunk{
+		Code:      make([]byte, 0),
+		Constants: make([]interface{}, 0),
+		tokens:    make([]tokenStart, 0),
+		calls:     make(map[int]*parser.Call),
+		constants: make(map[interface{}]int),
+	}
+}
+
+func (c *Chunk) write(b byte, token *lexer.Token) {
+	c.Code = append(c.Code, b)
+	if n := len(c.tokens); n == 0 || c.tokens[n-1].token != token {
+		c.tokens = append(c.tokens, tokenStart{offset: len(c.Code) - 1, token: token})
+	}
+}
+
+// 在刚写入的调用指令上记录调用表达式
+func (c *Chunk) addCall(call *parser.Call) {
+	c.calls[len(c.Code)-1] = call
+}
+
+func (c *Chunk) addConstant(value interface{}) int {
+	// 相同的字符串与数字常量只保存一份
+	switch value.(type) {
+	case string, int, int64, float64, bool:
+		if index, ok := c.constants[value]; ok {
+			return index
+		}
+		c.constants[value] = len(c.Constants)
+	}
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+// 指令对应的token, 没有记录时返回nil
+func (c *Chunk) GetToken(offset int) *lexer.Token {
+	n := sort.Search(len(c.tokens), func(i int) bool {
+		return c.tokens[i].offset > offset
+	})
+	if n == 0 {
+		return nil
+	}
+	return c.tokens[n-1].token
+}
+
+// 执行到offset处的调用指令时对应的调用表达式, 不是调用指令时返回nil
+func (c *Chunk) GetCall(offset int) *parser.Call {
+	return c.calls[offset]
+}
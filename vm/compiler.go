@@ -0,0 +1,1110 @@
+package vm
+
+import (
+	"github.com/WAY29/LoxGo/lexer"
+	"github.com/WAY29/LoxGo/parser"
+)
+
+const (
+	maxLocals   = 256
+	maxUpvalues = 256
+	maxShort    = 65535
+)
+
+type FunctionType uint8
+
+const (
+	FunctionTypeScript FunctionType = iota
+	FunctionTypeFunction
+	FunctionTypeMethod
+	FunctionTypeInitializer
+)
+
+type local struct {
+	name       string
+	depth      int
+	isCaptured bool
+}
+
+type upvalueRef struct {
+	index   uint8
+	isLocal bool
+}
+
+type loopState struct {
+	enclosing  *loopState
+	scopeDepth int
+	breaks     []int
+	continues  []int
+}
+
+// 正在编译的try语句, 用于在break, continue与return跳出时执行finally
+type tryState struct {
+	enclosing *tryState
+	loop      *loopState // try语句所在的循环
+	locals    int        // try语句开始时的局部变量个数
+	finally   parser.Stmt
+}
+
+type classState struct {
+	enclosing     *classState
+	hasSuperclass bool
+}
+
+type Compiler struct { // impl ExprVisitor, StmtVisitor
+	enclosing    *Compiler
+	function     *Function
+	functionType FunctionType
+
+	locals     []local
+	upvalues   []upvalueRef
+	scopeDepth int
+
+	loop  *loopState
+	try   *tryState
+	class *classState
+
+	// 由Resolver记录的局部变量, 未记录的变量均为全局变量
+	resolved map[parser.Expr]int
+	line     int
+	token    *lexer.Token // 最近编译的token, 用于错误定位
+	err      *error
+}
+
+func NewCompiler() *Compiler {
+	var err error
+	c := &Compiler{
+		function:     newFunction(""),
+		functionType: FunctionTypeScript,
+		locals:       make([]local, 0, maxLocals),
+		resolved:     make(map[parser.Expr]int),
+		line:         1,
+		err:          &err,
+	}
+	c.function.script = true
+	// 0号栈槽保留给被调用的函数本身
+	c.locals = append(c.locals, local{name: "", depth: 0})
+	return c
+}
+
+func newFunctionCompiler(enclosing *Compiler, functionType FunctionType, name string) *Compiler {
+	c := &Compiler{
+		enclosing:    enclosing,
+		function:     newFunction(name),
+		functionType: functionType,
+		locals:       make([]local, 0, maxLocals),
+		class:        enclosing.class,
+		resolved:     enclosing.resolved,
+		line:         enclosing.line,
+		token:        enclosing.token,
+		err:          enclosing.err,
+	}
+	// 方法的0号栈槽存放this
+	slotName := ""
+	if functionType == FunctionTypeMethod || functionType == FunctionTypeInitializer {
+		slotName = "this"
+	}
+	c.locals = append(c.locals, local{name: slotName, depth: 0})
+	return c
+}
+
+func (c *Compiler) Resolve(expr parser.Expr, depth int) {
+	c.resolved[expr] = depth
+}
+
+func (c *Compiler) Compile(statements []parser.Stmt) (*Function, error) {
+	for n, stmt := range statements {
+		// 顶层表达式语句的值作为结果返回
+		if expression, ok := stmt.(*parser.Expression); ok {
+			c.expression(expression.Expr)
+			c.emitOpShort(OpResult, n)
+		} else {
+			c.statement(stmt)
+		}
+	}
+	return c.finish()
+}
+
+// 编译被导入的模块, 模块的顶层表达式语句不产生结果
+func (c *Compiler) CompileModule(statements []parser.Stmt) (*Function, error) {
+	for _, stmt := range statements {
+		c.statement(stmt)
+	}
+	return c.finish()
+}
+
+func (c *Compiler) finish() (*Function, error) {
+	c.emitReturn()
+
+	if *c.err != nil {
+		return nil, *c.err
+	}
+	return c.function, nil
+}
+
+func (c *Compiler) error(format string, a ...interface{}) {
+	c.errorAt(c.token, format, a...)
+}
+
+func (c *Compiler) errorAt(token *lexer.Token, format string, a ...interface{}) {
+	if token == nil {
+		token = c.token
+	}
+	if *c.err == nil {
+		line := c.line
+		if token != nil {
+			line = token.GetLine()
+		}
+		*c.err = newCompileError(line, token, format, a...)
+	}
+}
+
+func (c *Compiler) setLine(token *lexer.Token) {
+	if token != nil {
+		c.line = token.GetLine()
+		c.token = token
+	}
+}
+
+func (c *Compiler) expression(expr parser.Expr) {
+	if expr == nil {
+		c.emitOp(OpNil)
+		return
+	}
+	expr.Accept(c)
+}
+
+func (c *Compiler) statement(stmt parser.Stmt) {
+	if stmt == nil {
+		return
+	}
+	stmt.Accept(c)
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return c.function.chunk
+}
+
+func (c *Compiler) emitByte(b byte) {
+	c.chunk().write(b, c.token)
+}
+
+func (c *Compiler) emitBytes(bytes ...byte) {
+	for _, b := range bytes {
+		c.emitByte(b)
+	}
+}
+
+func (c *Compiler) emitOp(op OpCode) {
+	c.emitByte(byte(op))
+}
+
+func (c *Compiler) emitOpByte(op OpCode, operand int) {
+	c.emitBytes(byte(op), byte(operand))
+}
+
+func (c *Compiler) emitShort(operand int) {
+	c.emitBytes(byte(operand>>8), byte(operand))
+}
+
+func (c *Compiler) emitOpShort(op OpCode, operand int) {
+	c.emitOp(op)
+	c.emitShort(operand)
+}
+
+func (c *Compiler) makeConstant(value interface{}) int {
+	index := c.chunk().addConstant(value)
+	if index > maxShort {
+		c.error("Too many constants in one chunk.")
+		return 0
+	}
+	return index
+}
+
+func (c *Compiler) emitConstant(value interface{}) {
+	c.emitOpShort(OpConstant, c.makeConstant(value))
+}
+
+func (c *Compiler) identifierConstant(name string) int {
+	return c.makeConstant(name)
+}
+
+func (c *Compiler) emitJump(op OpCode) int {
+	c.emitOp(op)
+	c.emitShort(0xffff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > maxShort {
+		// 指向跳转指令对应的token, 而不是跳转目标处
+		c.errorAt(c.chunk().GetToken(offset-1), "Too much code to jump over.")
+	}
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) emitLoop(loopStart int) {
+	c.emitOp(OpLoop)
+	offset := len(c.chunk().Code) - loopStart + 2
+	if offset > maxShort {
+		c.error("Loop body too large.")
+	}
+	c.emitShort(offset)
+}
+
+func (c *Compiler) emitReturn() {
+	c.emitReturnValue()
+	c.emitOp(OpReturn)
+}
+
+// 压入没有返回值时的默认返回值
+func (c *Compiler) emitReturnValue() {
+	if c.functionType == FunctionTypeInitializer {
+		c.emitOpByte(OpGetLocal, 0)
+	} else {
+		c.emitOp(OpNil)
+	}
+}
+
+func (c *Compiler) beginScope() {
+	c.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	c.scopeDepth--
+	for len(c.locals) > 0 && c.locals[len(c.locals)-1].depth > c.scopeDepth {
+		if c.locals[len(c.locals)-1].isCaptured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+		c.locals = c.locals[:len(c.locals)-1]
+	}
+}
+
+// 弹出比depth更深的局部变量, 但不从编译器中移除, 用于break与continue
+func (c *Compiler) discardLocals(depth int) {
+	for n := len(c.locals) - 1; n >= 0 && c.locals[n].depth > depth; n-- {
+		if c.locals[n].isCaptured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+	}
+}
+
+func (c *Compiler) addLocal(name string) {
+	if len(c.locals) >= maxLocals {
+		c.error("Too many local variables in function.")
+		return
+	}
+	c.locals = append(c.locals, local{name: name, depth: c.scopeDepth})
+}
+
+func (c *Compiler) resolveLocal(name string) int {
+	for n := len(c.locals) - 1; n >= 0; n-- {
+		if c.locals[n].name == name {
+			return n
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) addUpvalue(index uint8, isLocal bool) int {
+	for n, upvalue := range c.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return n
+		}
+	}
+	if len(c.upvalues) >= maxUpvalues {
+		c.error("Too many closure variables in function.")
+		return 0
+	}
+	c.upvalues = append(c.upvalues, upvalueRef{index: index, isLocal: isLocal})
+	c.function.upvalueCount = len(c.upvalues)
+	return len(c.upvalues) - 1
+}
+
+func (c *Compiler) resolveUpvalue(name string) int {
+	if c.enclosing == nil {
+		return -1
+	}
+	if local := c.enclosing.resolveLocal(name); local != -1 {
+		c.enclosing.locals[local].isCaptured = true
+		return c.addUpvalue(uint8(local), true)
+	}
+	if upvalue := c.enclosing.resolveUpvalue(name); upvalue != -1 {
+		return c.addUpvalue(uint8(upvalue), false)
+	}
+	return -1
+}
+
+// 根据变量所在位置选择读写指令
+func (c *Compiler) variableOps(expr parser.Expr, name string) (getOp, setOp OpCode, arg int, isShort bool) {
+	if _, ok := c.resolved[expr]; ok || expr == nil {
+		if arg = c.resolveLocal(name); arg != -1 {
+			return OpGetLocal, OpSetLocal, arg, false
+		} else if arg = c.resolveUpvalue(name); arg != -1 {
+			return OpGetUpvalue, OpSetUpvalue, arg, false
+		}
+	}
+	return OpGetGlobal, OpSetGlobal, c.identifierConstant(name), true
+}
+
+func (c *Compiler) getVariable(expr parser.Expr, name string) {
+	getOp, _, arg, isShort := c.variableOps(expr, name)
+	if isShort {
+		c.emitOpShort(getOp, arg)
+	} else {
+		c.emitOpByte(getOp, arg)
+	}
+}
+
+func (c *Compiler) setVariable(expr parser.Expr, name string) {
+	_, setOp, arg, isShort := c.variableOps(expr, name)
+	if isShort {
+		c.emitOpShort(setOp, arg)
+	} else {
+		c.emitOpByte(setOp, arg)
+	}
+}
+
+func (c *Compiler) defineVariable(name string) {
+	if c.scopeDepth > 0 {
+		c.addLocal(name)
+		return
+	}
+	c.emitOpShort(OpDefineGlobal, c.identifierConstant(name))
+}
+
+func (c *Compiler) compileFunction(stmt *parser.Function, functionType FunctionType) {
+	name := ""
+	if stmt.Name != nil {
+		name = stmt.Name.GetValue()
+	}
+	compiler := newFunctionCompiler(c, functionType, name)
+	compiler.beginScope()
+	for n, param := range stmt.Params {
+		if stmt.Defaults[n] == nil {
+			compiler.function.arity++
+		} else {
+			compiler.function.optional++
+		}
+		compiler.addLocal(param.GetValue())
+	}
+	if stmt.Rest != nil {
+		compiler.function.rest = true
+		compiler.addLocal(stmt.Rest.GetValue())
+	}
+	// 调用时没有传入的参数在函数开头求默认值
+	for n, defaultValue := range stmt.Defaults {
+		if defaultValue == nil {
+			continue
+		}
+		compiler.emitOpByte(OpJumpIfArgument, n)
+		compiler.emitShort(0xffff)
+		jump := len(compiler.chunk().Code) - 2
+		compiler.expression(defaultValue)
+		compiler.emitOpByte(OpSetLocal, n+1)
+		compiler.emitOp(OpPop)
+		compiler.patchJump(jump)
+	}
+	compiler.statement(stmt.Body)
+	compiler.emitReturn()
+
+	c.line = compiler.line
+	c.token = compiler.token
+	c.emitOpShort(OpClosure, c.makeConstant(compiler.function))
+	for _, upvalue := range compiler.upvalues {
+		isLocal := byte(0)
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		c.emitBytes(isLocal, upvalue.index)
+	}
+}
+
+func (c *Compiler) VisitTernaryExpr(expr *parser.Ternary) (interface{}, error) {
+	c.expression(expr.Condition)
+	elseJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.expression(expr.ThenExpr)
+	endJump := c.emitJump(OpJump)
+
+	c.patchJump(elseJump)
+	c.emitOp(OpPop)
+	c.expression(expr.ElseExpr)
+	c.patchJump(endJump)
+	return nil, nil
+}
+
+func (c *Compiler) VisitAssignExpr(expr *parser.Assign) (interface{}, error) {
+	c.setLine(expr.Name)
+	if expr.Operator != nil {
+		c.getVariable(expr, expr.Name.GetValue())
+	}
+	c.expression(expr.Value)
+	c.compoundOperator(expr.Operator)
+	c.setVariable(expr, expr.Name.GetValue())
+	return nil, nil
+}
+
+// 复合赋值: 栈顶为当前值与右侧的值, 合并为一个值
+func (c *Compiler) compoundOperator(operator *lexer.Token) {
+	if operator == nil {
+		return
+	}
+	c.setLine(operator)
+	c.emitOpByte(OpBinary, int(operator.GetType()))
+}
+
+func (c *Compiler) VisitBinaryExpr(expr *parser.Binary) (interface{}, error) {
+	c.expression(expr.Left)
+	c.expression(expr.Right)
+	c.setLine(expr.Operator)
+	c.emitOpByte(OpBinary, int(expr.Operator.GetType()))
+	return nil, nil
+}
+
+func hasSpread(exprs []parser.Expr) bool {
+	for _, expr := range exprs {
+		if _, ok := expr.(*parser.Spread); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 依次对元素求值并合并为一个数组, ...arr 展开为多个元素
+func (c *Compiler) spreadElements(elements []parser.Expr) {
+	pieces, count := 0, 0
+	flush := func() {
+		if count > 0 {
+			c.emitOpShort(OpArray, count)
+			pieces, count = pieces+1, 0
+		}
+	}
+	for _, element := range elements {
+		if spread, ok := element.(*parser.Spread); ok {
+			flush()
+			c.expression(spread.Value)
+			c.setLine(spread.Token)
+			pieces++
+			continue
+		}
+		c.expression(element)
+		count++
+	}
+	flush()
+	c.emitOpShort(OpConcat, pieces)
+}
+
+func (c *Compiler) VisitCallExpr(expr *parser.Call) (interface{}, error) {
+	// 展开实参时参数个数在运行时才能确定
+	if hasSpread(expr.Arguments) {
+		c.expression(expr.Callee)
+		c.spreadElements(expr.Arguments)
+		c.setLine(expr.Paren)
+		c.emitOp(OpCallArray)
+		c.chunk().addCall(expr)
+		return nil, nil
+	}
+
+	argCount := len(expr.Arguments)
+	if argCount > 255 {
+		c.error("Can't have more than 255 arguments.")
+	}
+
+	switch callee := expr.Callee.(type) {
+	case *parser.Get:
+		// obj.method(args) 直接调用方法, 不创建BoundMethod
+		c.expression(callee.Instance)
+		for _, argument := range expr.Arguments {
+			c.expression(argument)
+		}
+		c.setLine(expr.Paren)
+		c.emitOpShort(OpInvoke, c.identifierConstant(callee.Name.GetValue()))
+		c.emitByte(byte(argCount))
+		c.chunk().addCall(expr)
+	case *parser.Super:
+		c.setLine(callee.Keyword)
+		c.getVariable(callee, "this")
+		for _, argument := range expr.Arguments {
+			c.expression(argument)
+		}
+		c.getVariable(callee, "super")
+		c.setLine(expr.Paren)
+		c.emitOpShort(OpSuperInvoke, c.identifierConstant(callee.Method.GetValue()))
+		c.emitByte(byte(argCount))
+		c.chunk().addCall(expr)
+	default:
+		c.expression(expr.Callee)
+		for _, argument := range expr.Arguments {
+			c.expression(argument)
+		}
+		c.setLine(expr.Paren)
+		c.emitOpByte(OpCall, argCount)
+		c.chunk().addCall(expr)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitGetExpr(expr *parser.Get) (interface{}, error) {
+	c.expression(expr.Instance)
+	c.setLine(expr.Name)
+	c.emitOpShort(OpGetProperty, c.identifierConstant(expr.Name.GetValue()))
+	return nil, nil
+}
+
+func (c *Compiler) VisitGroupingExpr(expr *parser.Grouping) (interface{}, error) {
+	c.expression(expr.Expression)
+	return nil, nil
+}
+
+func (c *Compiler) VisitLiteralExpr(expr *parser.Literal) (interface{}, error) {
+	switch expr.Value {
+	case nil:
+		c.emitOp(OpNil)
+	case true:
+		c.emitOp(OpTrue)
+	case false:
+		c.emitOp(OpFalse)
+	default:
+		c.emitConstant(expr.Value)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitLogicalExpr(expr *parser.Logical) (interface{}, error) {
+	c.expression(expr.Left)
+	c.setLine(expr.Operator)
+	if expr.Operator.GetType() == lexer.OR {
+		elseJump := c.emitJump(OpJumpIfFalse)
+		endJump := c.emitJump(OpJump)
+		c.patchJump(elseJump)
+		c.emitOp(OpPop)
+		c.expression(expr.Right)
+		c.patchJump(endJump)
+	} else { // AND
+		endJump := c.emitJump(OpJumpIfFalse)
+		c.emitOp(OpPop)
+		c.expression(expr.Right)
+		c.patchJump(endJump)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitSetExpr(expr *parser.Set) (interface{}, error) {
+	c.expression(expr.Instance)
+	if expr.Operator != nil {
+		c.setLine(expr.Name)
+		c.emitOpByte(OpDup, 1)
+		c.emitOpShort(OpGetProperty, c.identifierConstant(expr.Name.GetValue()))
+	}
+	c.expression(expr.Value)
+	c.compoundOperator(expr.Operator)
+	c.setLine(expr.Name)
+	c.emitOpShort(OpSetProperty, c.identifierConstant(expr.Name.GetValue()))
+	return nil, nil
+}
+
+func (c *Compiler) VisitThisExpr(expr *parser.This) (interface{}, error) {
+	c.setLine(expr.Keyword)
+	c.getVariable(expr, "this")
+	return nil, nil
+}
+
+func (c *Compiler) VisitUnaryExpr(expr *parser.Unary) (interface{}, error) {
+	c.setLine(expr.Operator)
+	operator := expr.Operator.GetType()
+	if operator != lexer.PLUSPLUS && operator != lexer.MINUSMINUS {
+		c.expression(expr.Right)
+		c.emitOpByte(OpUnary, int(operator))
+		return nil, nil
+	}
+
+	// 自增自减: a = a + 1, 后缀形式返回修改前的值; 目标为属性或下标时对象与下标只求值一次
+	binaryOperator := lexer.PLUS
+	if operator == lexer.MINUSMINUS {
+		binaryOperator = lexer.MINUS
+	}
+	// 读取当前值, operands为当前值之下保留的对象与下标的个数
+	operands := 0
+	switch target := expr.Right.(type) {
+	case *parser.Variable:
+		c.setLine(target.Name)
+		c.getVariable(target, target.Name.GetValue())
+	case *parser.Get:
+		c.expression(target.Instance)
+		c.setLine(target.Name)
+		c.emitOpByte(OpDup, 1)
+		c.emitOpShort(OpGetProperty, c.identifierConstant(target.Name.GetValue()))
+		operands = 1
+	case *parser.Index:
+		c.expression(target.Object)
+		c.expression(target.Index)
+		c.setLine(target.Bracket)
+		c.emitOpByte(OpDup, 2)
+		c.emitOp(OpIndex)
+		operands = 2
+	default:
+		c.error("Invalid increment target.")
+		return nil, nil
+	}
+	if !expr.Prefix {
+		// 将修改前的值复制到对象与下标之下作为结果
+		c.emitOpByte(OpDup, 1)
+		c.emitOpByte(OpBury, operands+1)
+	}
+	c.setLine(expr.Operator)
+	c.emitConstant(1)
+	c.emitOpByte(OpBinary, int(binaryOperator))
+	switch target := expr.Right.(type) {
+	case *parser.Variable:
+		c.setLine(target.Name)
+		c.setVariable(target, target.Name.GetValue())
+	case *parser.Get:
+		c.setLine(target.Name)
+		c.emitOpShort(OpSetProperty, c.identifierConstant(target.Name.GetValue()))
+	case *parser.Index:
+		c.setLine(target.Bracket)
+		c.emitOp(OpSetIndex)
+	}
+	if !expr.Prefix {
+		c.emitOp(OpPop)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitVariableExpr(expr *parser.Variable) (interface{}, error) {
+	c.setLine(expr.Name)
+	c.getVariable(expr, expr.Name.GetValue())
+	return nil, nil
+}
+
+func (c *Compiler) VisitArrayExpr(expr *parser.Array) (interface{}, error) {
+	if hasSpread(expr.Elements) {
+		c.spreadElements(expr.Elements)
+		return nil, nil
+	}
+	for _, element := range expr.Elements {
+		c.expression(element)
+	}
+	c.setLine(expr.Token)
+	c.emitOpShort(OpArray, len(expr.Elements))
+	return nil, nil
+}
+
+func (c *Compiler) VisitIndexExpr(expr *parser.Index) (interface{}, error) {
+	c.expression(expr.Object)
+	c.expression(expr.Index)
+	c.setLine(expr.Bracket)
+	c.emitOp(OpIndex)
+	return nil, nil
+}
+
+func (c *Compiler) VisitIndexSetExpr(expr *parser.IndexSet) (interface{}, error) {
+	c.expression(expr.Object)
+	c.expression(expr.Index)
+	if expr.Operator != nil {
+		c.setLine(expr.Bracket)
+		c.emitOpByte(OpDup, 2)
+		c.emitOp(OpIndex)
+	}
+	c.expression(expr.Value)
+	c.compoundOperator(expr.Operator)
+	c.setLine(expr.Bracket)
+	c.emitOp(OpSetIndex)
+	return nil, nil
+}
+
+func (c *Compiler) VisitMapExpr(expr *parser.Map) (interface{}, error) {
+	for n, key := range expr.Keys {
+		c.expression(key)
+		c.expression(expr.Values[n])
+	}
+	c.setLine(expr.Brace)
+	c.emitOpShort(OpMap, len(expr.Keys))
+	return nil, nil
+}
+
+func (c *Compiler) VisitLambdaExpr(expr *parser.Lambda) (interface{}, error) {
+	c.setLine(expr.Token)
+	if function, ok := expr.Function.(*parser.Function); ok {
+		c.compileFunction(function, FunctionTypeFunction)
+	} else {
+		c.error("Invalid lambda.")
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitStringifyExpr(expr *parser.Stringify) (interface{}, error) {
+	c.expression(expr.Value)
+	c.emitOp(OpStringify)
+	return nil, nil
+}
+
+// 展开运算只能出现在实参与数组字面量中, 由spreadElements处理
+func (c *Compiler) VisitSpreadExpr(expr *parser.Spread) (interface{}, error) {
+	c.setLine(expr.Token)
+	c.error("Can only spread in arguments and array literals.")
+	return nil, nil
+}
+
+func (c *Compiler) VisitSuperExpr(expr *parser.Super) (interface{}, error) {
+	c.setLine(expr.Keyword)
+	c.getVariable(expr, "this")
+	c.getVariable(expr, "super")
+	c.emitOpShort(OpGetSuper, c.identifierConstant(expr.Method.GetValue()))
+	return nil, nil
+}
+
+func (c *Compiler) VisitBlockStmt(stmt *parser.Block) (interface{}, error) {
+	c.beginScope()
+	for _, statement := range stmt.Statements {
+		c.statement(statement)
+	}
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Compiler) VisitClassStmt(stmt *parser.Class) (interface{}, error) {
+	c.setLine(stmt.Name)
+	className := stmt.Name.GetValue()
+	c.emitOpShort(OpClass, c.identifierConstant(className))
+	c.defineVariable(className)
+
+	class := &classState{enclosing: c.class}
+	c.class = class
+	defer func() {
+		c.class = class.enclosing
+	}()
+
+	if stmt.Superclass != nil {
+		c.VisitVariableExpr(stmt.Superclass)
+		// 父类作为名为super的局部变量供方法捕获
+		c.beginScope()
+		c.addLocal("super")
+		c.getVariable(nil, className)
+		c.emitOp(OpInherit)
+		class.hasSuperclass = true
+	}
+
+	c.getVariable(nil, className)
+	for _, method := range stmt.Methods {
+		if function, ok := method.(*parser.Function); !ok {
+			c.error("Invalid method")
+		} else {
+			functionType := FunctionTypeMethod
+			if function.Name.GetValue() == "init" {
+				functionType = FunctionTypeInitializer
+			}
+			c.compileFunction(function, functionType)
+			c.emitOpShort(OpMethod, c.identifierConstant(function.Name.GetValue()))
+		}
+	}
+	for _, method := range stmt.ClassMethods {
+		if function, ok := method.(*parser.Function); !ok {
+			c.error("Invalid class method")
+		} else {
+			c.compileFunction(function, FunctionTypeMethod)
+			c.emitOpShort(OpClassMethod, c.identifierConstant(function.Name.GetValue()))
+		}
+	}
+	c.emitOp(OpPop)
+
+	if class.hasSuperclass {
+		c.endScope()
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitExpressionStmt(stmt *parser.Expression) (interface{}, error) {
+	c.expression(stmt.Expr)
+	c.emitOp(OpPop)
+	return nil, nil
+}
+
+func (c *Compiler) VisitFunctionStmt(stmt *parser.Function) (interface{}, error) {
+	c.setLine(stmt.Name)
+	name := stmt.Name.GetValue()
+	// 局部函数先声明, 以便递归调用
+	if c.scopeDepth > 0 {
+		c.addLocal(name)
+		c.compileFunction(stmt, FunctionTypeFunction)
+		return nil, nil
+	}
+	c.compileFunction(stmt, FunctionTypeFunction)
+	c.defineVariable(name)
+	return nil, nil
+}
+
+func (c *Compiler) VisitIfStmt(stmt *parser.If) (interface{}, error) {
+	c.expression(stmt.Condition)
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.statement(stmt.ThenBranch)
+	elseJump := c.emitJump(OpJump)
+
+	c.patchJump(thenJump)
+	c.emitOp(OpPop)
+	c.statement(stmt.ElseBranch)
+	c.patchJump(elseJump)
+	return nil, nil
+}
+
+func (c *Compiler) VisitPrintStmt(stmt *parser.Print) (interface{}, error) {
+	c.expression(stmt.Expr)
+	c.emitOp(OpPrint)
+	return nil, nil
+}
+
+func (c *Compiler) VisitReturnStmt(stmt *parser.Return) (interface{}, error) {
+	c.setLine(stmt.Keyword)
+	if c.functionType == FunctionTypeScript {
+		c.error("Can't return from top-level code.")
+	}
+	if stmt.Value == nil {
+		c.emitReturnValue()
+	} else {
+		if c.functionType == FunctionTypeInitializer {
+			c.error("Can't return a value from an initializer.")
+		}
+		c.expression(stmt.Value)
+	}
+	if c.try != nil {
+		// 返回值位于栈顶, 执行finally时视为局部变量
+		c.addLocal(" return")
+		c.unwindTries(nil)
+		c.locals = c.locals[:len(c.locals)-1]
+	}
+	c.emitOp(OpReturn)
+	return nil, nil
+}
+
+func (c *Compiler) VisitVarStmt(stmt *parser.Var) (interface{}, error) {
+	for n, name := range stmt.Names {
+		c.setLine(name)
+		if stmt.Initializers[n] == nil {
+			c.emitConstant(emptyVariable(name.GetValue()))
+		} else {
+			c.expression(stmt.Initializers[n])
+		}
+		c.defineVariable(name.GetValue())
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitWhileStmt(stmt *parser.While) (interface{}, error) {
+	loop := &loopState{
+		enclosing:  c.loop,
+		scopeDepth: c.scopeDepth,
+	}
+	c.loop = loop
+	defer func() {
+		c.loop = loop.enclosing
+	}()
+
+	loopStart := len(c.chunk().Code)
+	c.expression(stmt.Condition)
+	exitJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.statement(stmt.Body)
+
+	// continue跳转到自增表达式
+	for _, offset := range loop.continues {
+		c.patchJump(offset)
+	}
+	if stmt.Increment != nil {
+		c.expression(stmt.Increment)
+		c.emitOp(OpPop)
+	}
+	c.emitLoop(loopStart)
+
+	c.patchJump(exitJump)
+	c.emitOp(OpPop)
+	for _, offset := range loop.breaks {
+		c.patchJump(offset)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitForInStmt(stmt *parser.ForIn) (interface{}, error) {
+	c.expression(stmt.Iterable)
+	c.setLine(stmt.In)
+	c.emitOp(OpIter)
+	// 迭代器保存在名字无法被引用的局部变量中
+	c.beginScope()
+	c.addLocal(" iterator")
+
+	loop := &loopState{
+		enclosing:  c.loop,
+		scopeDepth: c.scopeDepth,
+	}
+	c.loop = loop
+	defer func() {
+		c.loop = loop.enclosing
+	}()
+
+	loopStart := len(c.chunk().Code)
+	exitJump := c.emitJump(OpForIter)
+	c.beginScope()
+	c.addLocal(stmt.Name.GetValue())
+	c.statement(stmt.Body)
+	c.endScope()
+
+	// continue时循环变量已被弹出, 直接跳回循环开始
+	for _, offset := range loop.continues {
+		c.patchJump(offset)
+	}
+	c.emitLoop(loopStart)
+
+	c.patchJump(exitJump)
+	for _, offset := range loop.breaks {
+		c.patchJump(offset)
+	}
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Compiler) VisitTryStmt(stmt *parser.Try) (interface{}, error) {
+	c.setLine(stmt.Keyword)
+	t := &tryState{
+		enclosing: c.try,
+		loop:      c.loop,
+		locals:    len(c.locals),
+		finally:   stmt.FinallyBody,
+	}
+	exitJumps := make([]int, 0, 2)
+
+	catchJump := c.emitJump(OpTry)
+	c.try = t
+	c.statement(stmt.Body)
+	c.try = t.enclosing
+	c.emitOp(OpEndTry)
+	c.compileFinally(t)
+	exitJumps = append(exitJumps, c.emitJump(OpJump))
+
+	// 捕获错误后, 错误值位于栈顶
+	c.patchJump(catchJump)
+	c.beginScope()
+	if stmt.CatchBody == nil {
+		c.addLocal(" error")
+		c.rethrow(t, 1)
+	} else {
+		name := ""
+		if stmt.CatchName != nil {
+			name = stmt.CatchName.GetValue()
+		}
+		c.addLocal(name)
+		if stmt.FinallyBody == nil {
+			c.statement(stmt.CatchBody)
+			c.endScope()
+		} else {
+			// catch中的错误也需要先执行finally
+			rethrowJump := c.emitJump(OpTry)
+			c.try = t
+			c.statement(stmt.CatchBody)
+			c.try = t.enclosing
+			c.emitOp(OpEndTry)
+			c.endScope()
+			c.compileFinally(t)
+			exitJumps = append(exitJumps, c.emitJump(OpJump))
+
+			c.patchJump(rethrowJump)
+			c.beginScope()
+			c.addLocal("")
+			c.addLocal(" error")
+			c.rethrow(t, 2)
+		}
+	}
+
+	for _, offset := range exitJumps {
+		c.patchJump(offset)
+	}
+	return nil, nil
+}
+
+// 执行finally后重新抛出栈顶的错误, 之后的代码不可达, 因此只在编译期移除局部变量
+func (c *Compiler) rethrow(t *tryState, locals int) {
+	c.compileFinally(t)
+	c.emitOp(OpThrow)
+	c.locals = c.locals[:len(c.locals)-locals]
+	c.scopeDepth--
+}
+
+func (c *Compiler) compileFinally(t *tryState) {
+	if t.finally == nil {
+		return
+	}
+	// finally中不能访问try内部的局部变量, 这些变量仍在栈上, 只隐藏其名字
+	names := make([]string, 0, len(c.locals)-t.locals)
+	for n := t.locals; n < len(c.locals); n++ {
+		names = append(names, c.locals[n].name)
+		c.locals[n].name = ""
+	}
+	enclosing := c.try
+	c.try = t.enclosing
+	c.statement(t.finally)
+	c.try = enclosing
+	for n, name := range names {
+		c.locals[t.locals+n].name = name
+	}
+}
+
+// 跳出try语句前弹出异常处理器并执行finally, loop不为nil时只处理该循环内的try语句
+func (c *Compiler) unwindTries(loop *loopState) {
+	for t := c.try; t != nil; t = t.enclosing {
+		if loop != nil && t.loop != loop {
+			break
+		}
+		c.emitOp(OpEndTry)
+		c.compileFinally(t)
+	}
+}
+
+func (c *Compiler) VisitImportStmt(stmt *parser.Import) (interface{}, error) {
+	c.setLine(stmt.Path)
+	c.emitOpShort(OpImport, c.makeConstant(stmt.Path.GetLiteral()))
+	c.emitShort(c.makeConstant(stmt.Path.GetSource().GetName()))
+	c.defineVariable(stmt.Name.GetValue())
+	return nil, nil
+}
+
+func (c *Compiler) VisitExportStmt(stmt *parser.Export) (interface{}, error) {
+	c.statement(stmt.Declaration)
+	return nil, nil
+}
+
+func (c *Compiler) VisitThrowStmt(stmt *parser.Throw) (interface{}, error) {
+	c.expression(stmt.Value)
+	c.setLine(stmt.Keyword)
+	c.emitOp(OpThrow)
+	return nil, nil
+}
+
+func (c *Compiler) VisitBreakStmt(stmt *parser.Break) (interface{}, error) {
+	c.setLine(stmt.Keyword)
+	if c.loop == nil {
+		c.error("Can't use 'break' outside of a loop.")
+		return nil, nil
+	}
+	c.unwindTries(c.loop)
+	c.discardLocals(c.loop.scopeDepth)
+	c.loop.breaks = append(c.loop.breaks, c.emitJump(OpJump))
+	return nil, nil
+}
+
+func (c *Compiler) VisitContinueStmt(stmt *parser.Continue) (interface{}, error) {
+	c.setLine(stmt.Keyword)
+	if c.loop == nil {
+		c.error("Can't use 'continue' outside of a loop.")
+		return nil, nil
+	}
+	c.unwindTries(c.loop)
+	c.discardLocals(c.loop.scopeDepth)
+	c.loop.continues = append(c.loop.continues, c.emitJump(OpJump))
+	return nil, nil
+}
@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/WAY29/LoxGo/lexer"
+)
+
+type CompileError struct {
+	line     int
+	token    *lexer.Token
+	extraMsg string
+}
+
+func newCompileError(line int, token *lexer.Token, format string, a ...interface{}) *CompileError {
+	return &CompileError{
+		line:     line,
+		token:    token,
+		extraMsg: fmt.Sprintf(format, a...),
+	}
+}
+
+func (e *CompileError) Error() string {
+	if e.token != nil {
+		return lexer.FormatError(e.token.GetSource(), e.token.GetStart(), e.token.GetEnd(), "Compile error: "+e.extraMsg)
+	}
+	return fmt.Sprintf("Compile error in line %d: %s", e.line, e.extraMsg)
+}
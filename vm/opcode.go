@@ -0,0 +1,54 @@
+package vm
+
+type OpCode byte
+
+const (
+	OpConstant       OpCode = iota // u16 常量下标
+	OpNil                          //
+	OpTrue                         //
+	OpFalse                        //
+	OpPop                          //
+	OpDup                          // u8 复制栈顶的n个值
+	OpBury                         // u8 将栈顶的值移到其下n个值之下
+	OpGetLocal                     // u8 栈槽
+	OpSetLocal                     // u8 栈槽
+	OpGetGlobal                    // u16 变量名常量
+	OpDefineGlobal                 // u16 变量名常量
+	OpSetGlobal                    // u16 变量名常量
+	OpGetUpvalue                   // u8 upvalue下标
+	OpSetUpvalue                   // u8 upvalue下标
+	OpGetProperty                  // u16 属性名常量
+	OpSetProperty                  // u16 属性名常量
+	OpGetSuper                     // u16 方法名常量
+	OpIndex                        //
+	OpSetIndex                     //
+	OpUnary                        // u8 运算符(lexer.TokenType)
+	OpBinary                       // u8 运算符(lexer.TokenType)
+	OpStringify                    //
+	OpPrint                        //
+	OpJump                         // u16 向前跳转的偏移
+	OpJumpIfFalse                  // u16 向前跳转的偏移
+	OpJumpIfArgument               // u8 参数下标, u16 调用时传入了该参数则向前跳转的偏移
+	OpLoop                         // u16 向后跳转的偏移
+	OpIter                         //
+	OpForIter                      // u16 迭代结束时向前跳转的偏移
+	OpTry                          // u16 catch相对当前位置的偏移
+	OpEndTry                       //
+	OpThrow                        //
+	OpCall                         // u8 参数个数
+	OpCallArray                    // 以栈顶数组的元素作为参数调用
+	OpInvoke                       // u16 方法名常量, u8 参数个数
+	OpSuperInvoke                  // u16 方法名常量, u8 参数个数
+	OpClosure                      // u16 函数常量, 之后每个upvalue各有 u8 isLocal, u8 下标
+	OpCloseUpvalue                 //
+	OpReturn                       //
+	OpClass                        // u16 类名常量
+	OpInherit                      //
+	OpMethod                       // u16 方法名常量
+	OpClassMethod                  // u16 方法名常量
+	OpArray                        // u16 元素个数
+	OpConcat                       // u16 合并栈顶的n个数组
+	OpMap                          // u16 键值对个数
+	OpImport                       // u16 模块路径常量, u16 导入模块的文件名常量
+	OpResult                       // u16 顶层语句下标
+)
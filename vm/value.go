@@ -0,0 +1,212 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/WAY29/LoxGo/interpreter"
+)
+
+type Function struct {
+	arity        int  // 必需参数个数
+	optional     int  // 有默认值的参数个数
+	rest         bool // 是否有剩余参数
+	upvalueCount int
+	chunk        *Chunk
+	name         string
+	script       bool // 脚本或模块的顶层代码, 不出现在调用栈中
+}
+
+func newFunction(name string) *Function {
+	return &Function{
+		chunk: NewChunk(),
+		name:  name,
+	}
+}
+
+func (f *Function) String() string {
+	if f.name == "" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", f.name)
+}
+
+// 参数个数的范围, 有剩余参数时没有上限
+func (f *Function) arityRange() (int, int) {
+	if f.rest {
+		return f.arity, -1
+	}
+	return f.arity, f.arity + f.optional
+}
+
+// 参数个数不固定时返回-1
+func arity(min, max int) int {
+	if min == max {
+		return min
+	}
+	return -1
+}
+
+// 没有初始值的变量在赋值前的值, 读取时报错, 与值为nil的变量区分
+type emptyVariable string
+
+// 宿主与其他模块读取到的变量值, 没有初始值的变量为nil
+func variableValue(value interface{}) interface{} {
+	if _, ok := value.(emptyVariable); ok {
+		return nil
+	}
+	return value
+}
+
+type Upvalue struct {
+	location int // 仍在栈上时为栈下标, 关闭后为-1
+	closed   interface{}
+	next     *Upvalue
+}
+
+type Closure struct {
+	function *Function
+	upvalues []*Upvalue
+	globals  map[string]interface{} // 函数所在模块的全局变量
+}
+
+func newClosure(function *Function) *Closure {
+	return &Closure{
+		function: function,
+		upvalues: make([]*Upvalue, function.upvalueCount),
+	}
+}
+
+func (c *Closure) String() string {
+	return fmt.Sprintf("<fn %s>", c.function.name)
+}
+
+// Closure, Class, BoundMethod与Native实现interpreter.Callable, 可以作为ArgCallable参数传给内置函数
+func (c *Closure) Arity() int {
+	return arity(c.ArityRange())
+}
+
+func (c *Closure) ArityRange() (int, int) {
+	return c.function.arityRange()
+}
+
+func (c *Closure) Name() string {
+	return c.function.name
+}
+
+type Native struct {
+	builtin *interpreter.LoxBuiltinFunc
+}
+
+func (n *Native) String() string {
+	return n.builtin.String()
+}
+
+func (n *Native) Arity() int {
+	return n.builtin.Arity()
+}
+
+func (n *Native) Name() string {
+	return n.builtin.Name()
+}
+
+type Class struct {
+	name         string
+	superclass   *Class
+	methods      map[string]*Closure
+	classMethods map[string]*Closure
+	fields       map[string]interface{}
+}
+
+func newClass(name string) *Class {
+	return &Class{
+		name:         name,
+		methods:      make(map[string]*Closure),
+		classMethods: make(map[string]*Closure),
+		fields:       make(map[string]interface{}),
+	}
+}
+
+func (c *Class) String() string {
+	return c.name
+}
+
+func (c *Class) Arity() int {
+	return arity(c.ArityRange())
+}
+
+// 参数个数与init方法相同
+func (c *Class) ArityRange() (int, int) {
+	if initializer, ok := c.methods["init"]; ok {
+		return initializer.ArityRange()
+	}
+	return 0, 0
+}
+
+func (c *Class) Name() string {
+	return c.name
+}
+
+func (c *Class) getField(name string) (interface{}, bool) {
+	for class := c; class != nil; class = class.superclass {
+		if value, ok := class.fields[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+type Instance struct {
+	class  *Class
+	fields map[string]interface{}
+}
+
+func newInstance(class *Class) *Instance {
+	return &Instance{
+		class:  class,
+		fields: make(map[string]interface{}),
+	}
+}
+
+func (i *Instance) String() string {
+	return i.class.name + " instance"
+}
+
+type BoundMethod struct {
+	receiver interface{}
+	method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.method.String()
+}
+
+func (b *BoundMethod) Arity() int {
+	return b.method.Arity()
+}
+
+func (b *BoundMethod) ArityRange() (int, int) {
+	return b.method.ArityRange()
+}
+
+func (b *BoundMethod) Name() string {
+	return b.method.Name()
+}
+
+func isFalsey(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if v, ok := value.(bool); ok {
+		return !v
+	}
+	return false
+}
+
+// 虚拟机内部的值不通过反射访问
+func isVMObject(value interface{}) bool {
+	switch value.(type) {
+	case *Function, *Closure, *Native, *BoundMethod, *Upvalue:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,974 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/WAY29/LoxGo/interpreter"
+	"github.com/WAY29/LoxGo/lexer"
+	"github.com/WAY29/LoxGo/parser"
+)
+
+const (
+	framesMax = 8192
+	stackInit = 256
+)
+
+type CallFrame struct {
+	closure  *Closure
+	ip       int
+	slots    int
+	argCount int // 调用时实际传入的参数个数
+}
+
+// try语句注册的异常处理器
+type handler struct {
+	frames int // 注册时的栈帧个数
+	stack  int // 注册时的栈大小
+	ip     int // catch代码的位置
+}
+
+// 正在执行的Go函数, 用于在调用栈中显示
+type nativeCall struct {
+	name   string
+	frames int // 调用时的栈帧个数, 之上的栈帧由该函数回调产生
+}
+
+type VM struct {
+	frames       []CallFrame
+	natives      []nativeCall
+	handlers     []handler
+	stack        []interface{}
+	builtins     map[string]interface{} // 所有模块共享的内置函数与宿主定义的变量
+	globals      map[string]interface{}
+	modules      *interpreter.ModuleLoader
+	openUpvalues *Upvalue
+	results      []interface{}
+
+	out io.Writer
+}
+
+func NewVM() *VM {
+	vm := &VM{
+		frames:   make([]CallFrame, 0, 64),
+		stack:    make([]interface{}, 0, stackInit),
+		builtins: make(map[string]interface{}),
+		globals:  make(map[string]interface{}),
+		modules:  interpreter.NewModuleLoader(),
+		out:      os.Stdout,
+	}
+	for _, builtin := range interpreter.NewBuiltinFuncs() {
+		vm.builtins[builtin.Name()] = &Native{builtin: builtin}
+	}
+	return vm
+}
+
+func (vm *VM) Interpret(function *Function) (results []interface{}, err error) {
+	defer vm.recoverError(&err)
+	vm.resetStack()
+
+	closure := newClosure(function)
+	closure.globals = vm.globals
+	vm.push(closure)
+	if err := vm.call(closure, 0); err != nil {
+		return nil, err
+	}
+	if err := vm.run(0); err != nil {
+		vm.resetStack()
+		return nil, err
+	}
+	return vm.results, nil
+}
+
+// 设置print语句的输出位置
+func (vm *VM) SetOutput(out io.Writer) {
+	vm.out = out
+}
+
+// 设置模块搜索路径
+func (vm *VM) SetModulePath(paths ...string) {
+	vm.modules.SetPath(paths...)
+}
+
+// 开始执行入口脚本, 返回结束执行时调用的函数
+func (vm *VM) EnterScript(name string) func() {
+	return vm.modules.Enter(name)
+}
+
+// 定义全局变量, 对所有模块可见, 内置函数会被包装为Native
+func (vm *VM) Define(name string, value interface{}) {
+	value = interpreter.FromGoGlobal(name, value)
+	if builtin, ok := value.(*interpreter.LoxBuiltinFunc); ok {
+		value = &Native{builtin: builtin}
+	}
+	vm.builtins[name] = value
+}
+
+// 获取全局变量
+func (vm *VM) Get(name string) (interface{}, bool) {
+	if value, ok := vm.globals[name]; ok {
+		return variableValue(value), true
+	}
+	value, ok := vm.builtins[name]
+	return value, ok
+}
+
+// 在单独的全局变量表中执行模块
+func (vm *VM) evalModule(path string, r io.Reader) (*interpreter.LoxModule, error) {
+	statements, err := parser.ParseSource(path, r)
+	if err != nil {
+		return nil, err
+	}
+	compiler := NewCompiler()
+	if err = interpreter.NewResolver(compiler).ResolveStmts(statements); err != nil {
+		return nil, err
+	}
+	function, err := compiler.CompileModule(statements)
+	if err != nil {
+		return nil, err
+	}
+
+	globals := make(map[string]interface{})
+	closure := newClosure(function)
+	closure.globals = globals
+	if _, err = vm.Call(closure); err != nil {
+		return nil, err
+	}
+	return interpreter.NewLoxModule(path, interpreter.ExportedNames(statements), func(name string) (interface{}, bool) {
+		value, ok := globals[name]
+		return variableValue(value), ok
+	}), nil
+}
+
+// 从Go中调用Lox函数或类
+func (vm *VM) Call(callee interface{}, arguments ...interface{}) (result interface{}, err error) {
+	defer vm.recoverError(&err)
+	base := len(vm.frames)
+	stackTop := len(vm.stack)
+	handlers := len(vm.handlers)
+	vm.push(callee)
+	for _, argument := range arguments {
+		vm.push(argument)
+	}
+	if err := vm.callValue(callee, len(arguments)); err != nil {
+		vm.stack = vm.stack[:stackTop]
+		return nil, err
+	}
+	// 调用Lox函数时会产生新的栈帧, 执行到该栈帧返回为止
+	if len(vm.frames) > base {
+		if err := vm.run(base); err != nil {
+			// 恢复到调用前的状态, 使外层的try语句能够继续处理错误
+			vm.closeUpvalues(stackTop)
+			vm.frames = vm.frames[:base]
+			vm.stack = vm.stack[:stackTop]
+			vm.handlers = vm.handlers[:handlers]
+			return nil, err
+		}
+	}
+	return vm.pop(), nil
+}
+
+// 兜底处理执行过程中的panic, 避免脚本错误导致宿主进程崩溃
+func (vm *VM) recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = vm.runtimeError("Internal error: %v", r)
+		vm.resetStack()
+	}
+}
+
+func (vm *VM) resetStack() {
+	vm.stack = vm.stack[:0]
+	vm.frames = vm.frames[:0]
+	vm.natives = vm.natives[:0]
+	vm.handlers = vm.handlers[:0]
+	vm.openUpvalues = nil
+	vm.results = make([]interface{}, 0)
+}
+
+func (vm *VM) push(value interface{}) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() interface{} {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+// 压入读取到的变量值, 变量没有初始值时报错
+func (vm *VM) pushVariable(value interface{}) error {
+	if name, ok := value.(emptyVariable); ok {
+		return vm.runtimeError("Access empty variable '%s'.", string(name))
+	}
+	vm.push(value)
+	return nil
+}
+
+func (vm *VM) peek(distance int) interface{} {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// 运行时错误与解释器使用相同的类型, 位置为当前执行的指令
+func (vm *VM) runtimeError(format string, a ...interface{}) error {
+	return vm.locate(interpreter.NewRuntimeError(nil, format, a...))
+}
+
+// 附加当前指令的位置与调用栈, 已有的信息保持不变
+func (vm *VM) locate(err *interpreter.RuntimeError) *interpreter.RuntimeError {
+	return err.Locate(vm.currentToken(), vm.trace)
+}
+
+// 当前执行的指令对应的token
+func (vm *VM) currentToken() *lexer.Token {
+	if len(vm.frames) == 0 {
+		return nil
+	}
+	frame := &vm.frames[len(vm.frames)-1]
+	return frame.closure.function.chunk.GetToken(frame.ip - 1)
+}
+
+// 由内向外的调用栈, 不包括脚本与模块的顶层代码
+func (vm *VM) trace() []interpreter.TraceFrame {
+	trace := make([]interpreter.TraceFrame, 0, len(vm.frames)+len(vm.natives))
+	native := len(vm.natives) - 1
+	for n := len(vm.frames); n >= 0; n-- {
+		// Go函数回调的栈帧没有调用位置
+		call := vm.callSite(n - 1)
+		if native >= 0 && vm.natives[native].frames == n {
+			call = nil
+		}
+		if n < len(vm.frames) {
+			if function := vm.frames[n].closure.function; !function.script {
+				trace = append(trace, interpreter.TraceFrame{Function: function.name, Call: call})
+			}
+		}
+		for ; native >= 0 && vm.natives[native].frames == n; native-- {
+			trace = append(trace, interpreter.TraceFrame{Function: vm.natives[native].name, Call: vm.callSite(n - 1)})
+		}
+	}
+	return trace
+}
+
+// 栈帧中正在执行的调用, 由Go发起调用时为nil
+func (vm *VM) callSite(n int) *parser.Call {
+	if n < 0 {
+		return nil
+	}
+	frame := &vm.frames[n]
+	return frame.closure.function.chunk.GetCall(frame.ip - 1)
+}
+
+// 方法调用中属性查找失败的错误, 位置指向方法名而不是右括号
+func (vm *VM) propertyError(err *interpreter.RuntimeError) error {
+	var name *lexer.Token
+	if call := vm.callSite(len(vm.frames) - 1); call != nil {
+		switch callee := call.Callee.(type) {
+		case *parser.Get:
+			name = callee.Name
+		case *parser.Super:
+			name = callee.Method
+		}
+	}
+	if name == nil {
+		return vm.locate(err)
+	}
+	return err.Locate(name, vm.trace)
+}
+
+// 抛出脚本中的值, 重新抛出捕获到的错误时保留其原有的信息
+func (vm *VM) throw(value interface{}) error {
+	return vm.locate(interpreter.NewThrowError(nil, value))
+}
+
+// 其他错误附加当前位置, 已经定位的错误保持不变
+func (vm *VM) wrapError(err error) error {
+	switch e := err.(type) {
+	case *interpreter.RuntimeError:
+		return vm.locate(e)
+	case *interpreter.ConvertError:
+		return vm.runtimeError("%s", e.Message())
+	}
+	return vm.runtimeError("%s", err.Error())
+}
+
+// 获取for-in循环的迭代器, 实例的迭代协议与解释器一致
+func (vm *VM) iterator(value interface{}) (interpreter.Iterator, error) {
+	instance, ok := value.(*Instance)
+	if !ok {
+		iterator, err := interpreter.NewIterator(value)
+		if err != nil {
+			return nil, vm.wrapError(err)
+		}
+		return iterator, nil
+	}
+
+	iter, err := vm.iteratorMethod(instance, "iter")
+	if err != nil {
+		return nil, err
+	}
+	if iter != nil {
+		result, err := vm.Call(iter)
+		if err != nil {
+			return nil, err
+		}
+		if result != value {
+			return vm.iterator(result)
+		}
+	}
+	next, err := vm.iteratorMethod(instance, "next")
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, vm.runtimeError("Can't iterate over %v, it has no 'iter' or 'next' method.", instance)
+	}
+	return interpreter.IteratorFunc(func() (interface{}, bool, error) {
+		result, err := vm.Call(next)
+		return result, result != nil && err == nil, err
+	}), nil
+}
+
+func (vm *VM) iteratorMethod(instance *Instance, name string) (*BoundMethod, error) {
+	method, ok := instance.class.methods[name]
+	if !ok {
+		return nil, nil
+	}
+	if method.function.arity != 0 || method.function.rest {
+		return nil, vm.runtimeError("Iterator method '%s' must take no arguments.", name)
+	}
+	return &BoundMethod{receiver: instance, method: method}, nil
+}
+
+// 解释器产生的错误只取其内容, 位置由虚拟机的行号表给出
+func errorMessage(err error) string {
+	switch e := err.(type) {
+	case *interpreter.RuntimeError:
+		return e.Message()
+	case *interpreter.ConvertError:
+		return e.Message()
+	}
+	return err.Error()
+}
+
+func (vm *VM) call(closure *Closure, argCount int) error {
+	function := closure.function
+	params := function.arity + function.optional
+	min, max := function.arityRange()
+	if err := interpreter.CheckArityRange(nil, min, max, argCount); err != nil {
+		return vm.runtimeError("%s", errorMessage(err))
+	}
+	if len(vm.frames) >= framesMax {
+		return vm.runtimeError("Stack oversize: Can't have more than %d stack.", framesMax)
+	}
+	slots := len(vm.stack) - argCount - 1
+	// 没有传入的可选参数先置为nil, 由函数开头的代码求默认值; 多余的参数收集为剩余参数数组
+	for n := argCount; n < params; n++ {
+		vm.push(nil)
+	}
+	if function.rest {
+		rest := make([]interface{}, 0)
+		if argCount > params {
+			rest = append(rest, vm.stack[slots+1+params:]...)
+			vm.stack = vm.stack[:slots+1+params]
+		}
+		vm.push(rest)
+	}
+	vm.frames = append(vm.frames, CallFrame{
+		closure:  closure,
+		ip:       0,
+		slots:    slots,
+		argCount: argCount,
+	})
+	return nil
+}
+
+func (vm *VM) callValue(callee interface{}, argCount int) error {
+	switch callee := callee.(type) {
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.receiver
+		return vm.call(callee.method, argCount)
+	case *Class:
+		vm.stack[len(vm.stack)-argCount-1] = newInstance(callee)
+		if initializer, ok := callee.methods["init"]; ok {
+			return vm.call(initializer, argCount)
+		} else if argCount != 0 {
+			return vm.runtimeError("Excepted 0 arguments but got %d.", argCount)
+		}
+		return nil
+	case *Closure:
+		return vm.call(callee, argCount)
+	case *Native:
+		return vm.callNative(callee.builtin, argCount)
+	case interpreter.LoxCallable:
+		return vm.callNative(callee, argCount)
+	}
+	return vm.runtimeError("Can only call functions and classes.")
+}
+
+// 调用Go实现的函数, 结果直接压栈
+func (vm *VM) callNative(callee interpreter.LoxCallable, argCount int) error {
+	if err := interpreter.CheckArity(nil, callee, argCount); err != nil {
+		return vm.runtimeError("%s", errorMessage(err))
+	}
+	arguments := make([]interface{}, argCount)
+	copy(arguments, vm.stack[len(vm.stack)-argCount:])
+	var (
+		result interface{}
+		err    error
+	)
+	// 与解释器一致, Go函数在调用栈中显示
+	natives := len(vm.natives)
+	vm.natives = append(vm.natives, nativeCall{name: callee.Name(), frames: len(vm.frames)})
+	defer func() {
+		// 回调中的panic会清空vm的状态
+		if len(vm.natives) > natives {
+			vm.natives = vm.natives[:natives]
+		}
+	}()
+	// 内置函数可以通过vm回调Lox函数
+	if builtin, ok := callee.(*interpreter.LoxBuiltinFunc); ok {
+		result, err = builtin.CallWith(vm, arguments)
+	} else {
+		result, err = callee.Call(nil, arguments)
+	}
+	if err != nil {
+		// 回调的Lox函数中产生的错误已经带有位置与调用栈, 保持不变
+		e, ok := err.(*interpreter.RuntimeError)
+		if !ok || e.GetToken() == nil {
+			e = interpreter.NewRuntimeError(nil, "%s", errorMessage(err))
+		}
+		return vm.locate(e)
+	}
+	vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+	vm.push(result)
+	return nil
+}
+
+func (vm *VM) invokeFromClass(methods map[string]*Closure, name string, argCount int) error {
+	method, ok := methods[name]
+	if !ok {
+		return vm.propertyError(interpreter.NewRuntimeError(nil, "Undefined property '%s'.", name))
+	}
+	return vm.call(method, argCount)
+}
+
+func (vm *VM) invoke(name string, argCount int) error {
+	switch receiver := vm.peek(argCount).(type) {
+	case *Instance:
+		if value, ok := receiver.fields[name]; ok {
+			vm.stack[len(vm.stack)-argCount-1] = value
+			return vm.callValue(value, argCount)
+		}
+		return vm.invokeFromClass(receiver.class.methods, name, argCount)
+	case *Class:
+		if value, ok := receiver.getField(name); ok {
+			vm.stack[len(vm.stack)-argCount-1] = value
+			return vm.callValue(value, argCount)
+		}
+		return vm.invokeFromClass(receiver.classMethods, name, argCount)
+	case *interpreter.LoxModule:
+		value, err := receiver.Get(name)
+		if err != nil {
+			if runtimeErr, ok := err.(*interpreter.RuntimeError); ok {
+				return vm.propertyError(runtimeErr)
+			}
+			return vm.wrapError(err)
+		}
+		vm.stack[len(vm.stack)-argCount-1] = value
+		return vm.callValue(value, argCount)
+	}
+	if isVMObject(vm.peek(argCount)) {
+		return vm.propertyError(interpreter.NewRuntimeError(nil, "Only instances and classes have properties."))
+	}
+	value, err := interpreter.GetProperty(vm.peek(argCount), name)
+	if err != nil {
+		return vm.propertyError(interpreter.NewRuntimeError(nil, "%s", errorMessage(err)))
+	}
+	vm.stack[len(vm.stack)-argCount-1] = value
+	return vm.callValue(value, argCount)
+}
+
+func (vm *VM) bindMethod(methods map[string]*Closure, receiver interface{}, name string) error {
+	method, ok := methods[name]
+	if !ok {
+		return vm.runtimeError("Undefined property '%s'.", name)
+	}
+	vm.pop()
+	vm.push(&BoundMethod{receiver: receiver, method: method})
+	return nil
+}
+
+func (vm *VM) captureUpvalue(location int) *Upvalue {
+	var prev *Upvalue = nil
+	upvalue := vm.openUpvalues
+	for upvalue != nil && upvalue.location > location {
+		prev = upvalue
+		upvalue = upvalue.next
+	}
+	if upvalue != nil && upvalue.location == location {
+		return upvalue
+	}
+
+	created := &Upvalue{location: location, next: upvalue}
+	if prev == nil {
+		vm.openUpvalues = created
+	} else {
+		prev.next = created
+	}
+	return created
+}
+
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.location >= last {
+		upvalue := vm.openUpvalues
+		upvalue.closed = vm.stack[upvalue.location]
+		upvalue.location = -1
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+func (vm *VM) getUpvalue(upvalue *Upvalue) interface{} {
+	if upvalue.location >= 0 {
+		return vm.stack[upvalue.location]
+	}
+	return upvalue.closed
+}
+
+func (vm *VM) setUpvalue(upvalue *Upvalue, value interface{}) {
+	if upvalue.location >= 0 {
+		vm.stack[upvalue.location] = value
+	} else {
+		upvalue.closed = value
+	}
+}
+
+// 整数的快速路径, 溢出等其余情况交由解释器的运算语义处理
+func binaryInt(operator lexer.TokenType, a, b int) (interface{}, bool) {
+	switch operator {
+	case lexer.PLUS:
+		return interpreter.AddInt(a, b)
+	case lexer.MINUS:
+		return interpreter.SubInt(a, b)
+	case lexer.STAR:
+		return interpreter.MulInt(a, b)
+	case lexer.LESS:
+		return a < b, true
+	case lexer.LESS_EQUAL:
+		return a <= b, true
+	case lexer.GREATER:
+		return a > b, true
+	case lexer.GREATER_EQUAL:
+		return a >= b, true
+	case lexer.EQUAL_EQUAL:
+		return a == b, true
+	case lexer.BANG_EQUAL:
+		return a != b, true
+	}
+	return nil, false
+}
+
+// 执行到第base个栈帧返回为止, try语句中发生的错误跳转到对应的catch继续执行
+func (vm *VM) run(base int) error {
+	for {
+		err := vm.execute(base)
+		if err == nil || !vm.catch(err, base) {
+			return err
+		}
+	}
+}
+
+// 展开到最近的异常处理器并压入错误值, 处理器不属于本次run时返回false
+func (vm *VM) catch(err error, base int) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	if h.frames <= base {
+		return false
+	}
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.closeUpvalues(h.stack)
+	vm.frames = vm.frames[:h.frames]
+	vm.stack = vm.stack[:h.stack]
+	vm.frames[h.frames-1].ip = h.ip
+	vm.push(interpreter.ErrorValue(err))
+	return true
+}
+
+func (vm *VM) execute(base int) error {
+	frame := &vm.frames[len(vm.frames)-1]
+	code := frame.closure.function.chunk.Code
+	constants := frame.closure.function.chunk.Constants
+
+	readByte := func() byte {
+		frame.ip++
+		return code[frame.ip-1]
+	}
+	readShort := func() int {
+		frame.ip += 2
+		return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+	}
+	readString := func() string {
+		return constants[readShort()].(string)
+	}
+	// 调用与返回后切换到新的栈帧
+	loadFrame := func() {
+		frame = &vm.frames[len(vm.frames)-1]
+		code = frame.closure.function.chunk.Code
+		constants = frame.closure.function.chunk.Constants
+	}
+
+	for {
+		switch OpCode(readByte()) {
+		case OpConstant:
+			vm.push(constants[readShort()])
+		case OpNil:
+			vm.push(nil)
+		case OpTrue:
+			vm.push(true)
+		case OpFalse:
+			vm.push(false)
+		case OpPop:
+			vm.pop()
+		case OpDup:
+			n := int(readByte())
+			vm.stack = append(vm.stack, vm.stack[len(vm.stack)-n:]...)
+		case OpBury:
+			n := int(readByte())
+			top := len(vm.stack) - 1
+			value := vm.stack[top]
+			copy(vm.stack[top-n+1:], vm.stack[top-n:top])
+			vm.stack[top-n] = value
+		case OpGetLocal:
+			if err := vm.pushVariable(vm.stack[frame.slots+int(readByte())]); err != nil {
+				return err
+			}
+		case OpSetLocal:
+			vm.stack[frame.slots+int(readByte())] = vm.peek(0)
+		case OpGetGlobal:
+			name := readString()
+			value, ok := frame.closure.globals[name]
+			if !ok {
+				value, ok = vm.builtins[name]
+			}
+			if !ok {
+				return vm.runtimeError("Undefined variable '%s'.", name)
+			}
+			if err := vm.pushVariable(value); err != nil {
+				return err
+			}
+		case OpDefineGlobal:
+			frame.closure.globals[readString()] = vm.pop()
+		case OpSetGlobal:
+			name := readString()
+			if _, ok := frame.closure.globals[name]; ok {
+				frame.closure.globals[name] = vm.peek(0)
+			} else if _, ok := vm.builtins[name]; ok {
+				vm.builtins[name] = vm.peek(0)
+			} else {
+				return vm.runtimeError("Undefined variable '%s'.", name)
+			}
+		case OpGetUpvalue:
+			if err := vm.pushVariable(vm.getUpvalue(frame.closure.upvalues[readByte()])); err != nil {
+				return err
+			}
+		case OpSetUpvalue:
+			vm.setUpvalue(frame.closure.upvalues[readByte()], vm.peek(0))
+		case OpGetProperty:
+			name := readString()
+			switch object := vm.peek(0).(type) {
+			case *Instance:
+				if value, ok := object.fields[name]; ok {
+					vm.pop()
+					vm.push(value)
+				} else if err := vm.bindMethod(object.class.methods, object, name); err != nil {
+					return err
+				}
+			case *Class:
+				if value, ok := object.getField(name); ok {
+					vm.pop()
+					vm.push(value)
+				} else if method, ok := object.classMethods[name]; ok {
+					// 静态方法中的this指向类本身
+					vm.pop()
+					vm.push(&BoundMethod{receiver: object, method: method})
+				} else {
+					return vm.runtimeError("Undefined class property '%s'.", name)
+				}
+			case *interpreter.LoxModule:
+				value, err := object.Get(name)
+				if err != nil {
+					return vm.wrapError(err)
+				}
+				vm.pop()
+				vm.push(value)
+			default:
+				if isVMObject(object) {
+					return vm.runtimeError("Only instances and classes have properties.")
+				}
+				value, err := interpreter.GetProperty(object, name)
+				if err != nil {
+					return vm.runtimeError("%s", errorMessage(err))
+				}
+				vm.pop()
+				vm.push(value)
+			}
+		case OpSetProperty:
+			name := readString()
+			value := vm.pop()
+			switch object := vm.pop().(type) {
+			case *Instance:
+				object.fields[name] = value
+			case *Class:
+				object.fields[name] = value
+			default:
+				if isVMObject(object) {
+					return vm.runtimeError("Only instances and classes have fields.")
+				}
+				if err := interpreter.SetProperty(object, name, value); err != nil {
+					return vm.runtimeError("%s", errorMessage(err))
+				}
+			}
+			vm.push(value)
+		case OpGetSuper:
+			name := readString()
+			superclass := vm.pop().(*Class)
+			methods := superclass.methods
+			if _, ok := vm.peek(0).(*Class); ok {
+				methods = superclass.classMethods
+			}
+			if err := vm.bindMethod(methods, vm.peek(0), name); err != nil {
+				return err
+			}
+		case OpIndex:
+			index := vm.pop()
+			object := vm.pop()
+			result, err := interpreter.IndexOperate(object, index)
+			if err != nil {
+				return vm.runtimeError("%s", errorMessage(err))
+			}
+			vm.push(result)
+		case OpIter:
+			iterator, err := vm.iterator(vm.pop())
+			if err != nil {
+				return err
+			}
+			loadFrame()
+			vm.push(iterator)
+		case OpForIter:
+			offset := readShort()
+			value, ok, err := vm.peek(0).(interpreter.Iterator).Next()
+			if err != nil {
+				return vm.wrapError(err)
+			}
+			// 迭代器可能调用Lox方法使栈帧重新分配
+			loadFrame()
+			if ok {
+				vm.push(value)
+			} else {
+				frame.ip += offset
+			}
+		case OpTry:
+			offset := readShort()
+			vm.handlers = append(vm.handlers, handler{
+				frames: len(vm.frames),
+				stack:  len(vm.stack),
+				ip:     frame.ip + offset,
+			})
+		case OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OpThrow:
+			return vm.throw(vm.pop())
+		case OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			object := vm.pop()
+			if err := interpreter.IndexSetOperate(object, index, value); err != nil {
+				return vm.runtimeError("%s", errorMessage(err))
+			}
+			vm.push(value)
+		case OpUnary:
+			operator := lexer.TokenType(readByte())
+			result, err := interpreter.UnaryOperate(operator, vm.pop())
+			if err != nil {
+				return vm.runtimeError("%s", errorMessage(err))
+			}
+			vm.push(result)
+		case OpBinary:
+			operator := lexer.TokenType(readByte())
+			b := vm.pop()
+			a := vm.pop()
+			if ai, ok := a.(int); ok {
+				if bi, ok := b.(int); ok {
+					if result, ok := binaryInt(operator, ai, bi); ok {
+						vm.push(result)
+						break
+					}
+				}
+			}
+			result, err := interpreter.BinaryOperate(operator, a, b)
+			if err != nil {
+				return vm.runtimeError("%s", errorMessage(err))
+			}
+			vm.push(result)
+		case OpStringify:
+			vm.push(interpreter.ToString(vm.pop()))
+		case OpPrint:
+			fmt.Fprintf(vm.out, "%v\n", vm.pop())
+		case OpJump:
+			offset := readShort()
+			frame.ip += offset
+		case OpJumpIfFalse:
+			offset := readShort()
+			if isFalsey(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case OpJumpIfArgument:
+			index := int(readByte())
+			offset := readShort()
+			if index < frame.argCount {
+				frame.ip += offset
+			}
+		case OpLoop:
+			offset := readShort()
+			frame.ip -= offset
+		case OpCallArray:
+			arguments := vm.pop().([]interface{})
+			vm.stack = append(vm.stack, arguments...)
+			if err := vm.callValue(vm.peek(len(arguments)), len(arguments)); err != nil {
+				return err
+			}
+			loadFrame()
+		case OpCall:
+			argCount := int(readByte())
+			if err := vm.callValue(vm.peek(argCount), argCount); err != nil {
+				return err
+			}
+			loadFrame()
+		case OpInvoke:
+			name := readString()
+			argCount := int(readByte())
+			if err := vm.invoke(name, argCount); err != nil {
+				return err
+			}
+			loadFrame()
+		case OpSuperInvoke:
+			name := readString()
+			argCount := int(readByte())
+			superclass := vm.pop().(*Class)
+			methods := superclass.methods
+			if _, ok := vm.peek(argCount).(*Class); ok {
+				methods = superclass.classMethods
+			}
+			if err := vm.invokeFromClass(methods, name, argCount); err != nil {
+				return err
+			}
+			loadFrame()
+		case OpClosure:
+			function := constants[readShort()].(*Function)
+			closure := newClosure(function)
+			closure.globals = frame.closure.globals
+			for n := range closure.upvalues {
+				isLocal := readByte()
+				index := int(readByte())
+				if isLocal == 1 {
+					closure.upvalues[n] = vm.captureUpvalue(frame.slots + index)
+				} else {
+					closure.upvalues[n] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.slots)
+			slots := frame.slots
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.stack = vm.stack[:slots]
+			vm.push(result)
+			// 丢弃返回的栈帧中未结束的try语句
+			for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frames > len(vm.frames) {
+				vm.handlers = vm.handlers[:len(vm.handlers)-1]
+			}
+			if len(vm.frames) == base {
+				return nil
+			}
+			loadFrame()
+		case OpClass:
+			vm.push(newClass(readString()))
+		case OpInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				return vm.runtimeError("Superclass must be a class.")
+			}
+			subclass := vm.peek(0).(*Class)
+			subclass.superclass = superclass
+			// 继承时复制父类方法, 子类方法随后覆盖
+			for name, method := range superclass.methods {
+				subclass.methods[name] = method
+			}
+			for name, method := range superclass.classMethods {
+				subclass.classMethods[name] = method
+			}
+			vm.pop()
+		case OpMethod:
+			name := readString()
+			method := vm.pop().(*Closure)
+			vm.peek(0).(*Class).methods[name] = method
+		case OpClassMethod:
+			name := readString()
+			method := vm.pop().(*Closure)
+			vm.peek(0).(*Class).classMethods[name] = method
+		case OpArray:
+			count := readShort()
+			array := make([]interface{}, count)
+			copy(array, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(array)
+		case OpConcat:
+			count := readShort()
+			array := make([]interface{}, 0)
+			for _, value := range vm.stack[len(vm.stack)-count:] {
+				elements, err := interpreter.SpreadElements(value)
+				if err != nil {
+					return vm.runtimeError("%s", errorMessage(err))
+				}
+				array = append(array, elements...)
+			}
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(array)
+		case OpMap:
+			count := readShort()
+			m := interpreter.NewLoxMap()
+			for n := len(vm.stack) - count*2; n < len(vm.stack); n += 2 {
+				if err := m.Set(vm.stack[n], vm.stack[n+1]); err != nil {
+					return vm.runtimeError("%s", errorMessage(err))
+				}
+			}
+			vm.stack = vm.stack[:len(vm.stack)-count*2]
+			vm.push(m)
+		case OpImport:
+			path, from := readString(), readString()
+			module, err := vm.modules.Load(path, from, vm.evalModule)
+			if _, ok := err.(*interpreter.RuntimeError); ok {
+				return vm.wrapError(err)
+			} else if err != nil {
+				// 模块中的语法与编译错误已带有位置信息
+				return err
+			}
+			loadFrame()
+			vm.push(module)
+		case OpResult:
+			index := readShort()
+			for len(vm.results) <= index {
+				vm.results = append(vm.results, nil)
+			}
+			vm.results[index] = vm.pop()
+		default:
+			return vm.runtimeError("Unknown opcode %d.", code[frame.ip-1])
+		}
+	}
+}
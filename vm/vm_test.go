@@ -0,0 +1,55 @@
+package vm_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/WAY29/LoxGo/lox"
+)
+
+// clock()的输出与时间有关, 比较前去掉
+var clockLine = regexp.MustCompile(`(?m)^[0-9.]+\n`)
+
+func run(file string, useVM bool) (string, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	var out bytes.Buffer
+	x := lox.NewLox()
+	if useVM {
+		x.UseVM()
+	}
+	x.SetOutput(&out)
+	if _, err = x.EvalNamed(file, fp); err != nil {
+		out.WriteString("[ERROR] " + err.Error() + "\n")
+	}
+	return clockLine.ReplaceAllString(out.String(), ""), nil
+}
+
+// 两种后端执行tests下的脚本, 输出(包括错误)应完全一致
+func TestBackendParity(t *testing.T) {
+	files, err := filepath.Glob("../tests/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, "../tests/modules/main.lox")
+	for _, file := range files {
+		want, err := run(file, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := run(file, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: vm output differs\n--- interpreter\n%s\n--- vm\n%s", file, want, got)
+		}
+	}
+}